@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bpjs-hackathon/sehat-chain/internal/core"
+	"github.com/bpjs-hackathon/sehat-chain/types"
+	"github.com/bpjs-hackathon/sehat-chain/utils"
+)
+
+// Siapkan key lokal untuk semua config node di dir. Key file yang belum ada dibuat
+// lalu public key-nya dipasang pada validator & submitter genesis dengan id node tersebut.
+// Genesis yang belum ada dibuat dari <genesis>.template.json. Key dan genesis hasilnya
+// hanya ada di mesin lokal (gitignore)
+func initDevnet(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	geneses := make(map[string]*types.Genesis)
+	for _, path := range paths {
+		config, err := loadConfig(path)
+		if err != nil || config.NodeID == "" || config.KeyFile == "" {
+			continue // bukan config node (genesis.json)
+		}
+		if config.GenesisFile == "" {
+			config.GenesisFile = "genesis.json"
+		}
+
+		cred, err := loadOrGenerateKey(config.KeyFile)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		genesis, exists := geneses[config.GenesisFile]
+		if !exists {
+			if genesis, err = loadOrCreateGenesis(config.GenesisFile); err != nil {
+				return err
+			}
+			geneses[config.GenesisFile] = genesis
+		}
+
+		if !bindGenesisKey(genesis, config.NodeID, cred.PublicKey()) {
+			fmt.Printf("⚠️ %s (%s) is not a validator or submitter in %s\n", config.NodeID, path, config.GenesisFile)
+		}
+	}
+
+	for path, genesis := range geneses {
+		if err := genesis.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := writeJSON(path, genesis); err != nil {
+			return err
+		}
+		fmt.Printf("📝 Genesis %s updated (hash %s)\n", path, genesis.Hash())
+	}
+
+	fmt.Println("Remove the data directory of every node before starting the devnet")
+	return nil
+}
+
+// Key file yang sudah ada dipakai ulang agar identitas node tidak berubah
+func loadOrGenerateKey(path string) (*utils.CryptoCred, error) {
+	cred, err := utils.LoadCred(path)
+	if err == nil {
+		fmt.Printf("🔑 Using existing key %s\n", path)
+		return cred, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if cred, err = generateKeyFile(path); err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("🔑 Generated key %s\n", path)
+	return cred, nil
+}
+
+// Genesis lokal yang sudah ada dipakai ulang, jika belum ada dibuat dari template.
+// Template belum berisi public key sehingga tidak divalidasi di sini
+func loadOrCreateGenesis(path string) (*types.Genesis, error) {
+	genesis, err := core.LoadGenesis(path)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return genesis, err
+	}
+
+	templatePath := genesisTemplatePath(path)
+	data, err := os.ReadFile(templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read genesis template: %w", err)
+	}
+
+	genesis = &types.Genesis{}
+	if err := json.Unmarshal(data, genesis); err != nil {
+		return nil, fmt.Errorf("invalid genesis template %s: %w", templatePath, err)
+	}
+
+	fmt.Printf("📝 Creating %s from %s\n", path, templatePath)
+	return genesis, nil
+}
+
+// configs/genesis.json -> configs/genesis.template.json
+func genesisTemplatePath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".template" + filepath.Ext(path)
+}
+
+// Pasang public key pada validator & submitter dengan id node
+func bindGenesisKey(genesis *types.Genesis, nodeID string, publicKey string) bool {
+	bound := false
+	for i := range genesis.Validators {
+		if genesis.Validators[i].ID == nodeID {
+			genesis.Validators[i].PublicKey = publicKey
+			bound = true
+		}
+	}
+	for i := range genesis.Submitters {
+		if genesis.Submitters[i].ID == nodeID {
+			genesis.Submitters[i].PublicKey = publicKey
+			bound = true
+		}
+	}
+	return bound
+}
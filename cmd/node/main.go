@@ -2,32 +2,64 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/bpjs-hackathon/sehat-chain/internal/core"
+	"github.com/bpjs-hackathon/sehat-chain/internal/mempool"
+	smartcontract "github.com/bpjs-hackathon/sehat-chain/internal/smart_contract"
 	"github.com/bpjs-hackathon/sehat-chain/types"
+	"github.com/bpjs-hackathon/sehat-chain/utils"
 )
 
 // Configuration structure
 type Config struct {
-	NodeID     string                  `json:"node_id"`
-	Secret     string                  `json:"secret"`
-	Port       string                  `json:"port"`
-	APIPort    string                  `json:"api_port"`
-	Validators []types.ValidatorConfig `json:"validators"`
+	NodeID      string `json:"node_id"`
+	KeyFile     string `json:"key_file"`
+	Port        string `json:"port"`
+	APIPort     string `json:"api_port"`
+	DataDir     string `json:"data_dir"`     // lokasi penyimpanan block (default data/<node_id>)
+	GenesisFile string `json:"genesis_file"` // chain id, validator, faskes & state awal (default genesis.json)
+	TariffDir   string `json:"tariff_dir"`   // direktori tabel tarif INA-CBG (default tariffs)
+
+	Mempool         mempool.Config             `json:"mempool"`          // batas pool tx (kosong = default)
+	BlockProduction core.BlockProductionConfig `json:"block_production"` // interval & budget block (kosong = default)
 }
 
 func main() {
 	// Parse command line flags
 	configPath := flag.String("config", "config.json", "Path to configuration file")
 	nodeID := flag.String("id", "", "Node ID (overrides config file)")
-	secret := flag.String("secret", "", "Node secret (overrides config file)")
+	keyFile := flag.String("key", "", "Path to node key file (overrides config file)")
 	port := flag.String("port", "", "P2P Port (overrides config file)")
+	genKey := flag.String("genkey", "", "Generate a new key file at the given path and exit")
+	devnetDir := flag.String("init-devnet", "", "Generate missing node keys for the configs in the given directory, bind them in genesis and exit")
 	flag.Parse()
 
+	if *devnetDir != "" {
+		if err := initDevnet(*devnetDir); err != nil {
+			fmt.Printf("Failed to initialise devnet keys: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *genKey != "" {
+		cred, err := generateKeyFile(*genKey)
+		if err != nil {
+			fmt.Printf("Failed to generate key file: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Key file written to %s\n", *genKey)
+		fmt.Printf("Public key: %s\n", cred.PublicKey())
+		os.Exit(0)
+	}
+
 	// Load configuration from file
 	config, err := loadConfig(*configPath)
 	if err != nil {
@@ -45,22 +77,56 @@ func main() {
 	if *nodeID != "" {
 		config.NodeID = *nodeID
 	}
-	if *secret != "" {
-		config.Secret = *secret
+	if *keyFile != "" {
+		config.KeyFile = *keyFile
 	}
 	if *port != "" {
 		config.Port = *port
 	}
 
 	// Validate configuration
-	if config.NodeID == "" || config.Secret == "" || config.Port == "" || config.APIPort == "" {
-		fmt.Println("❌ Error: node_id, secret, and port must be specified")
+	if config.NodeID == "" || config.KeyFile == "" || config.Port == "" || config.APIPort == "" {
+		fmt.Println("❌ Error: node_id, key_file, port, and api_port must be specified")
+		os.Exit(1)
+	}
+
+	if config.DataDir == "" {
+		config.DataDir = filepath.Join("data", config.NodeID)
+	}
+	if config.TariffDir == "" {
+		config.TariffDir = "tariffs"
+	}
+	if config.GenesisFile == "" {
+		config.GenesisFile = "genesis.json"
+	}
+
+	genesis, err := core.LoadGenesis(config.GenesisFile)
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		if errors.Is(err, fs.ErrNotExist) {
+			fmt.Println("Devnet genesis is created locally from genesis.template.json, run -init-devnet configs first")
+		}
+		os.Exit(1)
+	}
+
+	tariffs, err := loadTariffs(config.TariffDir)
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	cred, err := utils.LoadCred(config.KeyFile)
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		if errors.Is(err, fs.ErrNotExist) {
+			fmt.Println("Private keys are not committed, generate them locally with -init-devnet configs (or -genkey)")
+		}
 		os.Exit(1)
 	}
 
 	// Determine if this node is a validator
 	isValidator := false
-	for _, v := range config.Validators {
+	for _, v := range genesis.Validators {
 		if v.ID == config.NodeID {
 			isValidator = true
 			break
@@ -77,15 +143,24 @@ func main() {
 	fmt.Println("========================================")
 	fmt.Printf("Node ID: %s\n", config.NodeID)
 	fmt.Printf("P2P Port: %s\n", config.Port)
-	fmt.Printf("Secret: %s\n", maskSecret(config.Secret))
-	fmt.Printf("Known Validators: %d\n", len(config.Validators))
+	fmt.Printf("Data Dir: %s\n", config.DataDir)
+	fmt.Printf("Public Key: %s\n", cred.PublicKey())
+	fmt.Printf("Chain ID: %s\n", genesis.ChainID)
+	fmt.Printf("Genesis Hash: %s\n", genesis.Hash())
+	fmt.Printf("Genesis Validators: %d\n", len(genesis.Validators))
+	fmt.Printf("Authorised Submitters: %d\n", len(genesis.Submitters))
+	fmt.Printf("Tariff Tables: %v (genesis %s %s)\n", tariffs.Versions(), genesis.TariffVersion, genesis.TariffHash)
 	fmt.Println("========================================")
 
 	// Create and start node
-	node := core.CreateNode(config.NodeID, config.Secret, config.Port, config.APIPort, config.Validators)
+	node, err := core.CreateNode(config.NodeID, cred, config.Port, config.APIPort, config.DataDir, genesis, tariffs, config.Mempool, config.BlockProduction)
+	if err != nil {
+		fmt.Printf("❌ Failed to create node: %v\n", err)
+		os.Exit(1)
+	}
 
 	fmt.Printf("Node %s created\n", config.NodeID)
-	fmt.Println("Genesis block initialized")
+	fmt.Printf("Blockchain loaded at height %d\n", node.Blockchain.GetLatestHeight())
 
 	// Start the node (opens P2P and connects to network)
 	if !isValidator {
@@ -116,6 +191,22 @@ func main() {
 	select {}
 }
 
+// Muat tabel tarif dari dir (jika ada) ditambah mock engine untuk development
+func loadTariffs(dir string) (*smartcontract.TariffRegistry, error) {
+	tariffs := smartcontract.NewTariffRegistry()
+	if _, err := os.Stat(dir); err == nil {
+		tariffs, err = smartcontract.LoadTariffDir(dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tariffs.Register(smartcontract.NewMockTariffEngine()); err != nil {
+		return nil, err
+	}
+	return tariffs, nil
+}
+
 // loadConfig loads configuration from JSON file
 func loadConfig(path string) (*Config, error) {
 	file, err := os.Open(path)
@@ -134,30 +225,50 @@ func loadConfig(path string) (*Config, error) {
 }
 
 // createDefaultConfig creates a default configuration file
+// beserta key file dan genesis.json baru untuk validator-1
 func createDefaultConfig(path string) error {
-	defaultConfig := Config{
-		NodeID: "validator-1",
-		Secret: "secret-validator-1",
-		Port:   "9001",
+	keyPath := "validator-1.key"
+	cred, err := generateKeyFile(keyPath)
+	if err != nil {
+		return err
+	}
+
+	genesisPath := filepath.Join(filepath.Dir(path), "genesis.json")
+	defaultGenesis := types.Genesis{
+		ChainID:     "sehat-devnet",
+		GenesisTime: time.Now().Unix(),
 		Validators: []types.ValidatorConfig{
 			{
-				ID:      "validator-1",
-				Secret:  "secret-validator-1",
-				Address: ":9001",
-			},
-			{
-				ID:      "validator-2",
-				Secret:  "secret-validator-2",
-				Address: ":9002",
+				ID:        "validator-1",
+				PublicKey: cred.PublicKey(),
+				Address:   ":9001",
 			},
+		},
+		Submitters: []types.SubmitterConfig{
 			{
-				ID:      "validator-3",
-				Secret:  "secret-validator-3",
-				Address: ":9003",
+				ID:        "validator-1",
+				Role:      types.RoleBPJSAdmin,
+				PublicKey: cred.PublicKey(),
 			},
 		},
+		TariffVersion: smartcontract.MockTariffVersion,
+		TariffHash:    smartcontract.NewMockTariffEngine().Hash(),
+	}
+	if err := writeJSON(genesisPath, defaultGenesis); err != nil {
+		return err
+	}
+
+	defaultConfig := Config{
+		NodeID:      "validator-1",
+		KeyFile:     keyPath,
+		Port:        "9001",
+		APIPort:     "6691",
+		GenesisFile: genesisPath,
 	}
+	return writeJSON(path, defaultConfig)
+}
 
+func writeJSON(path string, value any) error {
 	file, err := os.Create(path)
 	if err != nil {
 		return err
@@ -165,14 +276,20 @@ func createDefaultConfig(path string) error {
 	defer file.Close()
 
 	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(defaultConfig)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(value)
 }
 
-// maskSecret masks the secret for display purposes
-func maskSecret(secret string) string {
-	if len(secret) <= 8 {
-		return "****"
+// generateKeyFile generates a new Ed25519 keypair and saves it to path
+func generateKeyFile(path string) (*utils.CryptoCred, error) {
+	cred, err := utils.GenerateCred()
+	if err != nil {
+		return nil, err
 	}
-	return secret[:4] + "****" + secret[len(secret)-4:]
+
+	if err := cred.Save(path); err != nil {
+		return nil, err
+	}
+
+	return cred, nil
 }
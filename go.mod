@@ -2,4 +2,9 @@ module github.com/bpjs-hackathon/sehat-chain
 
 go 1.25.4
 
-require github.com/google/uuid v1.6.0
+require (
+	github.com/fxamacker/cbor/v2 v2.9.0
+	github.com/google/uuid v1.6.0
+)
+
+require github.com/x448/float16 v0.8.4 // indirect
@@ -1,15 +1,24 @@
 package consensus
 
 import (
-	"encoding/json"
 	"fmt"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/bpjs-hackathon/sehat-chain/internal/p2p"
 	"github.com/bpjs-hackathon/sehat-chain/types"
+	"github.com/bpjs-hackathon/sehat-chain/utils"
+	"github.com/google/uuid"
 )
 
+// Batas waktu satu view sebelum validator meminta pergantian leader.
+// Timeout bertambah linear setiap view berganti pada height yang sama
+const RoundTimeout = 10 * time.Second
+
+// Toleransi timestamp block di depan jam lokal validator
+const MaxClockDrift = 30 * time.Second
+
 type RoundRobin struct {
 	ID   string
 	Node NodeInterface
@@ -17,24 +26,59 @@ type RoundRobin struct {
 
 	validators     map[string]types.ValidatorConfig // string id
 	validatorsSort []string                         // sorted validator
+
+	// State round yang sedang berjalan
+	view          uint64 // view untuk height berikutnya
+	proposal      *types.Block
+	prepareVotes  map[string]string // id validator -> signature PREPARE
+	commitVotes   map[string]string // id validator -> signature COMMIT
+	prepareQCSent bool
+
+	// Block yang sudah kita vote COMMIT, dibawa saat view change
+	lockedBlock *types.Block
+	lockedQC    *types.QuorumCertificate
+
+	// View change
+	viewChanges     map[uint64]map[string]p2p.ViewChangePayload // view -> id validator -> pesan
+	pendingProposal *p2p.ProposalPayload                        // proposal untuk view yang belum kita masuki
+	nextProposal    *p2p.ProposalPayload                        // proposal height berikutnya yang datang sebelum block saat ini kita commit
+	timer           *time.Timer
+	timerHeight     uint64
+	timerView       uint64
 }
 
-func NewRoundRobin(id string, node NodeInterface, validators map[string]types.ValidatorConfig) *RoundRobin {
-	// sort validator
-	validatorsSort := make([]string, 0, len(validators))
-	for _, validator := range validators {
-		validatorsSort = append(validatorsSort, validator.ID)
+func NewRoundRobin(id string, node NodeInterface) *RoundRobin {
+	r := &RoundRobin{
+		ID:          id,
+		Node:        node,
+		viewChanges: make(map[uint64]map[string]p2p.ViewChangePayload),
 	}
-	sort.Strings(validatorsSort)
+	r.setValidators(node.Validators())
+	return r
+}
 
-	return &RoundRobin{
-		ID:             id,
-		Node:           node,
-		validators:     validators,
-		validatorsSort: validatorsSort,
+// Ganti validator set yang dipakai untuk height berikutnya
+func (r *RoundRobin) setValidators(validators []types.ValidatorConfig) {
+	r.validators = make(map[string]types.ValidatorConfig, len(validators))
+	r.validatorsSort = make([]string, 0, len(validators))
+	for _, validator := range validators {
+		r.validators[validator.ID] = validator
+		r.validatorsSort = append(r.validatorsSort, validator.ID)
 	}
+	sort.Strings(r.validatorsSort)
 }
 
+// Block di commit di luar consensus (sync). Round yang sedang berjalan untuk
+// height lama tidak berlaku lagi, mulai dari view 0 pada height berikutnya
+func (r *RoundRobin) ResetAfterSync() {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.advanceHeight()
+}
+
+// Dipanggil ketika ada tx yang menunggu. Leader membuat block proposal,
+// validator lain memasang timeout untuk leader view saat ini
 func (r *RoundRobin) StartRound() {
 	r.mux.Lock()
 	defer r.mux.Unlock()
@@ -44,76 +88,329 @@ func (r *RoundRobin) StartRound() {
 		return
 	}
 
+	r.armTimer()
+
 	if !r.IsLeader() {
 		fmt.Printf("node %s cannot start round because it is not the leader\n", r.ID)
 		return
 	}
 
-	block := r.Node.CreateBlock()
-	// mock signature
-	hash := block.HeaderHash()
-	block.QC.HeaderHash = hash
-	block.QC.Signatures = r.Node.SignData([]byte(hash))
+	// Jangan membuat proposal baru jika proposal view ini masih di vote
+	nextHeight := r.Node.GetLatestBlock().Header.Height + 1
+	if r.proposal != nil && r.proposal.Header.Height == nextHeight {
+		fmt.Printf("round for height %d view %d already in progress\n", nextHeight, r.view)
+		return
+	}
+
+	r.propose(r.lockedBlock)
+}
 
-	r.Node.CommitBlock(block)
+// Buat (atau propose ulang block locked) lalu kirim ke validator
+func (r *RoundRobin) propose(locked *types.Block) {
+	var block types.Block
+	if locked != nil {
+		block = *locked
+		fmt.Printf("re-proposing locked block %d in view %d\n", block.Header.Height, r.view)
+	} else {
+		block = r.Node.CreateBlock()
+		block.Header.View = r.view
+	}
+	r.resetRound(&block)
 
-	// buat payload untuk broadcast
-	payload, err := json.Marshal(block)
+	payload, err := p2p.EncodePayload(p2p.ProposalPayload{
+		View:      r.view,
+		Block:     block,
+		LeaderID:  r.ID,
+		Signature: r.Node.SignData(types.ProposalSignBytes(block.HeaderHash(), r.view)),
+	})
 	if err != nil {
-		fmt.Printf("failed to marshal block: %v\n", err)
+		fmt.Printf("failed to marshal proposal: %v\n", err)
+		return
+	}
+
+	r.sendToValidators(p2p.Message{
+		SenderID:  r.ID,
+		RequestID: uuid.NewString(),
+		Type:      p2p.MsgTypeProposal,
+		Payload:   payload,
+	})
+
+	// Leader ikut vote untuk proposal miliknya sendiri
+	r.castVote(types.VoteTypePrepare, block)
+}
+
+// Validator menerima proposal dari leader, validasi lalu kirim vote PREPARE
+func (r *RoundRobin) HandleProposal(proposal p2p.ProposalPayload) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.handleProposal(proposal)
+}
+
+func (r *RoundRobin) handleProposal(proposal p2p.ProposalPayload) {
+	if !r.Node.IsValidator() {
+		return
+	}
+
+	block := proposal.Block
+
+	// Proposal palsu yang mengatasnamakan leader tidak boleh mengisi slot vote view ini
+	if err := r.verifyProposalSignature(proposal); err != nil {
+		fmt.Printf("rejecting proposal for height %d view %d: %v\n", block.Header.Height, proposal.View, err)
+		return
+	}
+
+	// Leader baru bisa propose sebelum block yang sama sampai ke kita, proses setelah commit.
+	// Leader height tersebut baru bisa dicek setelah validator set-nya diketahui
+	if block.Header.Height == r.Node.GetLatestBlock().Header.Height+2 {
+		r.nextProposal = &proposal
+		return
+	}
+
+	if expected := r.getLeader(block.Header.Height, proposal.View); proposal.LeaderID != expected {
+		fmt.Printf("rejecting proposal from %s: leader for view %d is %s\n", proposal.LeaderID, proposal.View, expected)
+		return
+	}
+
+	// Simpan proposal untuk view yang belum kita masuki (view change belum quorum di sisi kita)
+	if proposal.View > r.view {
+		r.pendingProposal = &proposal
+		return
+	}
+
+	if proposal.View < r.view {
+		fmt.Printf("ignoring proposal for old view %d (current %d)\n", proposal.View, r.view)
+		return
+	}
+
+	if err := r.validateBlock(block); err != nil {
+		fmt.Printf("rejecting proposal from %s: %v\n", block.Header.ProposerID, err)
+		return
+	}
+
+	// Block dari view sebelumnya hanya boleh di propose ulang oleh leader view saat ini
+	if block.Header.View > proposal.View {
+		fmt.Printf("proposal block view %d is ahead of proposal view %d\n", block.Header.View, proposal.View)
+		return
+	}
+
+	// Validator yang locked hanya vote untuk block yang sama
+	if r.lockedBlock != nil && r.lockedBlock.Header.Height == block.Header.Height &&
+		r.lockedBlock.HeaderHash() != block.HeaderHash() {
+		fmt.Printf("locked on block %s, rejecting proposal %s\n", r.lockedBlock.HeaderHash(), block.HeaderHash())
+		return
+	}
+
+	// Hanya vote satu proposal per view
+	if r.proposal != nil && r.proposal.Header.Height == block.Header.Height {
+		if r.proposal.HeaderHash() != block.HeaderHash() {
+			fmt.Printf("conflicting proposal for height %d view %d, ignoring\n", block.Header.Height, r.view)
+		}
+		return
+	}
+
+	r.resetRound(&block)
+	r.armTimer()
+	r.castVote(types.VoteTypePrepare, block)
+}
+
+// Validator menerima QC PREPARE dari leader lalu kirim vote COMMIT
+func (r *RoundRobin) HandlePrepareQC(qc types.QuorumCertificate) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if !r.Node.IsValidator() || r.proposal == nil {
 		return
 	}
 
-	blockMsg := p2p.Message{
-		SenderID:   r.ID,
-		RequestID:  "",
-		ResponseID: "",
-		Type:       p2p.MsgTypeBlockSend,
-		Payload:    payload,
+	if qc.VoteType != types.VoteTypePrepare || qc.View != r.view || qc.HeaderHash != r.proposal.HeaderHash() {
+		fmt.Printf("prepare QC does not match current proposal\n")
+		return
 	}
 
-	r.Node.Broadcast(blockMsg)
+	if err := r.verifyQC(qc); err != nil {
+		fmt.Printf("invalid prepare QC: %v\n", err)
+		return
+	}
+
+	// Lock block sebelum vote COMMIT
+	locked := *r.proposal
+	r.lockedBlock = &locked
+	r.lockedQC = &qc
+
+	r.castVote(types.VoteTypeCommit, *r.proposal)
+}
+
+// Leader mengumpulkan vote dari validator
+func (r *RoundRobin) HandleVote(vote p2p.VotePayload) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.addVote(vote)
 }
 
 func (r *RoundRobin) HandleIncomingBlock(block types.Block) {
 	r.mux.Lock()
 	defer r.mux.Unlock()
 
+	if err := r.validateBlock(block); err != nil {
+		fmt.Printf("rejecting incoming block: %v\n", err)
+		fmt.Printf("\tProposer: %s\n", block.Header.ProposerID)
+		return
+	}
+
+	if err := r.verifyCommitQC(block); err != nil {
+		fmt.Printf("invalid QC for block %d: %v\n", block.Header.Height, err)
+		return
+	}
+
+	fmt.Println("Incoming block validated, commiting block")
+	r.commit(block)
+}
+
+// Verifikasi QC COMMIT block terhadap validator set saat ini (dipakai juga saat sync)
+func (r *RoundRobin) VerifyCommitQC(block types.Block) error {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	return r.verifyCommitQC(block)
+}
+
+func (r *RoundRobin) verifyCommitQC(block types.Block) error {
+	// Block hanya di commit jika membawa QC COMMIT dari > 2/3 validator
+	if block.QC.VoteType != types.VoteTypeCommit || block.QC.HeaderHash != block.HeaderHash() || block.QC.Height != block.Header.Height {
+		return fmt.Errorf("QC does not commit this block")
+	}
+
+	// View pada QC tidak boleh lebih rendah dari view saat block dibuat
+	if block.QC.View < block.Header.View {
+		return fmt.Errorf("QC view %d is lower than block view %d", block.QC.View, block.Header.View)
+	}
+
+	return r.verifyQC(block.QC)
+}
+
+// Commit block lalu reset state consensus untuk height berikutnya
+// Block yang gagal di commit (hasil eksekusi berbeda) membuat node tertinggal dan mengejar lewat sync
+func (r *RoundRobin) commit(block types.Block) {
+	if err := r.Node.CommitBlock(block); err != nil {
+		fmt.Printf("failed to commit block %d: %v\n", block.Header.Height, err)
+	}
+	r.advanceHeight()
+}
+
+// Reset state consensus untuk height setelah block terakhir
+func (r *RoundRobin) advanceHeight() {
+	r.setValidators(r.Node.Validators()) // validator set bisa berubah karena tx governance
+
+	r.resetRound(nil)
+	r.view = 0
+	r.lockedBlock = nil
+	r.lockedQC = nil
+	r.viewChanges = make(map[uint64]map[string]p2p.ViewChangePayload)
+	r.pendingProposal = nil
+	r.stopTimer()
+
+	if next := r.nextProposal; next != nil {
+		r.nextProposal = nil
+		if next.Block.Header.Height == r.Node.GetLatestBlock().Header.Height+1 {
+			r.handleProposal(*next)
+		}
+	}
+}
+
+func (r *RoundRobin) IsLeader() bool {
+	nextHeight := r.Node.GetLatestBlock().Header.Height + 1
+	return r.getLeader(nextHeight, r.view) == r.ID
+}
+
+// Leader bergiliran berdasarkan height, bergeser satu validator setiap view change
+func (r *RoundRobin) getLeader(height uint64, view uint64) string {
+	index := (height + view) % uint64(len(r.validatorsSort))
+	return r.validatorsSort[index]
+}
+
+// Proposal harus ditandatangani validator yang disebut sebagai leader-nya
+func (r *RoundRobin) verifyProposalSignature(proposal p2p.ProposalPayload) error {
+	validator, exists := r.validators[proposal.LeaderID]
+	if !exists {
+		return fmt.Errorf("proposal signer %s is not a validator", proposal.LeaderID)
+	}
+
+	signBytes := types.ProposalSignBytes(proposal.Block.HeaderHash(), proposal.View)
+	if err := utils.Verify(validator.PublicKey, proposal.Signature, signBytes); err != nil {
+		return fmt.Errorf("invalid proposal signature from %s: %v", proposal.LeaderID, err)
+	}
+
+	return nil
+}
+
+// Validasi block terhadap block terakhir (tanpa QC)
+func (r *RoundRobin) validateBlock(block types.Block) error {
 	// Tidak menerima block dengan height yang lebih rendah
 	latest := r.Node.GetLatestBlock()
 	if block.Header.Height != latest.Header.Height+1 {
-		fmt.Printf("invalid block height (handle incoming). Expecting %d, got %d\n", latest.Header.Height+1, block.Header.Height)
-		fmt.Printf("\tProposer: %s\n", block.Header.ProposerID)
-		return
+		return fmt.Errorf("invalid block height. Expecting %d, got %d", latest.Header.Height+1, block.Header.Height)
 	}
 
-	// Pastikan block datang dari leader yang benar
-	expectedLeader := r.getLeaderForHeight(block.Header.Height)
+	// Pastikan block dibuat oleh leader yang benar untuk view block tersebut
+	expectedLeader := r.getLeader(block.Header.Height, block.Header.View)
 	if block.Header.ProposerID != expectedLeader {
-		fmt.Printf("invalid proposer. Expecting %s, got %s\n", expectedLeader, block.Header.ProposerID)
-		return
+		return fmt.Errorf("invalid proposer for view %d. Expecting %s, got %s", block.Header.View, expectedLeader, block.Header.ProposerID)
 	}
 
 	// Validate header hash
 	if block.Header.PrevHash != latest.HeaderHash() {
-		fmt.Printf("invalid previous block hash. Expecting %s, got %s\n", latest.HeaderHash(), block.Header.PrevHash)
-		return
+		return fmt.Errorf("invalid previous block hash. Expecting %s, got %s", latest.HeaderHash(), block.Header.PrevHash)
+	}
+
+	// Timestamp block dipakai smart contract, harus monoton dan tidak jauh di depan jam lokal
+	if block.Header.Timestamp < latest.Header.Timestamp {
+		return fmt.Errorf("block timestamp %d is before previous block %d", block.Header.Timestamp, latest.Header.Timestamp)
+	}
+	if block.Header.Timestamp > time.Now().Add(MaxClockDrift).Unix() {
+		return fmt.Errorf("block timestamp %d is too far in the future", block.Header.Timestamp)
 	}
 
 	// Validate tx root
+	if txRoot := types.CalculateTxRoot(block.Transactions); block.Header.TxRoot != txRoot {
+		return fmt.Errorf("invalid tx root. Expecting %s, got %s", txRoot, block.Header.TxRoot)
+	}
 
-	// Validate world state
+	// Tx dengan signature tidak valid, sudah pernah masuk chain, atau nonce-nya sudah terpakai ditolak
+	if err := r.Node.ValidateBlockTxs(block); err != nil {
+		return err
+	}
 
-	fmt.Println("Incoming block validated, commiting block")
-	r.Node.CommitBlock(block)
+	// Validate world state & receipts (eksekusi pada overlay, world state asli tidak berubah)
+	stateRoot, receiptsRoot := r.Node.CalculateRoots(block)
+	if block.Header.StateRoot != stateRoot {
+		return fmt.Errorf("invalid state root. Expecting %s, got %s", stateRoot, block.Header.StateRoot)
+	}
+	if block.Header.ReceiptsRoot != receiptsRoot {
+		return fmt.Errorf("invalid receipts root. Expecting %s, got %s", receiptsRoot, block.Header.ReceiptsRoot)
+	}
+
+	return nil
 }
 
-func (r *RoundRobin) IsLeader() bool {
-	nextHeight := r.Node.GetLatestBlock().Header.Height + 1
-	return r.getLeaderForHeight(nextHeight) == r.ID
+// Reset state round. proposal nil berarti tidak ada round berjalan
+func (r *RoundRobin) resetRound(proposal *types.Block) {
+	r.proposal = proposal
+	r.prepareVotes = make(map[string]string)
+	r.commitVotes = make(map[string]string)
+	r.prepareQCSent = false
 }
 
-func (r *RoundRobin) getLeaderForHeight(height uint64) string {
-	index := height % uint64(len(r.validatorsSort))
-	return r.validatorsSort[index]
+// Kirim pesan ke semua validator lain (bukan ke light node)
+func (r *RoundRobin) sendToValidators(message p2p.Message) {
+	for _, id := range r.validatorsSort {
+		if id == r.ID {
+			continue
+		}
+
+		if err := r.Node.Send(id, message); err != nil {
+			fmt.Printf("failed to send %s to %s: %v\n", message.Type, id, err)
+		}
+	}
 }
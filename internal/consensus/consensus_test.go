@@ -0,0 +1,503 @@
+package consensus
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/bpjs-hackathon/sehat-chain/internal/p2p"
+	"github.com/bpjs-hackathon/sehat-chain/types"
+	"github.com/bpjs-hackathon/sehat-chain/utils"
+)
+
+// Node palsu: mencatat pesan yang dikirim dan block yang di commit
+type fakeNode struct {
+	id         string
+	creds      map[string]*utils.CryptoCred
+	validators []types.ValidatorConfig
+	latest     types.Block
+	committed  []types.Block
+	sent       []sentMessage
+}
+
+type sentMessage struct {
+	to      string // "" = broadcast
+	message p2p.Message
+}
+
+func (f *fakeNode) Broadcast(message p2p.Message) {
+	f.sent = append(f.sent, sentMessage{message: message})
+}
+
+func (f *fakeNode) Send(peerID string, message p2p.Message) error {
+	f.sent = append(f.sent, sentMessage{to: peerID, message: message})
+	return nil
+}
+
+func (f *fakeNode) GetLatestBlock() types.Block { return f.latest }
+
+func (f *fakeNode) CreateBlock() types.Block { return testBlock(f.latest, f.id, 0) }
+
+func (f *fakeNode) CommitBlock(block types.Block) error {
+	f.committed = append(f.committed, block)
+	f.latest = block
+	return nil
+}
+
+func (f *fakeNode) CalculateRoots(block types.Block) (string, string) { return "", "" }
+
+func (f *fakeNode) ValidateBlockTxs(block types.Block) error { return nil }
+
+func (f *fakeNode) IsValidator() bool { return true }
+
+func (f *fakeNode) Validators() []types.ValidatorConfig { return f.validators }
+
+func (f *fakeNode) SignData(data []byte) string { return f.creds[f.id].Sign(data) }
+
+// Pesan bertipe messageType yang dikirim node, didecode ke payload T
+func sentPayloads[T any](t *testing.T, f *fakeNode, messageType string) []T {
+	t.Helper()
+
+	var payloads []T
+	for _, sent := range f.sent {
+		if sent.message.Type != messageType {
+			continue
+		}
+		var payload T
+		if err := sent.message.DecodePayload(&payload); err != nil {
+			t.Fatal(err)
+		}
+		payloads = append(payloads, payload)
+	}
+	return payloads
+}
+
+// Key untuk validator v-0..v-(n-1) dan satu pihak luar
+func testCreds(t *testing.T, n int) (map[string]*utils.CryptoCred, []types.ValidatorConfig) {
+	t.Helper()
+
+	creds := make(map[string]*utils.CryptoCred)
+	var validators []types.ValidatorConfig
+	for i := 0; i <= n; i++ {
+		cred, err := utils.GenerateCred()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == n {
+			creds["outsider"] = cred
+			break
+		}
+
+		id := fmt.Sprintf("v-%d", i)
+		creds[id] = cred
+		validators = append(validators, types.ValidatorConfig{ID: id, PublicKey: cred.PublicKey()})
+	}
+	return creds, validators
+}
+
+func testConsensus(t *testing.T, id string, creds map[string]*utils.CryptoCred, validators []types.ValidatorConfig) (*RoundRobin, *fakeNode) {
+	t.Helper()
+
+	node := &fakeNode{
+		id:         id,
+		creds:      creds,
+		validators: validators,
+		latest:     types.Block{Header: types.BlockHeader{Timestamp: 1000}},
+	}
+	r := NewRoundRobin(id, node)
+	t.Cleanup(func() {
+		r.mux.Lock()
+		r.stopTimer()
+		r.mux.Unlock()
+	})
+	return r, node
+}
+
+func testBlock(prev types.Block, proposer string, view uint64) types.Block {
+	return types.Block{Header: types.BlockHeader{
+		Height:     prev.Header.Height + 1,
+		Timestamp:  prev.Header.Timestamp + 1,
+		PrevHash:   prev.HeaderHash(),
+		TxRoot:     types.CalculateTxRoot(nil),
+		ProposerID: proposer,
+		View:       view,
+	}}
+}
+
+func signedVote(creds map[string]*utils.CryptoCred, nodeID string, signer string, voteType string, block types.Block, view uint64) p2p.VotePayload {
+	hash := block.HeaderHash()
+	return p2p.VotePayload{
+		NodeID:      nodeID,
+		BlockHeight: block.Header.Height,
+		View:        view,
+		BlockHash:   hash,
+		VoteType:    voteType,
+		Signature:   creds[signer].Sign(types.VoteSignBytes(voteType, block.Header.Height, view, hash)),
+	}
+}
+
+func signedQC(creds map[string]*utils.CryptoCred, voteType string, block types.Block, view uint64, signers ...string) types.QuorumCertificate {
+	qc := types.QuorumCertificate{
+		HeaderHash: block.HeaderHash(),
+		Height:     block.Header.Height,
+		View:       view,
+		VoteType:   voteType,
+		Signers:    signers,
+		Signatures: make(map[string]string),
+	}
+	for _, signer := range signers {
+		qc.Signatures[signer] = creds[signer].Sign(qc.SignBytes())
+	}
+	return qc
+}
+
+func signedProposal(creds map[string]*utils.CryptoCred, leader string, view uint64, block types.Block) p2p.ProposalPayload {
+	return p2p.ProposalPayload{
+		View:      view,
+		Block:     block,
+		LeaderID:  leader,
+		Signature: creds[leader].Sign(types.ProposalSignBytes(block.HeaderHash(), view)),
+	}
+}
+
+func signedViewChange(creds map[string]*utils.CryptoCred, nodeID string, height uint64, view uint64) p2p.ViewChangePayload {
+	return p2p.ViewChangePayload{
+		NodeID:    nodeID,
+		Height:    height,
+		View:      view,
+		Signature: creds[nodeID].Sign(types.ViewChangeSignBytes(height, view)),
+	}
+}
+
+func TestQuorumSize(t *testing.T) {
+	tests := []struct {
+		validators int
+		want       int
+	}{
+		{1, 1}, {2, 2}, {3, 3}, {4, 3}, {5, 4}, {6, 5}, {7, 5}, {10, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d validators", tt.validators), func(t *testing.T) {
+			creds, validators := testCreds(t, tt.validators)
+			r, _ := testConsensus(t, "v-0", creds, validators)
+			if got := r.quorumSize(); got != tt.want {
+				t.Fatalf("quorum %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeaderCollectsVotes(t *testing.T) {
+	// {id di vote, pemilik key yang menandatangani}
+	type vote struct{ nodeID, signer string }
+
+	tests := []struct {
+		name        string
+		prepare     []vote
+		commit      []vote
+		wantQC      bool     // QC PREPARE disebar
+		wantSigners []string // signer QC COMMIT block yang di commit (nil = tidak commit)
+	}{
+		{
+			name:        "quorum with leader vote",
+			prepare:     []vote{{"v-0", "v-0"}, {"v-2", "v-2"}},
+			commit:      []vote{{"v-0", "v-0"}, {"v-2", "v-2"}},
+			wantQC:      true,
+			wantSigners: []string{"v-0", "v-1", "v-2"},
+		},
+		{
+			name:        "votes after quorum are not needed",
+			prepare:     []vote{{"v-3", "v-3"}, {"v-0", "v-0"}, {"v-2", "v-2"}},
+			commit:      []vote{{"v-3", "v-3"}, {"v-2", "v-2"}, {"v-0", "v-0"}},
+			wantQC:      true,
+			wantSigners: []string{"v-1", "v-2", "v-3"},
+		},
+		{
+			name:    "below quorum",
+			prepare: []vote{{"v-0", "v-0"}},
+		},
+		{
+			name:    "duplicate vote counted once",
+			prepare: []vote{{"v-0", "v-0"}, {"v-0", "v-0"}},
+		},
+		{
+			name:    "foreign signer ignored",
+			prepare: []vote{{"v-0", "v-0"}, {"outsider", "outsider"}},
+		},
+		{
+			name:    "vote signed with another key ignored",
+			prepare: []vote{{"v-0", "v-0"}, {"v-2", "v-3"}},
+		},
+		{
+			name:    "commit votes before prepare QC ignored",
+			prepare: []vote{{"v-0", "v-0"}},
+			commit:  []vote{{"v-0", "v-0"}, {"v-2", "v-2"}, {"v-3", "v-3"}},
+		},
+		{
+			name:    "commit below quorum",
+			prepare: []vote{{"v-0", "v-0"}, {"v-2", "v-2"}},
+			commit:  []vote{{"v-0", "v-0"}, {"v-0", "v-0"}, {"outsider", "outsider"}},
+			wantQC:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds, validators := testCreds(t, 4)
+			r, node := testConsensus(t, "v-1", creds, validators) // leader height 1 view 0
+
+			r.StartRound()
+			if r.proposal == nil {
+				t.Fatal("leader did not propose")
+			}
+			block := *r.proposal
+
+			for _, v := range tt.prepare {
+				r.HandleVote(signedVote(creds, v.nodeID, v.signer, types.VoteTypePrepare, block, 0))
+			}
+			qcs := sentPayloads[p2p.PrepareQCPayload](t, node, p2p.MsgTypePrepareQC)
+			if gotQC := len(qcs) > 0; gotQC != tt.wantQC {
+				t.Fatalf("prepare QC sent = %v, want %v", gotQC, tt.wantQC)
+			}
+			for _, qc := range qcs {
+				if err := r.verifyQC(qc.QC); err != nil {
+					t.Fatalf("leader sent invalid prepare QC: %v", err)
+				}
+			}
+
+			for _, v := range tt.commit {
+				r.HandleVote(signedVote(creds, v.nodeID, v.signer, types.VoteTypeCommit, block, 0))
+			}
+
+			if tt.wantSigners == nil {
+				if len(node.committed) != 0 {
+					t.Fatalf("block committed without quorum: %+v", node.committed[0].QC)
+				}
+				return
+			}
+			if len(node.committed) != 1 {
+				t.Fatalf("%d blocks committed, want 1", len(node.committed))
+			}
+			qc := node.committed[0].QC
+			if !slices.Equal(qc.Signers, tt.wantSigners) {
+				t.Fatalf("QC signers %v, want %v", qc.Signers, tt.wantSigners)
+			}
+			if err := r.verifyQC(qc); err != nil {
+				t.Fatalf("committed block has invalid QC: %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyQC(t *testing.T) {
+	creds, validators := testCreds(t, 4)
+	r, _ := testConsensus(t, "v-0", creds, validators)
+	block := testBlock(r.Node.GetLatestBlock(), "v-1", 0)
+
+	tests := []struct {
+		name  string
+		qc    func() types.QuorumCertificate
+		valid bool
+	}{
+		{"quorum", func() types.QuorumCertificate {
+			return signedQC(creds, types.VoteTypeCommit, block, 0, "v-0", "v-1", "v-2")
+		}, true},
+		{"all validators", func() types.QuorumCertificate {
+			return signedQC(creds, types.VoteTypeCommit, block, 0, "v-0", "v-1", "v-2", "v-3")
+		}, true},
+		{"below quorum", func() types.QuorumCertificate {
+			return signedQC(creds, types.VoteTypeCommit, block, 0, "v-0", "v-1")
+		}, false},
+		{"duplicate signer", func() types.QuorumCertificate {
+			qc := signedQC(creds, types.VoteTypeCommit, block, 0, "v-0", "v-1")
+			qc.Signers = append(qc.Signers, "v-1")
+			return qc
+		}, false},
+		{"foreign signer", func() types.QuorumCertificate {
+			return signedQC(creds, types.VoteTypeCommit, block, 0, "v-0", "v-1", "outsider")
+		}, false},
+		{"signature from another key", func() types.QuorumCertificate {
+			qc := signedQC(creds, types.VoteTypeCommit, block, 0, "v-0", "v-1", "v-2")
+			qc.Signatures["v-2"] = creds["v-3"].Sign(qc.SignBytes())
+			return qc
+		}, false},
+		{"signatures for another view", func() types.QuorumCertificate {
+			qc := signedQC(creds, types.VoteTypeCommit, block, 1, "v-0", "v-1", "v-2")
+			qc.View = 0
+			return qc
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := r.verifyQC(tt.qc())
+			if tt.valid && err != nil {
+				t.Fatalf("valid QC rejected: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Fatal("invalid QC accepted")
+			}
+		})
+	}
+}
+
+func TestLockedBlockAcrossViewChange(t *testing.T) {
+	// Height 1: leader view 0 = v-1, leader view 1 = v-2
+	tests := []struct {
+		name         string
+		node         string
+		ownLock      bool     // node menerima QC PREPARE block view 0 (locked)
+		carriedLock  []string // signer QC PREPARE lock yang dibawa view change v-1 (nil = tanpa lock)
+		wantReusable bool     // block view 0 yang dipakai di view 1
+	}{
+		{"new leader re-proposes own lock", "v-2", true, nil, true},
+		{"new leader adopts lock from view change", "v-2", false, []string{"v-0", "v-1", "v-3"}, true},
+		{"lock with invalid QC is dropped", "v-2", false, []string{"v-0", "v-1"}, false},
+		{"locked validator rejects conflicting block", "v-3", true, nil, true},
+		{"unlocked validator votes new block", "v-3", false, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds, validators := testCreds(t, 4)
+			r, node := testConsensus(t, tt.node, creds, validators)
+
+			locked := testBlock(node.latest, "v-1", 0)
+			r.HandleProposal(signedProposal(creds, "v-1", 0, locked))
+			if tt.ownLock {
+				r.HandlePrepareQC(signedQC(creds, types.VoteTypePrepare, locked, 0, "v-0", "v-1", "v-3"))
+				if r.lockedBlock == nil || r.lockedBlock.HeaderHash() != locked.HeaderHash() {
+					t.Fatal("prepare QC did not lock the block")
+				}
+			}
+
+			// View change dari validator lain sampai quorum
+			for _, validator := range validators {
+				if validator.ID == tt.node {
+					continue
+				}
+				vc := signedViewChange(creds, validator.ID, 1, 1)
+				if validator.ID == "v-1" && tt.carriedLock != nil {
+					qc := signedQC(creds, types.VoteTypePrepare, locked, 0, tt.carriedLock...)
+					vc.LockedBlock, vc.LockedQC = &locked, &qc
+				}
+				r.HandleViewChange(vc)
+			}
+			if r.view != 1 {
+				t.Fatalf("view %d after view change quorum, want 1", r.view)
+			}
+			if tt.ownLock && (r.lockedBlock == nil || r.lockedBlock.HeaderHash() != locked.HeaderHash()) {
+				t.Fatal("lock lost during view change")
+			}
+
+			// Leader view 1: proposal berisi block locked atau block baru
+			if tt.node == "v-2" {
+				proposals := sentPayloads[p2p.ProposalPayload](t, node, p2p.MsgTypeProposal)
+				if len(proposals) == 0 {
+					t.Fatal("new leader did not propose")
+				}
+				proposal := proposals[len(proposals)-1]
+				if proposal.View != 1 {
+					t.Fatalf("proposal view %d, want 1", proposal.View)
+				}
+				if reused := proposal.Block.HeaderHash() == locked.HeaderHash(); reused != tt.wantReusable {
+					t.Fatalf("re-proposed locked block = %v, want %v", reused, tt.wantReusable)
+				}
+				return
+			}
+
+			// Validator view 1: block baru dari leader hanya di vote jika tidak locked
+			votedInView := func(block types.Block) bool {
+				for _, vote := range sentPayloads[p2p.VotePayload](t, node, p2p.MsgTypeVote) {
+					if vote.View == 1 && vote.BlockHash == block.HeaderHash() {
+						return true
+					}
+				}
+				return false
+			}
+
+			conflicting := testBlock(node.latest, "v-2", 1)
+			r.HandleProposal(signedProposal(creds, "v-2", 1, conflicting))
+			if voted := votedInView(conflicting); voted == tt.wantReusable {
+				t.Fatalf("voted for conflicting block = %v", voted)
+			}
+
+			if tt.wantReusable {
+				r.HandleProposal(signedProposal(creds, "v-2", 1, locked))
+				if !votedInView(locked) {
+					t.Fatal("locked validator did not vote for re-proposed locked block")
+				}
+			}
+		})
+	}
+}
+
+func TestLeaderRotation(t *testing.T) {
+	creds, validators := testCreds(t, 4)
+
+	tests := []struct {
+		height uint64
+		view   uint64
+		want   string
+	}{
+		{1, 0, "v-1"},
+		{1, 1, "v-2"},
+		{1, 2, "v-3"},
+		{1, 3, "v-0"},
+		{1, 4, "v-1"},
+		{2, 0, "v-2"},
+		{2, 2, "v-0"},
+		{3, 1, "v-0"},
+		{4, 0, "v-0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("height %d view %d", tt.height, tt.view), func(t *testing.T) {
+			r, node := testConsensus(t, "v-0", creds, validators)
+			if got := r.getLeader(tt.height, tt.view); got != tt.want {
+				t.Fatalf("leader %s, want %s", got, tt.want)
+			}
+
+			// Block dari validator selain leader (height, view) ditolak
+			node.latest.Header.Height = tt.height - 1
+			for _, validator := range validators {
+				block := testBlock(node.latest, validator.ID, tt.view)
+				err := r.validateBlock(block)
+				if validator.ID == tt.want && err != nil {
+					t.Fatalf("block from leader rejected: %v", err)
+				}
+				if validator.ID != tt.want && err == nil {
+					t.Fatalf("block from %s accepted, leader is %s", validator.ID, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestProposalFromWrongLeaderRejected(t *testing.T) {
+	creds, validators := testCreds(t, 4)
+
+	tests := []struct {
+		name   string
+		leader string // penandatangan proposal
+		block  string // proposer di header block
+		voted  bool
+	}{
+		{"leader", "v-1", "v-1", true},
+		{"non leader signs own block", "v-2", "v-2", false},
+		{"non leader forwards leader block", "v-2", "v-1", false},
+		{"outsider", "outsider", "v-1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, node := testConsensus(t, "v-3", creds, validators)
+
+			r.HandleProposal(signedProposal(creds, tt.leader, 0, testBlock(node.latest, tt.block, 0)))
+			if voted := len(sentPayloads[p2p.VotePayload](t, node, p2p.MsgTypeVote)) > 0; voted != tt.voted {
+				t.Fatalf("voted = %v, want %v", voted, tt.voted)
+			}
+		})
+	}
+}
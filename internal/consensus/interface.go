@@ -7,10 +7,14 @@ import (
 
 // Interface penghubung node dan consensus
 type NodeInterface interface {
-	Broadcast(message p2p.Message) // mengirim broadcast ke semua peers
+	Broadcast(message p2p.Message)                 // mengirim broadcast ke semua peers
+	Send(peerID string, message p2p.Message) error // mengirim pesan ke satu peer (vote / proposal)
 	GetLatestBlock() types.Block
-	CreateBlock() types.Block      // membuat block proposal
-	CommitBlock(block types.Block) // mengcommit block ke blockchain & kirim ke light nodes
+	CreateBlock() types.Block                          // membuat block proposal
+	CommitBlock(block types.Block) error               // mengcommit block ke blockchain & kirim ke light nodes
+	CalculateRoots(block types.Block) (string, string) // state root & receipts root setelah block dieksekusi pada overlay world state
+	ValidateBlockTxs(block types.Block) error          // signature tx, id tx unik & nonce pengirim naik
 	IsValidator() bool
-	SignData(data []byte) string // mock signing data
+	Validators() []types.ValidatorConfig // validator set untuk height berikutnya
+	SignData(data []byte) string         // sign data dengan private key node (hex encoded)
 }
@@ -0,0 +1,175 @@
+package consensus
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bpjs-hackathon/sehat-chain/internal/p2p"
+	"github.com/bpjs-hackathon/sehat-chain/types"
+	"github.com/bpjs-hackathon/sehat-chain/utils"
+	"github.com/google/uuid"
+)
+
+// Pasang timeout untuk view saat ini. Tidak dipasang ulang jika
+// timer untuk height dan view yang sama masih berjalan
+func (r *RoundRobin) armTimer() {
+	height := r.Node.GetLatestBlock().Header.Height + 1
+	view := r.view
+
+	if r.timer != nil && r.timerHeight == height && r.timerView == view {
+		return
+	}
+
+	r.stopTimer()
+	r.timerHeight = height
+	r.timerView = view
+	r.timer = time.AfterFunc(RoundTimeout*time.Duration(view+1), func() {
+		r.onTimeout(height, view)
+	})
+}
+
+func (r *RoundRobin) stopTimer() {
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+}
+
+// Leader view saat ini tidak menghasilkan block tepat waktu, minta view berikutnya
+func (r *RoundRobin) onTimeout(height uint64, view uint64) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	// Timer sudah kadaluarsa (block sudah di commit atau view sudah berganti)
+	if r.Node.GetLatestBlock().Header.Height+1 != height || r.view != view {
+		return
+	}
+
+	r.timer = nil
+	fmt.Printf("⏰ Leader %s timed out at height %d view %d, requesting view change\n", r.getLeader(height, view), height, view)
+
+	vc := p2p.ViewChangePayload{
+		NodeID:      r.ID,
+		Height:      height,
+		View:        view + 1,
+		LockedBlock: r.lockedBlock,
+		LockedQC:    r.lockedQC,
+		Signature:   r.Node.SignData(types.ViewChangeSignBytes(height, view+1)),
+	}
+
+	payload, err := p2p.EncodePayload(vc)
+	if err != nil {
+		fmt.Printf("failed to marshal view change: %v\n", err)
+		return
+	}
+
+	r.sendToValidators(p2p.Message{
+		SenderID:  r.ID,
+		RequestID: uuid.NewString(),
+		Type:      p2p.MsgTypeViewChange,
+		Payload:   payload,
+	})
+
+	r.addViewChange(vc)
+}
+
+// Validator menerima permintaan view change dari validator lain
+func (r *RoundRobin) HandleViewChange(vc p2p.ViewChangePayload) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if !r.Node.IsValidator() {
+		return
+	}
+
+	validator, exists := r.validators[vc.NodeID]
+	if !exists {
+		fmt.Printf("ignoring view change from non validator %s\n", vc.NodeID)
+		return
+	}
+
+	if err := utils.Verify(validator.PublicKey, vc.Signature, types.ViewChangeSignBytes(vc.Height, vc.View)); err != nil {
+		fmt.Printf("invalid view change signature from %s: %v\n", vc.NodeID, err)
+		return
+	}
+
+	// Block locked hanya dipercaya jika membawa QC PREPARE yang valid
+	if vc.LockedBlock != nil {
+		if vc.LockedQC == nil || vc.LockedQC.VoteType != types.VoteTypePrepare ||
+			vc.LockedQC.HeaderHash != vc.LockedBlock.HeaderHash() || r.verifyQC(*vc.LockedQC) != nil {
+			fmt.Printf("view change from %s carries invalid locked block, dropping lock\n", vc.NodeID)
+			vc.LockedBlock = nil
+			vc.LockedQC = nil
+		}
+	}
+
+	r.addViewChange(vc)
+}
+
+// Catat view change dan pindah view jika sudah quorum
+func (r *RoundRobin) addViewChange(vc p2p.ViewChangePayload) {
+	height := r.Node.GetLatestBlock().Header.Height + 1
+	if vc.Height != height || vc.View <= r.view {
+		return
+	}
+
+	if _, exists := r.viewChanges[vc.View]; !exists {
+		r.viewChanges[vc.View] = make(map[string]p2p.ViewChangePayload)
+	}
+	r.viewChanges[vc.View][vc.NodeID] = vc
+
+	if len(r.viewChanges[vc.View]) >= r.quorumSize() {
+		r.enterView(vc.View)
+	}
+}
+
+// Pindah ke view baru. Leader view baru langsung membuat proposal,
+// memprioritaskan block locked dengan QC PREPARE paling tinggi
+func (r *RoundRobin) enterView(view uint64) {
+	height := r.Node.GetLatestBlock().Header.Height + 1
+
+	fmt.Printf("🔁 Entering view %d at height %d, new leader %s\n", view, height, r.getLeader(height, view))
+
+	locked := r.highestLocked(height, view)
+
+	r.view = view
+	r.resetRound(nil)
+	r.armTimer()
+
+	for v := range r.viewChanges {
+		if v <= view {
+			delete(r.viewChanges, v)
+		}
+	}
+
+	if r.getLeader(height, view) == r.ID {
+		r.propose(locked)
+		return
+	}
+
+	// Proposal view ini mungkin sudah datang sebelum kita mencapai quorum view change
+	if r.pendingProposal != nil && r.pendingProposal.View == view {
+		proposal := *r.pendingProposal
+		r.pendingProposal = nil
+		r.handleProposal(proposal)
+	}
+}
+
+// Block locked dengan QC PREPARE paling tinggi dari pesan view change dan lock kita sendiri
+func (r *RoundRobin) highestLocked(height uint64, view uint64) *types.Block {
+	locked := r.lockedBlock
+	lockedQC := r.lockedQC
+
+	for _, vc := range r.viewChanges[view] {
+		if vc.LockedBlock == nil || vc.LockedBlock.Header.Height != height {
+			continue
+		}
+
+		if lockedQC == nil || vc.LockedQC.View > lockedQC.View {
+			locked = vc.LockedBlock
+			lockedQC = vc.LockedQC
+		}
+	}
+
+	return locked
+}
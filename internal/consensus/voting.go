@@ -0,0 +1,175 @@
+package consensus
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/bpjs-hackathon/sehat-chain/internal/p2p"
+	"github.com/bpjs-hackathon/sehat-chain/types"
+	"github.com/bpjs-hackathon/sehat-chain/utils"
+	"github.com/google/uuid"
+)
+
+// Jumlah minimal signature agar lebih dari 2/3 validator setuju
+func (r *RoundRobin) quorumSize() int {
+	return types.QuorumSize(len(r.validatorsSort))
+}
+
+// Tandatangani block dan kirim vote ke leader (atau langsung diproses jika kita leader)
+func (r *RoundRobin) castVote(voteType string, block types.Block) {
+	hash := block.HeaderHash()
+	vote := p2p.VotePayload{
+		NodeID:      r.ID,
+		BlockHeight: block.Header.Height,
+		View:        r.view,
+		BlockHash:   hash,
+		VoteType:    voteType,
+		Signature:   r.Node.SignData(types.VoteSignBytes(voteType, block.Header.Height, r.view, hash)),
+	}
+
+	// Vote dikirim ke leader view saat ini (bisa berbeda dari pembuat block jika block di propose ulang)
+	leader := r.getLeader(block.Header.Height, r.view)
+	if leader == r.ID {
+		r.addVote(vote)
+		return
+	}
+
+	payload, err := p2p.EncodePayload(vote)
+	if err != nil {
+		fmt.Printf("failed to marshal vote: %v\n", err)
+		return
+	}
+
+	err = r.Node.Send(leader, p2p.Message{
+		SenderID:  r.ID,
+		RequestID: uuid.NewString(),
+		Type:      p2p.MsgTypeVote,
+		Payload:   payload,
+	})
+	if err != nil {
+		fmt.Printf("failed to send %s vote to leader %s: %v\n", voteType, leader, err)
+	}
+}
+
+// Leader mencatat vote. Jika quorum PREPARE tercapai, QC PREPARE disebar.
+// Jika quorum COMMIT tercapai, block di commit dengan QC COMMIT
+func (r *RoundRobin) addVote(vote p2p.VotePayload) {
+	if r.proposal == nil || r.getLeader(r.proposal.Header.Height, r.view) != r.ID {
+		return
+	}
+
+	hash := r.proposal.HeaderHash()
+	if vote.BlockHash != hash || vote.BlockHeight != r.proposal.Header.Height || vote.View != r.view {
+		fmt.Printf("ignoring vote from %s for unknown block %s\n", vote.NodeID, vote.BlockHash)
+		return
+	}
+
+	validator, exists := r.validators[vote.NodeID]
+	if !exists {
+		fmt.Printf("ignoring vote from non validator %s\n", vote.NodeID)
+		return
+	}
+
+	signBytes := types.VoteSignBytes(vote.VoteType, vote.BlockHeight, vote.View, vote.BlockHash)
+	if err := utils.Verify(validator.PublicKey, vote.Signature, signBytes); err != nil {
+		fmt.Printf("invalid %s vote signature from %s: %v\n", vote.VoteType, vote.NodeID, err)
+		return
+	}
+
+	switch vote.VoteType {
+	case types.VoteTypePrepare:
+		r.prepareVotes[vote.NodeID] = vote.Signature
+		if r.prepareQCSent || len(r.prepareVotes) < r.quorumSize() {
+			return
+		}
+
+		r.prepareQCSent = true
+		qc := r.buildQC(types.VoteTypePrepare, *r.proposal, r.prepareVotes)
+
+		payload, err := p2p.EncodePayload(p2p.PrepareQCPayload{QC: qc})
+		if err != nil {
+			fmt.Printf("failed to marshal prepare QC: %v\n", err)
+			return
+		}
+
+		r.sendToValidators(p2p.Message{
+			SenderID:  r.ID,
+			RequestID: uuid.NewString(),
+			Type:      p2p.MsgTypePrepareQC,
+			Payload:   payload,
+		})
+
+		// Leader juga lock block sebelum vote COMMIT
+		locked := *r.proposal
+		r.lockedBlock = &locked
+		r.lockedQC = &qc
+
+		r.castVote(types.VoteTypeCommit, *r.proposal)
+	case types.VoteTypeCommit:
+		// Vote COMMIT hanya berlaku setelah QC PREPARE terbentuk
+		if !r.prepareQCSent {
+			return
+		}
+
+		r.commitVotes[vote.NodeID] = vote.Signature
+		if len(r.commitVotes) < r.quorumSize() {
+			return
+		}
+
+		block := *r.proposal
+		block.QC = r.buildQC(types.VoteTypeCommit, block, r.commitVotes)
+
+		fmt.Printf("🗳️ Block %d reached commit quorum in view %d (%d/%d)\n", block.Header.Height, r.view, len(block.QC.Signers), len(r.validatorsSort))
+		r.commit(block)
+	default:
+		fmt.Printf("unknown vote type %s from %s\n", vote.VoteType, vote.NodeID)
+	}
+}
+
+// Agregasi vote menjadi quorum certificate
+func (r *RoundRobin) buildQC(voteType string, block types.Block, votes map[string]string) types.QuorumCertificate {
+	signers := make([]string, 0, len(votes))
+	signatures := make(map[string]string, len(votes))
+	for id, signature := range votes {
+		signers = append(signers, id)
+		signatures[id] = signature
+	}
+	sort.Strings(signers)
+
+	return types.QuorumCertificate{
+		HeaderHash: block.HeaderHash(),
+		Height:     block.Header.Height,
+		View:       r.view,
+		VoteType:   voteType,
+		Signers:    signers,
+		Signatures: signatures,
+	}
+}
+
+// Verifikasi QC ditandatangani oleh > 2/3 validator yang berbeda
+func (r *RoundRobin) verifyQC(qc types.QuorumCertificate) error {
+	signBytes := qc.SignBytes()
+	seen := make(map[string]bool)
+
+	for _, signer := range qc.Signers {
+		if seen[signer] {
+			return fmt.Errorf("duplicate signer %s", signer)
+		}
+		seen[signer] = true
+
+		validator, exists := r.validators[signer]
+		if !exists {
+			return fmt.Errorf("signer %s is not a validator", signer)
+		}
+
+		if err := utils.Verify(validator.PublicKey, qc.Signatures[signer], signBytes); err != nil {
+			return fmt.Errorf("invalid signature from %s: %v", signer, err)
+		}
+	}
+
+	if len(seen) < r.quorumSize() {
+		return fmt.Errorf("not enough signatures, has %d but need %d", len(seen), r.quorumSize())
+	}
+
+	return nil
+}
@@ -4,11 +4,15 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
+	"github.com/bpjs-hackathon/sehat-chain/internal/p2p"
+	"github.com/bpjs-hackathon/sehat-chain/internal/state"
 	"github.com/bpjs-hackathon/sehat-chain/types"
 	"github.com/google/uuid"
 )
@@ -30,8 +34,10 @@ func (node *Node) TestTx() {
 		SenderID:  node.ID,
 		Payload:   visitJson,
 	}
-	tx.Signature = node.SignData([]byte(tx.Hash()))
-	node.submitTransactionToNetwork(tx)
+	if err := node.signAndSubmit(&tx); err != nil {
+		fmt.Printf("fake tx rejected: %v\n", err)
+		return
+	}
 	fmt.Printf("created a fake tx of id %s\n", visitPayload.RekamMedisID)
 }
 
@@ -63,8 +69,10 @@ func (node *Node) handleFK1RekamMedisPost(w http.ResponseWriter, r *http.Request
 		SenderID:  node.ID,
 		Payload:   visitJson,
 	}
-	tx.Signature = node.SignData([]byte(tx.Hash()))
-	node.submitTransactionToNetwork(tx)
+	if err := node.signAndSubmit(&tx); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 
 	// Tidak perlu buat rujukan jika pasien sembuh di FK1
 	if reqData.Outcome == "SEMBUH" {
@@ -75,7 +83,7 @@ func (node *Node) handleFK1RekamMedisPost(w http.ResponseWriter, r *http.Request
 	txPayload := types.TxRujukan{
 		RujukanID:       rujukanID,
 		PesertaID:       reqData.PesertaNIK,
-		FaskesPembuatID: "58c22b9f-6e2a-49ff-9dd4-d9e7c0927974",
+		FaskesPembuatID: node.ID, // rujukan hanya bisa dibuat oleh faskes pengirim tx
 		FaskesTujuanID:  "85516c8a-688b-4123-b880-e1c829692c88",
 		RekamMedisID:    reqData.RekamMedisID,
 		RekamMedisHash:  rmHash,
@@ -90,9 +98,10 @@ func (node *Node) handleFK1RekamMedisPost(w http.ResponseWriter, r *http.Request
 		SenderID:  node.ID,
 		Payload:   txJson,
 	}
-	tx.Signature = node.SignData([]byte(tx.Hash()))
-
-	node.submitTransactionToNetwork(tx)
+	if err := node.signAndSubmit(&tx); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 
 	response := FK1RMSubmitResponse{
 		RujukanID: rujukanID,
@@ -128,16 +137,22 @@ func (node *Node) handleFK2RekamMedisPost(w http.ResponseWriter, r *http.Request
 		SenderID:  node.ID,
 		Payload:   visitJson,
 	}
-	tx.Signature = node.SignData([]byte(tx.Hash()))
-	node.submitTransactionToNetwork(tx)
+	if err := node.signAndSubmit(&tx); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 
 	// FK2 otomatis membuat rekaman claim
 	txPayload := types.TxSubmitClaim{
 		ClaimID:        reqData.ClaimID,
 		RujukanID:      reqData.RujukanID,
+		PesertaID:      reqData.PesertaNIK,
 		RekamMedisID:   reqData.RekamMedisID,
 		RekamMedisHash: rmHash,
 		DiagnosisCode:  reqData.DiagnosisCode,
+		ProcedureCodes: reqData.ProcedureCodes,
+		CareClass:      reqData.CareClass,
+		LengthOfStay:   lengthOfStay(reqData.AdmissionDate, reqData.DischargeDate),
 		Amount:         reqData.Amount,
 	}
 
@@ -150,14 +165,44 @@ func (node *Node) handleFK2RekamMedisPost(w http.ResponseWriter, r *http.Request
 		SenderID:  node.ID,
 		Payload:   txJson,
 	}
-	tx.Signature = node.SignData([]byte(tx.Hash()))
-
-	node.submitTransactionToNetwork(tx)
+	if err := node.signAndSubmit(&tx); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 
 	// Send empty header if OK
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// Faskes pembuat membatalkan rujukan yang belum dipakai
+func (node *Node) handleRujukanCancel(w http.ResponseWriter, r *http.Request) {
+	var reqData CancelRujukan
+	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	burnPayload := types.TxBurnRujukan{
+		RujukanID: r.PathValue("id"),
+		Reason:    reqData.Reason,
+	}
+	burnJson, _ := json.Marshal(burnPayload)
+
+	tx := types.Transaction{
+		ID:        uuid.NewString(),
+		Type:      types.TxTypeRedeemRujukan,
+		Timestamp: time.Now().Unix(),
+		SenderID:  node.ID,
+		Payload:   burnJson,
+	}
+	if err := node.signAndSubmit(&tx); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (node *Node) handleClaimExecute(w http.ResponseWriter, r *http.Request) {
 	var reqData ExecuteClaim
 
@@ -180,13 +225,156 @@ func (node *Node) handleClaimExecute(w http.ResponseWriter, r *http.Request) {
 		SenderID:  node.ID,
 		Payload:   executeJson,
 	}
-	tx.Signature = node.SignData([]byte(tx.Hash()))
+	if err := node.signAndSubmit(&tx); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 
-	node.submitTransactionToNetwork(tx)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Admin BPJS mengganti versi tabel tarif INA-CBG yang dipakai chain
+func (node *Node) handleTariffVersionSet(w http.ResponseWriter, r *http.Request) {
+	var reqData SetTariffVersion
+
+	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if reqData.Hash == "" {
+		engine, exists := node.Executor.Tariffs.Get(reqData.Version)
+		if !exists {
+			http.Error(w, fmt.Sprintf("tariff table version %q is not loaded, hash is required", reqData.Version), http.StatusBadRequest)
+			return
+		}
+		reqData.Hash = engine.Hash()
+	}
+
+	versionJson, _ := json.Marshal(types.TxSetTariffVersion{Version: reqData.Version, Hash: reqData.Hash})
+
+	tx := types.Transaction{
+		ID:        uuid.NewString(),
+		Type:      types.TxTypeSetTariffVersion,
+		Timestamp: time.Now().Unix(),
+		SenderID:  node.ID,
+		Payload:   versionJson,
+	}
+	if err := node.signAndSubmit(&tx); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// Data validator yang disetujui. Untuk REMOVE_VALIDATOR diambil dari validator set aktif
+func (node *Node) validatorChangeTarget(reqData ValidatorChangeRequest) (types.ValidatorConfig, error) {
+	switch reqData.Action {
+	case types.TxTypeAddValidator:
+		return types.ValidatorConfig{
+			ID:        reqData.ValidatorID,
+			PublicKey: reqData.PublicKey,
+			Address:   reqData.Address,
+		}, nil
+	case types.TxTypeRemoveValidator:
+		validator, exists := node.WorldState.GetValidator(reqData.ValidatorID)
+		if !exists {
+			return types.ValidatorConfig{}, fmt.Errorf("%s is not a validator", reqData.ValidatorID)
+		}
+		return validator, nil
+	}
+
+	return types.ValidatorConfig{}, fmt.Errorf("unknown action %s", reqData.Action)
+}
+
+// Validator ini menandatangani perubahan validator set, signature dikumpulkan oleh pengaju
+func (node *Node) handleValidatorChangeSign(w http.ResponseWriter, r *http.Request) {
+	var reqData ValidatorChangeRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if !node.IsValidator() {
+		http.Error(w, "node is not a validator", http.StatusForbidden)
+		return
+	}
+
+	validator, err := node.validatorChangeTarget(reqData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	payload := ValidatorChangeApproval{
+		ValidatorID: node.ID,
+		Signature:   node.SignData(types.ValidatorChangeSignBytes(reqData.Action, validator, reqData.EffectiveHeight)),
+	}
+	payloadJson, _ := json.Marshal(payload)
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(payloadJson)
+}
+
+// Ajukan tx governance beserta approval yang sudah dikumpulkan
+func (node *Node) handleValidatorChange(w http.ResponseWriter, r *http.Request) {
+	var reqData ValidatorChangeRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	validator, err := node.validatorChangeTarget(reqData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	changeJson, _ := json.Marshal(types.TxValidatorChange{
+		Validator:       validator,
+		EffectiveHeight: reqData.EffectiveHeight,
+		Approvals:       reqData.Approvals,
+	})
+
+	tx := types.Transaction{
+		ID:        uuid.NewString(),
+		Type:      reqData.Action,
+		Timestamp: time.Now().Unix(),
+		SenderID:  node.ID,
+		Payload:   changeJson,
+	}
+	if err := node.signAndSubmit(&tx); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte(tx.ID))
+}
+
+func (node *Node) handleAPIValidators(w http.ResponseWriter, _ *http.Request) {
+	payload := ValidatorSetResponse{
+		Height:     node.Blockchain.GetLatestHeight(),
+		Validators: node.WorldState.GetValidators(),
+		Pending:    node.WorldState.GetValidatorChanges(),
+	}
+	payloadJson, _ := json.Marshal(payload)
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(payloadJson)
+}
+
+// Lama rawat dalam hari (dibulatkan ke atas), minimal 1 hari
+func lengthOfStay(admission int64, discharge int64) int {
+	if admission <= 0 || discharge <= admission {
+		return 1
+	}
+	return int((discharge - admission + 86399) / 86400)
+}
+
 func (node *Node) handleBlockTotalReq(w http.ResponseWriter, _ *http.Request) {
 	type BlockCount struct {
 		Count uint64 `json:"count"`
@@ -246,15 +434,8 @@ func (node *Node) handleAPIRequestRujukan(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	var rujukan types.RujukanAsset
-	for _, rujuk := range node.WorldState.Rujukans {
-		if rujuk.ID == reqID {
-			rujukan = rujuk
-			break
-		}
-	}
-
-	if rujukan.ID == "" {
+	rujukan, exists := node.WorldState.GetRujukan(reqID)
+	if !exists {
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
@@ -268,6 +449,284 @@ func (node *Node) handleAPIRequestRujukan(w http.ResponseWriter, r *http.Request
 	w.Write(payloadJson)
 }
 
+// Daftar claim dengan filter faskes, status, dan rentang waktu submit (unix detik)
+func (node *Node) handleAPIClaims(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	offset, limit, err := parsePagination(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter := state.ClaimFilter{
+		FaskesID: query.Get("faskes"),
+		Status:   query.Get("status"),
+	}
+	if filter.From, err = parseTimeParam(query, "from"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.To, err = parseTimeParam(query, "to"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	claims, total := node.WorldState.QueryClaims(filter, offset, limit)
+
+	payload := ClaimListResponse{
+		Claims: claims,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	}
+	payloadJson, _ := json.Marshal(payload)
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(payloadJson)
+}
+
+func (node *Node) handleAPIClaimByID(w http.ResponseWriter, r *http.Request) {
+	claim, exists := node.WorldState.GetClaim(r.PathValue("id"))
+	if !exists {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	payload := GetClaimInfo{
+		ClaimAsset: claim,
+	}
+	payloadJson, _ := json.Marshal(payload)
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(payloadJson)
+}
+
+// Rujukan milik peserta (NIK) urut waktu dibuat
+func (node *Node) handleAPIPesertaRujukan(w http.ResponseWriter, r *http.Request) {
+	offset, limit, err := parsePagination(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rujukans, total := node.WorldState.GetRujukansByPeserta(r.PathValue("nik"), offset, limit)
+
+	payload := RujukanListResponse{
+		Rujukans: rujukans,
+		Total:    total,
+		Limit:    limit,
+		Offset:   offset,
+	}
+	payloadJson, _ := json.Marshal(payload)
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(payloadJson)
+}
+
+// Visit beserta claim yang memakai rekam medis tersebut
+func (node *Node) handleAPIVisit(w http.ResponseWriter, r *http.Request) {
+	rekamMedisID := r.PathValue("rekam_medis_id")
+
+	visit, exists := node.WorldState.GetVisit(rekamMedisID)
+	if !exists {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	payload := VisitResponse{
+		TxVisit: visit,
+		Claims:  node.WorldState.GetClaimsByRekamMedis(rekamMedisID),
+	}
+	payloadJson, _ := json.Marshal(payload)
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(payloadJson)
+}
+
+// Parameter offset & limit (default DefaultQueryLimit, maksimal MaxQueryLimit)
+func parsePagination(query url.Values) (int, int, error) {
+	offset, limit := 0, DefaultQueryLimit
+
+	if offsetStr := query.Get("offset"); offsetStr != "" {
+		parsed, err := strconv.Atoi(offsetStr)
+		if err != nil || parsed < 0 {
+			return 0, 0, fmt.Errorf("invalid offset %q", offsetStr)
+		}
+		offset = parsed
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 {
+			return 0, 0, fmt.Errorf("invalid limit %q", limitStr)
+		}
+		limit = min(parsed, MaxQueryLimit)
+	}
+
+	return offset, limit, nil
+}
+
+// Parameter waktu dalam unix detik, 0 jika kosong
+func parseTimeParam(query url.Values, key string) (int64, error) {
+	value := query.Get(key)
+	if value == "" {
+		return 0, nil
+	}
+
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, value)
+	}
+	return parsed, nil
+}
+
+// Merkle proof asset (rujukan / claim / visit) pada height tertentu (default: height terakhir)
+func (node *Node) handleAPIStateProof(w http.ResponseWriter, r *http.Request) {
+	namespace := r.PathValue("namespace")
+	id := r.PathValue("id")
+
+	switch namespace {
+	case state.NamespaceRujukan, state.NamespaceClaim, state.NamespaceVisit:
+	default:
+		http.Error(w, "unknown asset type", http.StatusBadRequest)
+		return
+	}
+
+	height := node.Blockchain.GetLatestHeight()
+	if heightStr := r.URL.Query().Get("height"); heightStr != "" {
+		parsed, err := strconv.ParseUint(heightStr, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid height", http.StatusBadRequest)
+			return
+		}
+		height = parsed
+	}
+
+	if height > node.Blockchain.GetLatestHeight() {
+		http.Error(w, "height is higher than current stored", http.StatusBadRequest)
+		return
+	}
+
+	value, stateRoot, proof, err := node.WorldState.Prove(namespace, id, height)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	payload := StateProofResponse{
+		Height:    height,
+		StateRoot: stateRoot,
+		Found:     value != nil,
+		Value:     value,
+		Proof:     proof,
+	}
+	payloadJson, _ := json.Marshal(payload)
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(payloadJson)
+}
+
+// Receipt tx yang sudah masuk block
+func (node *Node) handleAPITxReceipt(w http.ResponseWriter, r *http.Request) {
+	txID := r.PathValue("id")
+
+	receipt, err := node.Blockchain.GetReceipt(txID)
+	if errors.Is(err, ErrTxNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	payload := TxReceiptResponse{
+		Receipt: receipt,
+	}
+	payloadJson, _ := json.Marshal(payload)
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(payloadJson)
+}
+
+func (node *Node) handleAPITxLookup(w http.ResponseWriter, r *http.Request) {
+	txID := r.PathValue("id")
+	payload := TxLookupResponse{Status: TxStatusUnknown}
+
+	tx, block, location, err := node.Blockchain.GetTx(txID)
+	switch {
+	case err == nil:
+		proof, err := types.BuildTxProof(block.Transactions, location.Index)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		payload = TxLookupResponse{
+			Status:      TxStatusCommitted,
+			Transaction: &tx,
+			BlockHeight: location.Height,
+			TxIndex:     location.Index,
+			TxHash:      tx.Hash(),
+			TxRoot:      block.Header.TxRoot,
+			Proof:       &proof,
+		}
+	case !errors.Is(err, ErrTxNotFound):
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	default:
+		if pending, exists := node.Mempool.Get(txID); exists {
+			payload.Status = TxStatusPending
+			payload.Transaction = &pending
+		}
+	}
+
+	payloadJson, _ := json.Marshal(payload)
+
+	// Tx yang tidak dikenal tetap dibalas dengan status UNKNOWN
+	if payload.Status == TxStatusUnknown {
+		w.WriteHeader(http.StatusNotFound)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.Write(payloadJson)
+}
+
+func (node *Node) handleAPIPeers(w http.ResponseWriter, _ *http.Request) {
+	peers := node.P2P.PeerStatuses()
+
+	// Validator yang sedang terputus tetap ditampilkan
+	connected := make(map[string]bool)
+	for _, peer := range peers {
+		connected[peer.ID] = true
+	}
+	for _, validator := range node.Validators() {
+		if validator.ID != node.ID && !connected[validator.ID] {
+			peers = append(peers, p2p.PeerStatus{ID: validator.ID, Address: validator.Address})
+		}
+	}
+
+	payload := PeersResponse{
+		Peers:       peers,
+		AddressBook: node.AddressBook.Entries(),
+	}
+	payloadJson, _ := json.Marshal(payload)
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(payloadJson)
+}
+
+func (node *Node) handleAPIRejectedTxs(w http.ResponseWriter, _ *http.Request) {
+	payload := RejectedTxStats{
+		Rejected: node.RejectedTxStats(),
+	}
+	payloadJson, _ := json.Marshal(payload)
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(payloadJson)
+}
+
 func (node *Node) handleAPIPing(w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusAccepted)
 	w.Write([]byte("STATUS OK!"))
@@ -1,6 +1,12 @@
 package core
 
-import "github.com/bpjs-hackathon/sehat-chain/types"
+import (
+	"encoding/json"
+
+	"github.com/bpjs-hackathon/sehat-chain/internal/p2p"
+	"github.com/bpjs-hackathon/sehat-chain/internal/state"
+	"github.com/bpjs-hackathon/sehat-chain/types"
+)
 
 type RekamMedis struct {
 	RekamMedisID  string `json:"id"`
@@ -42,7 +48,9 @@ type Claim struct {
 
 type FK2SubmitRequest struct {
 	RekamMedis
-	RujukanID string `json:"rujukan_id"`
+	RujukanID      string   `json:"rujukan_id"`
+	ProcedureCodes []string `json:"procedure_codes"` // ICD-9-CM
+	CareClass      string   `json:"care_class"`      // kelas rawat 1 / 2 / 3
 	Claim
 }
 
@@ -53,12 +61,58 @@ type GetRujukanInfo struct {
 	types.RujukanAsset
 }
 
+// /// /// /// /// /// /// ///
+// Faskes 1 Batalkan Rujukan //
+// /// /// /// /// /// /// ///
+type CancelRujukan struct {
+	Reason string `json:"reason"`
+}
+
 // /// /// /// /// /// ///
 // Admin Execute Claim  //
 // /// /// /// /// /// ///
 type ExecuteClaim struct {
 	Claim
-	Status string `json:"status"` // PAID or REJECTED
+	Status string `json:"status"` // APPROVED, PAID, REJECTED or FAKED
+}
+
+// /// /// /// /// /// /// /// //
+// Admin Ganti Versi Tarif     //
+// /// /// /// /// /// /// /// //
+type SetTariffVersion struct {
+	Version string `json:"version"`
+	Hash    string `json:"hash,omitempty"` // default: hash tabel versi tersebut di node ini
+}
+
+// /// /// /// /// /// /// /// /// //
+// Governance Tambah/Hapus Validator //
+// /// /// /// /// /// /// /// /// //
+type ValidatorChangeRequest struct {
+	Action          string            `json:"action"` // ADD_VALIDATOR or REMOVE_VALIDATOR
+	ValidatorID     string            `json:"validator_id"`
+	PublicKey       string            `json:"public_key,omitempty"` // wajib untuk ADD_VALIDATOR
+	Address         string            `json:"address,omitempty"`    // wajib untuk ADD_VALIDATOR
+	EffectiveHeight uint64            `json:"effective_height"`
+	Approvals       map[string]string `json:"approvals,omitempty"` // id validator -> signature
+}
+
+type ValidatorChangeApproval struct {
+	ValidatorID string `json:"validator_id"`
+	Signature   string `json:"signature"`
+}
+
+type ValidatorSetResponse struct {
+	Height     uint64                  `json:"height"`
+	Validators []types.ValidatorConfig `json:"validators"`
+	Pending    []types.ValidatorChange `json:"pending"`
+}
+
+// /// /// /// /// /// /// /// //
+// Status Koneksi Peer         //
+// /// /// /// /// /// /// /// //
+type PeersResponse struct {
+	Peers       []p2p.PeerStatus   `json:"peers"`
+	AddressBook []p2p.AddressEntry `json:"address_book"`
 }
 
 // /// /// /// /// /// /// ///  //
@@ -67,3 +121,76 @@ type ExecuteClaim struct {
 type GetClaimInfo struct {
 	types.ClaimAsset
 }
+
+// /// /// /// /// /// /// /// //
+// Statistik Tx Yang Ditolak   //
+// /// /// /// /// /// /// /// //
+type RejectedTxStats struct {
+	Rejected map[string]uint64 `json:"rejected"` // jumlah per alasan
+}
+
+// /// /// /// /// /// /// /// /// //
+// Merkle Proof Asset Pada Height  //
+// /// /// /// /// /// /// /// /// //
+type StateProofResponse struct {
+	Height    uint64            `json:"height"`
+	StateRoot string            `json:"state_root"` // sama dengan state_root pada header block di height ini
+	Found     bool              `json:"found"`
+	Value     json.RawMessage   `json:"value,omitempty"` // asset yang dibuktikan (json yang di hash sebagai leaf)
+	Proof     state.MerkleProof `json:"proof"`
+}
+
+// /// /// /// /// /// /// //
+// Receipt Eksekusi Tx     //
+// /// /// /// /// /// /// //
+type TxReceiptResponse struct {
+	types.Receipt
+}
+
+// /// /// /// /// /// /// /// /// //
+// Status & Inclusion Proof Tx     //
+// /// /// /// /// /// /// /// /// //
+const (
+	TxStatusPending   = "PENDING"   // masih di mempool node ini
+	TxStatusCommitted = "COMMITTED" // sudah masuk block
+	TxStatusUnknown   = "UNKNOWN"
+)
+
+type TxLookupResponse struct {
+	Status      string             `json:"status"`
+	Transaction *types.Transaction `json:"transaction,omitempty"`
+
+	// Hanya untuk tx COMMITTED. Leaf proof adalah hash tx (tx_hash)
+	BlockHeight uint64             `json:"block_height,omitempty"`
+	TxIndex     int                `json:"tx_index"`
+	TxHash      string             `json:"tx_hash,omitempty"`
+	TxRoot      string             `json:"tx_root,omitempty"` // sama dengan tx_root pada header block
+	Proof       *types.MerkleProof `json:"proof,omitempty"`
+}
+
+// /// /// /// /// /// /// /// /// //
+// Query Claim / Rujukan / Visit   //
+// /// /// /// /// /// /// /// /// //
+const (
+	DefaultQueryLimit = 50
+	MaxQueryLimit     = 500
+)
+
+type ClaimListResponse struct {
+	Claims []types.ClaimAsset `json:"claims"`
+	Total  int                `json:"total"` // jumlah claim sesuai filter sebelum pagination
+	Limit  int                `json:"limit"`
+	Offset int                `json:"offset"`
+}
+
+type RujukanListResponse struct {
+	Rujukans []types.RujukanAsset `json:"rujukans"`
+	Total    int                  `json:"total"`
+	Limit    int                  `json:"limit"`
+	Offset   int                  `json:"offset"`
+}
+
+type VisitResponse struct {
+	types.TxVisit
+	Claims []types.ClaimAsset `json:"claims"` // claim yang memakai rekam medis ini
+}
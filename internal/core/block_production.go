@@ -0,0 +1,79 @@
+// Produksi block berdasarkan interval waktu atau ukuran pool
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	DefaultBlockInterval = 1 * time.Second
+	DefaultMaxBlockTxs   = 500
+	DefaultMaxBlockBytes = 1 << 20 // harus jauh di bawah p2p.MaxFrameSize
+)
+
+// Nilai 0 diganti default
+type BlockProductionConfig struct {
+	IntervalMillis    int64 `json:"interval_ms"`         // jeda antar proposal
+	MaxBlockTxs       int   `json:"max_block_txs"`       // proposal langsung dibuat jika tx pending mencapai batas ini
+	MaxBlockBytes     int   `json:"max_block_bytes"`     // sama seperti MaxBlockTxs tapi dalam ukuran byte tx
+	CreateEmptyBlocks bool  `json:"create_empty_blocks"` // false = tidak membuat block saat pool kosong
+}
+
+func (config BlockProductionConfig) withDefaults() BlockProductionConfig {
+	if config.IntervalMillis <= 0 {
+		config.IntervalMillis = DefaultBlockInterval.Milliseconds()
+	}
+	if config.MaxBlockTxs <= 0 {
+		config.MaxBlockTxs = DefaultMaxBlockTxs
+	}
+	if config.MaxBlockBytes <= 0 {
+		config.MaxBlockBytes = DefaultMaxBlockBytes
+	}
+	return config
+}
+
+// Validator memulai round setiap interval atau segera setelah pool melewati budget block.
+// Hanya leader yang membuat proposal, validator lain memasang timeout view change
+func (node *Node) runBlockProduction() {
+	interval := time.Duration(node.blockConfig.IntervalMillis) * time.Millisecond
+	fmt.Printf("⛏️ Block production every %s (max %d txs / %d bytes, empty blocks %t)\n",
+		interval, node.blockConfig.MaxBlockTxs, node.blockConfig.MaxBlockBytes, node.blockConfig.CreateEmptyBlocks)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-node.poolFull:
+		}
+
+		node.produceBlock()
+	}
+}
+
+func (node *Node) produceBlock() {
+	if !node.IsValidator() || node.syncing.Load() {
+		return
+	}
+
+	// Empty block suppression
+	if node.Mempool.Size() == 0 && !node.blockConfig.CreateEmptyBlocks {
+		return
+	}
+
+	node.Consensus.StartRound()
+}
+
+// Dipanggil setelah tx masuk pool, proposal tidak menunggu interval jika budget block sudah terpenuhi
+func (node *Node) notifyPendingTxs() {
+	if node.Mempool.Size() < node.blockConfig.MaxBlockTxs && node.Mempool.Bytes() < node.blockConfig.MaxBlockBytes {
+		return
+	}
+
+	select {
+	case node.poolFull <- struct{}{}:
+	default:
+	}
+}
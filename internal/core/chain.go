@@ -2,61 +2,103 @@
 package core
 
 import (
-	"encoding/hex"
+	"errors"
 	"fmt"
-	"strings"
+	"path/filepath"
 	"sync"
-	"time"
 
+	"github.com/bpjs-hackathon/sehat-chain/internal/store"
 	"github.com/bpjs-hackathon/sehat-chain/types"
 )
 
+var (
+	ErrTxNotFound  = errors.New("tx not found in chain")
+	ErrTxDuplicate = errors.New("duplicate tx")
+)
+
 type Blockchain struct {
-	Blocks []types.Block
-	mux    sync.RWMutex
+	store   *store.BlockStore // block tersimpan di disk (append-only)
+	txIndex *store.TxIndex    // id tx yang sudah masuk chain -> height & urutan di block
+	latest  types.Block       // cache block terakhir
+	mux     sync.RWMutex
 }
 
-// Buat instance blockchain dengan genesis block yang di hardcode
-func InitializeBlockChain() *Blockchain {
-	hash := []byte(strings.Repeat("0", 64)) // mengikuti standar All Zeroes sebagai hash untuk genesis block
-	hashStr := hex.EncodeToString(hash[:])
+// Buka blockchain dari block store di dataDir.
+// Jika store masih kosong, genesis block ditulis terlebih dahulu.
+// Store yang berisi chain dari genesis lain ditolak
+func InitializeBlockChain(dataDir string, genesis types.Block) (*Blockchain, error) {
+	blockStore, err := store.OpenBlockStore(dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open block store: %w", err)
+	}
 
-	// Buat hardcoded header
-	header := types.BlockHeader{
-		Height:     0,
-		Timestamp:  time.Date(2025, 11, 20, 0, 0, 0, 0, time.UTC).Unix(),
-		PrevHash:   hashStr,
-		StateRoot:  hashStr,
-		TxRoot:     hashStr,
-		ProposerID: "SYSTEM_INIT",
+	txIndex, err := store.OpenTxIndex(filepath.Join(dataDir, "txindex.log"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tx index: %w", err)
 	}
 
-	// Buat block
-	block := types.Block{
-		Header:       header,
-		Transactions: []types.Transaction{},
-		QC:           types.QuorumCertificate{},
+	blockchain := Blockchain{
+		store:   blockStore,
+		txIndex: txIndex,
 	}
 
-	// QC kosong
-	block.QC = types.QuorumCertificate{
-		HeaderHash: block.HeaderHash(),
-		Signatures: "",
+	if blockStore.Len() == 0 {
+		if err := blockStore.Append(genesis, []types.Receipt{}); err != nil {
+			return nil, fmt.Errorf("failed to write genesis block: %w", err)
+		}
 	}
 
-	blockchain := Blockchain{
-		Blocks: []types.Block{block},
+	stored, err := blockStore.Get(0)
+	if err != nil {
+		return nil, err
+	}
+	if stored.HeaderHash() != genesis.HeaderHash() {
+		return nil, fmt.Errorf("data dir %s belongs to a different genesis (block 0 %s, expecting %s)", dataDir, stored.HeaderHash(), genesis.HeaderHash())
+	}
+
+	if txIndex.NextHeight() > blockStore.Len() {
+		return nil, fmt.Errorf("tx index is ahead of block store (%d > %d blocks)", txIndex.NextHeight(), blockStore.Len())
 	}
 
-	return &blockchain
+	// Block yang tersimpan tapi belum sempat di index (crash setelah block ditulis)
+	for height := txIndex.NextHeight(); height < blockStore.Len(); height++ {
+		block, err := blockStore.Get(height)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := txIndex.Append(height, blockTxIDs(block)); err != nil {
+			return nil, fmt.Errorf("failed to index block %d: %w", height, err)
+		}
+	}
+
+	latest, err := blockStore.Get(blockStore.Len() - 1)
+	if err != nil {
+		return nil, err
+	}
+	blockchain.latest = latest
+
+	return &blockchain, nil
 }
 
-// Tambah block ke chain (fixed block setelah Commit consensus)
-func (bc *Blockchain) AddBlock(block types.Block) error {
-	bc.mux.Lock()
-	defer bc.mux.Unlock()
+func blockTxIDs(block types.Block) []string {
+	ids := make([]string, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		ids[i] = tx.ID
+	}
+	return ids
+}
 
-	lastBlock := bc.Blocks[len(bc.Blocks)-1]
+// Cek block dapat disambung ke block terakhir
+func (bc *Blockchain) ValidateNext(block types.Block) error {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+
+	return bc.validateNext(block)
+}
+
+func (bc *Blockchain) validateNext(block types.Block) error {
+	lastBlock := bc.latest
 
 	if block.Header.Height != lastBlock.Header.Height+1 {
 		return fmt.Errorf("invalid block height (after commit). Expecting %d, got %d", lastBlock.Header.Height+1, block.Header.Height)
@@ -66,40 +108,121 @@ func (bc *Blockchain) AddBlock(block types.Block) error {
 		return fmt.Errorf("previous block hash did not match")
 	}
 
-	txSeen := make(map[string]bool)
-	for _, existingBlock := range bc.Blocks {
-		for _, tx := range existingBlock.Transactions {
-			txSeen[tx.ID] = true
+	return bc.checkTxIDs(block)
+}
+
+// Id tx harus unik di dalam block dan belum pernah masuk chain
+func (bc *Blockchain) checkTxIDs(block types.Block) error {
+	seen := make(map[string]bool, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		if seen[tx.ID] {
+			return fmt.Errorf("%w: %s appears twice in block %d", ErrTxDuplicate, tx.ID, block.Header.Height)
+		}
+		seen[tx.ID] = true
+
+		if location, exists := bc.txIndex.Get(tx.ID); exists {
+			return fmt.Errorf("%w: %s already committed in block %d", ErrTxDuplicate, tx.ID, location.Height)
 		}
 	}
 
-	bc.Blocks = append(bc.Blocks, block)
+	return nil
+}
+
+// Tambah block beserta receipt-nya ke chain (fixed block setelah Commit consensus)
+func (bc *Blockchain) AddBlock(block types.Block, receipts []types.Receipt) error {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	if err := bc.validateNext(block); err != nil {
+		return err
+	}
+
+	// Tulis ke disk (fsync) sebelum block dianggap committed
+	if err := bc.store.Append(block, receipts); err != nil {
+		return fmt.Errorf("failed to persist block: %w", err)
+	}
+
+	// Tanpa index, replay tx block ini tidak terdeteksi (HasTx). Block sudah di disk
+	// dan index dibangun ulang dari block store saat node dibuka kembali
+	if err := bc.txIndex.Append(block.Header.Height, blockTxIDs(block)); err != nil {
+		return fmt.Errorf("failed to index txs of block %d: %w", block.Header.Height, err)
+	}
+
+	bc.latest = block
 	return nil
 }
 
 // Mengambil block dari height yang spesifik
 func (bc *Blockchain) GetBlock(height uint64) (types.Block, error) {
-	bc.mux.RLock()
-	defer bc.mux.RUnlock()
-
-	if height >= uint64(len(bc.Blocks)) {
+	if height > bc.GetLatestHeight() {
 		return types.Block{}, fmt.Errorf("requested height is higher than current stored")
 	}
 
-	block := bc.Blocks[height]
-	return block, nil
+	return bc.store.Get(height)
+}
+
+// Ambil receipt tx yang sudah masuk chain
+func (bc *Blockchain) GetReceipt(txID string) (types.Receipt, error) {
+	location, exists := bc.txIndex.Get(txID)
+	if !exists {
+		return types.Receipt{}, ErrTxNotFound
+	}
+
+	receipts, err := bc.store.GetReceipts(location.Height)
+	if err != nil {
+		return types.Receipt{}, err
+	}
+
+	for _, receipt := range receipts {
+		if receipt.TxID == txID {
+			return receipt, nil
+		}
+	}
+
+	return types.Receipt{}, ErrTxNotFound
+}
+
+// Tx sudah masuk chain
+func (bc *Blockchain) HasTx(txID string) bool {
+	_, exists := bc.txIndex.Get(txID)
+	return exists
+}
+
+// Tx yang sudah masuk chain beserta block-nya
+func (bc *Blockchain) GetTx(txID string) (types.Transaction, types.Block, store.TxLocation, error) {
+	location, exists := bc.txIndex.Get(txID)
+	if !exists {
+		return types.Transaction{}, types.Block{}, store.TxLocation{}, ErrTxNotFound
+	}
+
+	block, err := bc.store.Get(location.Height)
+	if err != nil {
+		return types.Transaction{}, types.Block{}, store.TxLocation{}, err
+	}
+	if location.Index >= len(block.Transactions) || block.Transactions[location.Index].ID != txID {
+		return types.Transaction{}, types.Block{}, store.TxLocation{}, fmt.Errorf("tx index out of sync with block %d", location.Height)
+	}
+
+	return block.Transactions[location.Index], block, location, nil
 }
 
 func (bc *Blockchain) GetLatestHeight() uint64 {
-	bc.mux.Lock()
-	defer bc.mux.Unlock()
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
 
-	return bc.Blocks[len(bc.Blocks)-1].Header.Height
+	return bc.latest.Header.Height
 }
 
 func (bc *Blockchain) GetLatestBlock() types.Block {
 	bc.mux.RLock()
 	defer bc.mux.RUnlock()
 
-	return bc.Blocks[len(bc.Blocks)-1]
+	return bc.latest
+}
+
+func (bc *Blockchain) Close() error {
+	if err := bc.txIndex.Close(); err != nil {
+		return err
+	}
+	return bc.store.Close()
 }
@@ -0,0 +1,243 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bpjs-hackathon/sehat-chain/internal/mempool"
+	"github.com/bpjs-hackathon/sehat-chain/types"
+	"github.com/bpjs-hackathon/sehat-chain/utils"
+	"github.com/google/uuid"
+)
+
+// Tx kunjungan faskes-1 yang sudah ditandatangani
+func signedVisitTx(cred *utils.CryptoCred, nonce uint64) types.Transaction {
+	payload, _ := json.Marshal(types.TxVisit{RekamMedisID: uuid.NewString(), RekamMedisHash: "hash"})
+	tx := types.Transaction{
+		ID:        uuid.NewString(),
+		Type:      types.TxTypeRecordVisit,
+		Timestamp: time.Now().Unix(),
+		SenderID:  "faskes-1",
+		Nonce:     nonce,
+		Payload:   payload,
+	}
+	tx.Signature = cred.Sign([]byte(tx.Hash()))
+	return tx
+}
+
+// Tx lama dengan id baru (ditandatangani ulang pengirimnya)
+func withNewID(cred *utils.CryptoCred, tx types.Transaction) types.Transaction {
+	tx.ID = uuid.NewString()
+	tx.Signature = cred.Sign([]byte(tx.Hash()))
+	return tx
+}
+
+// Node dengan satu block berisi tx faskes nonce 1 & 2, return tx yang sudah di commit
+func committedTxs(t *testing.T) (*Node, *utils.CryptoCred, []types.Transaction) {
+	t.Helper()
+
+	validator, faskes := testCred(t), testCred(t)
+	node := testNode(t, validator, testGenesis(validator, faskes))
+
+	txs := []types.Transaction{signedVisitTx(faskes, 1), signedVisitTx(faskes, 2)}
+	for _, tx := range txs {
+		if err := node.AddTxToPool(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	block := node.CreateBlock()
+	if len(block.Transactions) != len(txs) {
+		t.Fatalf("block has %d txs, want %d", len(block.Transactions), len(txs))
+	}
+	if err := node.CommitBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	if height := node.Blockchain.GetLatestHeight(); height != 1 {
+		t.Fatalf("height %d after commit, want 1", height)
+	}
+
+	return node, faskes, txs
+}
+
+// Block berikutnya yang berisi txs (header cukup untuk ValidateNext)
+func nextBlock(node *Node, txs ...types.Transaction) types.Block {
+	prev := node.Blockchain.GetLatestBlock()
+	return types.Block{
+		Header: types.BlockHeader{
+			Height:   prev.Header.Height + 1,
+			PrevHash: prev.HeaderHash(),
+			TxRoot:   types.CalculateTxRoot(txs),
+		},
+		Transactions: txs,
+	}
+}
+
+func TestReplayedTxRejected(t *testing.T) {
+	node, faskes, committed := committedTxs(t)
+	replayed := committed[0]
+
+	t.Run("mempool", func(t *testing.T) {
+		if err := node.AddTxToPool(replayed); !errors.Is(err, mempool.ErrAlreadySeen) {
+			t.Fatalf("replayed tx: err %v, want ErrAlreadySeen", err)
+		}
+		if err := node.AddTxToPool(withNewID(faskes, replayed)); !errors.Is(err, errStaleNonce) {
+			t.Fatalf("replayed tx with new id: err %v, want errStaleNonce", err)
+		}
+	})
+
+	blocks := []struct {
+		name    string
+		txs     []types.Transaction
+		wantErr error
+	}{
+		{"committed tx id in later block", []types.Transaction{replayed}, ErrTxDuplicate},
+		{"committed tx id after new tx", []types.Transaction{signedVisitTx(faskes, 3), replayed}, ErrTxDuplicate},
+		{"same tx twice in block", func() []types.Transaction {
+			tx := signedVisitTx(faskes, 3)
+			return []types.Transaction{tx, tx}
+		}(), ErrTxDuplicate},
+		{"old tx with new id", []types.Transaction{withNewID(faskes, replayed)}, errStaleNonce},
+	}
+
+	for _, tt := range blocks {
+		t.Run("block/"+tt.name, func(t *testing.T) {
+			block := nextBlock(node, tt.txs...)
+			if err := node.ValidateBlockTxs(block); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err %v, want %v", err, tt.wantErr)
+			}
+			if err := node.Blockchain.ValidateNext(block); tt.wantErr == ErrTxDuplicate && !errors.Is(err, ErrTxDuplicate) {
+				t.Fatalf("ValidateNext err %v, want ErrTxDuplicate", err)
+			}
+		})
+	}
+}
+
+func TestBlockNonceStrictlyIncreasing(t *testing.T) {
+	node, faskes, _ := committedTxs(t) // nonce faskes-1 di state = 2
+
+	tests := []struct {
+		name   string
+		nonces []uint64
+		valid  bool
+	}{
+		{"next nonce", []uint64{3}, true},
+		{"increasing with gap", []uint64{3, 7}, true},
+		{"nonce already executed", []uint64{2}, false},
+		{"nonce below state", []uint64{1}, false},
+		{"same nonce twice", []uint64{3, 3}, false},
+		{"decreasing nonce", []uint64{4, 3}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var txs []types.Transaction
+			for _, nonce := range tt.nonces {
+				txs = append(txs, signedVisitTx(faskes, nonce))
+			}
+
+			err := node.ValidateBlockTxs(nextBlock(node, txs...))
+			if tt.valid && err != nil {
+				t.Fatalf("valid block rejected: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, errStaleNonce) {
+				t.Fatalf("err %v, want errStaleNonce", err)
+			}
+		})
+	}
+}
+
+func TestMempoolNonceRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		pending []uint64 // tx yang sudah ada di pool
+		nonce   uint64
+		wantErr error
+	}{
+		{"next after state", nil, 3, nil},
+		{"next after pending", []uint64{3, 4}, 5, nil},
+		{"executed nonce", nil, 2, errStaleNonce},
+		{"gap after state", nil, 4, mempool.ErrNonceGap},
+		{"gap after pending", []uint64{3}, 5, mempool.ErrNonceGap},
+		{"reuse pending nonce", []uint64{3, 4}, 4, mempool.ErrNonceInUse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, faskes, _ := committedTxs(t) // nonce faskes-1 di state = 2
+
+			for _, nonce := range tt.pending {
+				if err := node.AddTxToPool(signedVisitTx(faskes, nonce)); err != nil {
+					t.Fatalf("pending nonce %d: %v", nonce, err)
+				}
+			}
+
+			err := node.AddTxToPool(signedVisitTx(faskes, tt.nonce))
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("nonce %d rejected: %v", tt.nonce, err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("nonce %d: err %v, want %v", tt.nonce, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignAndSubmitNonceWithoutGaps(t *testing.T) {
+	node, _, _ := committedTxs(t)
+
+	// Tx pertama gagal masuk pool (payload tidak penting), nonce-nya dipakai ulang tx berikutnya
+	rejected := types.Transaction{ID: "tx-dup", Type: types.TxTypeRecordVisit, SenderID: node.ID}
+	node.Mempool.Remove([]types.Transaction{rejected}) // id sudah pernah dilihat
+	if err := node.signAndSubmit(&rejected); err == nil {
+		t.Fatal("tx with seen id accepted")
+	}
+
+	for want := uint64(1); want <= 3; want++ {
+		tx := types.Transaction{ID: uuid.NewString(), Type: types.TxTypeRecordVisit, SenderID: node.ID}
+		if err := node.signAndSubmit(&tx); err != nil {
+			t.Fatal(err)
+		}
+		if tx.Nonce != want {
+			t.Fatalf("nonce %d, want %d", tx.Nonce, want)
+		}
+	}
+}
+
+func TestCommitBlockRejectsRootMismatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(header *types.BlockHeader)
+	}{
+		{"state root", func(header *types.BlockHeader) { header.StateRoot = "bad" }},
+		{"receipts root", func(header *types.BlockHeader) { header.ReceiptsRoot = "bad" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, faskes, _ := committedTxs(t)
+			stateRoot := node.WorldState.CalculateHash()
+
+			if err := node.AddTxToPool(signedVisitTx(faskes, 3)); err != nil {
+				t.Fatal(err)
+			}
+			block := node.CreateBlock()
+			tt.tamper(&block.Header)
+
+			if err := node.CommitBlock(block); err == nil {
+				t.Fatal("block with mismatched root committed")
+			}
+			if height := node.Blockchain.GetLatestHeight(); height != 1 {
+				t.Fatalf("height %d after rejected block, want 1", height)
+			}
+			if got := node.WorldState.CalculateHash(); got != stateRoot {
+				t.Fatalf("world state changed by rejected block: %s, want %s", got, stateRoot)
+			}
+			if nonce := node.WorldState.GetNonce("faskes-1"); nonce != 2 {
+				t.Fatalf("nonce %d after rejected block, want 2", nonce)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bpjs-hackathon/sehat-chain/internal/state"
+	"github.com/bpjs-hackathon/sehat-chain/types"
+)
+
+const GenesisProposerID = "GENESIS"
+
+// Baca dan validasi genesis.json
+func LoadGenesis(path string) (*types.Genesis, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read genesis file: %w", err)
+	}
+
+	var genesis types.Genesis
+	if err := json.Unmarshal(data, &genesis); err != nil {
+		return nil, fmt.Errorf("invalid genesis file %s: %w", path, err)
+	}
+
+	if err := genesis.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &genesis, nil
+}
+
+// Isi world state dengan state awal dari genesis
+func applyGenesis(ws *state.WorldState, genesis *types.Genesis) {
+	for _, validator := range genesis.Validators {
+		ws.SetValidator(validator)
+	}
+
+	for _, submitter := range genesis.Submitters {
+		ws.SetRole(submitter.ID, submitter.Role)
+		if submitter.RegionalTier != "" {
+			ws.SetRegionalTier(submitter.ID, submitter.RegionalTier)
+		}
+	}
+	ws.SetConfig(state.ConfigTariffVersion, genesis.TariffVersion)
+	ws.SetConfig(state.ConfigTariffHash, genesis.TariffHash)
+
+	for _, visit := range genesis.Assets.Visits {
+		ws.AddVisit(visit)
+	}
+	for _, rujukan := range genesis.Assets.Rujukans {
+		ws.AddRujukan(rujukan)
+	}
+	for _, claim := range genesis.Assets.Claims {
+		ws.AddClaim(claim)
+	}
+}
+
+// Genesis block diturunkan dari genesis: PrevHash berisi hash genesis
+// sehingga seluruh chain terikat pada chain id dan state awal yang sama
+func createGenesisBlock(genesis *types.Genesis, stateRoot string) types.Block {
+	zeroHash := strings.Repeat("0", 64)
+
+	header := types.BlockHeader{
+		Height:       0,
+		Timestamp:    genesis.GenesisTime,
+		PrevHash:     genesis.Hash(),
+		StateRoot:    stateRoot,
+		TxRoot:       zeroHash,
+		ReceiptsRoot: zeroHash,
+		ProposerID:   GenesisProposerID,
+	}
+
+	block := types.Block{
+		Header:       header,
+		Transactions: []types.Transaction{},
+	}
+
+	// QC kosong
+	block.QC = types.QuorumCertificate{
+		HeaderHash: block.HeaderHash(),
+		Height:     header.Height,
+		VoteType:   types.VoteTypeCommit,
+		Signers:    []string{},
+		Signatures: map[string]string{},
+	}
+
+	return block
+}
@@ -2,9 +2,12 @@ package core
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 
+	"github.com/bpjs-hackathon/sehat-chain/internal/mempool"
 	"github.com/bpjs-hackathon/sehat-chain/internal/p2p"
+	"github.com/bpjs-hackathon/sehat-chain/types"
 )
 
 func (node *Node) handleIncomingMessage(peer *p2p.Peer, msg p2p.Message) {
@@ -15,12 +18,24 @@ func (node *Node) handleIncomingMessage(peer *p2p.Peer, msg p2p.Message) {
 		node.handleHandshakeResponse(peer, msg)
 	case p2p.MsgTypeBlockReq:
 		node.handleBlockRequest(peer, msg)
+	case p2p.MsgTypeHeadersReq:
+		node.handleHeadersRequest(peer, msg)
+	case p2p.MsgTypeBlockRangeReq:
+		node.handleBlockRangeRequest(peer, msg)
 	case p2p.MsgTypePeersReq:
 		node.handlePeerRequest(peer, msg)
 	case p2p.MsgTypeTxGossip:
 		node.handleTxGossip(msg)
 	case p2p.MsgTypeBlockSend:
 		node.handleBlockSend(msg)
+	case p2p.MsgTypeProposal:
+		node.handleProposal(peer, msg)
+	case p2p.MsgTypeVote:
+		node.handleVote(peer, msg)
+	case p2p.MsgTypePrepareQC:
+		node.handlePrepareQC(msg)
+	case p2p.MsgTypeViewChange:
+		node.handleViewChange(msg)
 	default:
 		fmt.Printf("invalid message type")
 	}
@@ -28,23 +43,32 @@ func (node *Node) handleIncomingMessage(peer *p2p.Peer, msg p2p.Message) {
 
 func (node *Node) handleHandshakeRequest(peer *p2p.Peer, message p2p.Message) {
 	var handshake p2p.HandshakePayload
-	if err := json.Unmarshal(message.Payload, &handshake); err != nil {
+	if err := message.DecodePayload(&handshake); err != nil {
 		fmt.Printf("invalid message type and actual payload format")
 		return
 	}
 
-	node.peers[handshake.NodeID] = handshake.Secret
+	if err := node.verifyPeerIdentity(peer, handshake); err != nil {
+		fmt.Printf("rejecting handshake from %s: %v\n", handshake.NodeID, err)
+		peer.Close()
+		return
+	}
 
-	// Masukkan dalam peer list
-	node.P2P.RegisterPeer(peer, handshake.NodeID)
+	node.mux.Lock()
+	node.peers[handshake.NodeID] = handshake.PublicKey
+	node.mux.Unlock()
 
 	// Kirimkan pesan balasan ke requester
 	respPayload := p2p.HandshakePayload{
-		NodeID: node.ID,
-		Port:   node.P2P.Port,
-		Secret: node.cred.GetSecret(),
+		NodeID:    node.ID,
+		Port:      node.P2P.Port,
+		PublicKey: node.cred.PublicKey(),
+
+		ChainID:     node.Genesis.ChainID,
+		GenesisHash: node.GenesisHash,
+		WireVersion: p2p.NegotiateWireVersion(handshake.WireVersion),
 	}
-	respPayloadRaw, err := json.Marshal(respPayload)
+	respPayloadRaw, err := p2p.EncodePayload(respPayload)
 	if err != nil {
 		fmt.Printf("handshake resp marshal failed")
 	}
@@ -54,43 +78,98 @@ func (node *Node) handleHandshakeRequest(peer *p2p.Peer, message p2p.Message) {
 		SenderID:   node.ID,
 		Type:       p2p.MsgHandshakeResp,
 		RequestID:  message.RequestID,
-		ResponseID: message.ResponseID,
+		ResponseID: message.RequestID,
 		Payload:    respPayloadRaw,
 	}
 
-	node.P2P.Send(handshake.NodeID, respMessage)
+	// Response masih memakai format lama, pesan berikutnya memakai versi yang disepakati.
+	// Peer baru masuk peer list setelah versi berganti agar pesan lain tidak terkirim dengan format lama
+	if err := peer.RespondHandshake(respMessage, respPayload.WireVersion); err != nil {
+		fmt.Printf("failed to respond handshake from %s: %v\n", handshake.NodeID, err)
+		peer.Close()
+		return
+	}
+
+	node.P2P.RegisterPeer(peer, handshake.NodeID)
+	node.onPeerConnected(peer, handshake)
 }
 
 func (node *Node) handleHandshakeResponse(peer *p2p.Peer, message p2p.Message) {
 	var respPayload p2p.HandshakePayload
-	if err := json.Unmarshal(message.Payload, &respPayload); err != nil {
+	if err := message.DecodePayload(&respPayload); err != nil {
 		fmt.Printf("failed to unmarshal handshake response: %v\n", err)
 		return
 	}
 
-	// Store peer secret
+	if err := node.verifyPeerIdentity(peer, respPayload); err != nil {
+		fmt.Printf("rejecting handshake response from %s: %v\n", respPayload.NodeID, err)
+		return
+	}
+
+	// Store peer public key
 	node.mux.Lock()
-	node.peers[respPayload.NodeID] = respPayload.Secret
+	node.peers[respPayload.NodeID] = respPayload.PublicKey
 	node.mux.Unlock()
 
 	// Register peer
+	peer.SetWireVersion(p2p.NegotiateWireVersion(respPayload.WireVersion))
 	node.P2P.RegisterPeer(peer, respPayload.NodeID)
+	node.onPeerConnected(peer, respPayload)
 
 	fmt.Printf("✅ Handshake complete with %s\n", respPayload.NodeID)
 }
 
-func (node *Node) handlePeerRequest(peer *p2p.Peer, message p2p.Message) {
-	peersList := node.P2P.Peers
+// Peer harus berada di chain yang sama (chain id & genesis) dan public key yang
+// diklaim harus sama dengan key yang dibuktikan peer saat TLS handshake.
+// Id validator / submitter terdaftar hanya boleh dipakai pemilik key-nya
+func (node *Node) verifyPeerIdentity(peer *p2p.Peer, handshake p2p.HandshakePayload) error {
+	if handshake.PublicKey != peer.PublicKey {
+		return fmt.Errorf("public key does not match tls certificate")
+	}
+
+	if handshake.ChainID != node.Genesis.ChainID {
+		return fmt.Errorf("chain id %s does not match %s", handshake.ChainID, node.Genesis.ChainID)
+	}
 
+	if handshake.GenesisHash != node.GenesisHash {
+		return fmt.Errorf("genesis hash %s does not match %s", handshake.GenesisHash, node.GenesisHash)
+	}
+
+	validator, isValidator := node.WorldState.GetValidator(handshake.NodeID)
+	if isValidator && validator.PublicKey != handshake.PublicKey {
+		return fmt.Errorf("public key does not match configured validator %s", handshake.NodeID)
+	}
+
+	submitter, isSubmitter := node.Registry.Get(handshake.NodeID)
+	if isSubmitter && submitter.PublicKey != handshake.PublicKey {
+		return fmt.Errorf("public key does not match registered submitter %s", handshake.NodeID)
+	}
+
+	return nil
+}
+
+// Balas dengan peer yang sedang terhubung ditambah alamat yang pernah berhasil kita hubungi
+func (node *Node) handlePeerRequest(peer *p2p.Peer, message p2p.Message) {
 	mappedPeers := make(map[string]string)
-	for peerID, peer := range peersList {
-		mappedPeers[peerID] = peer.Address
+	for _, entry := range node.AddressBook.Entries() {
+		if entry.LastSeen > 0 && entry.Score > 0 {
+			mappedPeers[entry.ID] = entry.Address
+		}
+	}
+
+	node.P2P.PeersMux.RLock()
+	for peerID, connected := range node.P2P.Peers {
+		if connected.ListenAddress != "" {
+			mappedPeers[peerID] = connected.ListenAddress
+		}
 	}
+	node.P2P.PeersMux.RUnlock()
+	delete(mappedPeers, peer.ID)
 
 	peerResp := p2p.PeerPayload{
 		Peers: mappedPeers,
 	}
-	peerRespRaw, err := json.Marshal(peerResp)
+	peerRespRaw, err := p2p.EncodePayload(peerResp)
 	if err != nil {
 		fmt.Printf("peer resp marshal failed")
 	}
@@ -98,7 +177,7 @@ func (node *Node) handlePeerRequest(peer *p2p.Peer, message p2p.Message) {
 	respMessage := p2p.Message{
 		SenderID:   node.ID,
 		RequestID:  message.RequestID,
-		ResponseID: message.ResponseID,
+		ResponseID: message.RequestID,
 		Type:       p2p.MsgTypePeersSend,
 		Payload:    peerRespRaw,
 	}
@@ -108,7 +187,7 @@ func (node *Node) handlePeerRequest(peer *p2p.Peer, message p2p.Message) {
 
 func (node *Node) handleBlockRequest(peer *p2p.Peer, message p2p.Message) {
 	var blockReq p2p.BlockRequestPayload
-	if err := json.Unmarshal(message.Payload, &blockReq); err != nil {
+	if err := message.DecodePayload(&blockReq); err != nil {
 		fmt.Printf("invalid message type and actual payload format")
 		return
 	}
@@ -125,7 +204,7 @@ func (node *Node) handleBlockRequest(peer *p2p.Peer, message p2p.Message) {
 		LatestHeight: node.Blockchain.GetLatestHeight(),
 		Block:        block,
 	}
-	blockRespRaw, err := json.Marshal(blockResp)
+	blockRespRaw, err := p2p.EncodePayload(blockResp)
 	if err != nil {
 		fmt.Printf("block resp marshal failed")
 	}
@@ -133,7 +212,7 @@ func (node *Node) handleBlockRequest(peer *p2p.Peer, message p2p.Message) {
 	respMessage := p2p.Message{
 		SenderID:   node.ID,
 		RequestID:  message.RequestID,
-		ResponseID: message.ResponseID,
+		ResponseID: message.RequestID,
 		Type:       p2p.MsgTypeBlockSend,
 		Payload:    blockRespRaw,
 	}
@@ -141,34 +220,170 @@ func (node *Node) handleBlockRequest(peer *p2p.Peer, message p2p.Message) {
 	node.P2P.Send(peer.ID, respMessage)
 }
 
+func (node *Node) handleHeadersRequest(peer *p2p.Peer, message p2p.Message) {
+	var headersReq p2p.HeadersRequestPayload
+	if err := message.DecodePayload(&headersReq); err != nil {
+		fmt.Printf("invalid message type and actual payload format")
+		return
+	}
+
+	// Selalu dibalas walau kosong agar peer tahu height terbaru kita
+	latestHeight := node.Blockchain.GetLatestHeight()
+	headers := make([]p2p.SyncHeader, 0)
+	if headersReq.Count > 0 {
+		to := min(latestHeight, headersReq.From+min(headersReq.Count, HeaderBatchSize)-1)
+		for height := headersReq.From; height <= to; height++ {
+			block, err := node.Blockchain.GetBlock(height)
+			if err != nil {
+				fmt.Printf("cannot handle headers request from %s, reason: %s\n", peer.ID, err)
+				break
+			}
+
+			headers = append(headers, p2p.SyncHeader{Header: block.Header, QC: block.QC})
+		}
+	}
+
+	headersResp := p2p.HeadersPayload{
+		LatestHeight: latestHeight,
+		Headers:      headers,
+	}
+	headersRespRaw, _ := p2p.EncodePayload(headersResp)
+
+	node.P2P.Send(peer.ID, p2p.Message{
+		SenderID:   node.ID,
+		RequestID:  message.RequestID,
+		ResponseID: message.RequestID,
+		Type:       p2p.MsgTypeHeadersSend,
+		Payload:    headersRespRaw,
+	})
+}
+
+func (node *Node) handleBlockRangeRequest(peer *p2p.Peer, message p2p.Message) {
+	var rangeReq p2p.BlockRangeRequestPayload
+	if err := message.DecodePayload(&rangeReq); err != nil {
+		fmt.Printf("invalid message type and actual payload format")
+		return
+	}
+
+	latestHeight := node.Blockchain.GetLatestHeight()
+	to := min(rangeReq.To, latestHeight, rangeReq.From+BlockRangeSize-1)
+
+	// Balasan dipotong sebelum melewati batas frame, sisanya diminta ulang
+	blocks := make([]types.Block, 0)
+	size := 0
+	for height := rangeReq.From; height <= to; height++ {
+		block, err := node.Blockchain.GetBlock(height)
+		if err != nil {
+			fmt.Printf("cannot handle block range request from %s, reason: %s\n", peer.ID, err)
+			break
+		}
+
+		blockRaw, _ := json.Marshal(block)
+		if len(blocks) > 0 && size+len(blockRaw) > maxBlockRangeBytes {
+			break
+		}
+
+		blocks = append(blocks, block)
+		size += len(blockRaw)
+	}
+
+	rangeResp := p2p.BlockRangePayload{
+		LatestHeight: latestHeight,
+		Blocks:       blocks,
+	}
+	rangeRespRaw, _ := p2p.EncodePayload(rangeResp)
+
+	node.P2P.Send(peer.ID, p2p.Message{
+		SenderID:   node.ID,
+		RequestID:  message.RequestID,
+		ResponseID: message.RequestID,
+		Type:       p2p.MsgTypeBlockRangeSend,
+		Payload:    rangeRespRaw,
+	})
+}
+
 func (node *Node) handleTxGossip(message p2p.Message) {
 	var txGossip p2p.TxGossipPayload
-	if err := json.Unmarshal(message.Payload, &txGossip); err != nil {
-		fmt.Print("tx gossip unmarshal failed")
+	if err := message.DecodePayload(&txGossip); err != nil {
+		node.recordRejectedTx(txGossip.Transaction, err)
+		return
 	}
 
 	// Masukkan tx ke mempool
-	added := node.AddTxToPool(txGossip.Transaction)
-	if !added {
+	if err := node.AddTxToPool(txGossip.Transaction); err != nil {
 		// Return tanpa broadcast
-		fmt.Println("got tx that are already in mempool. Skipping broadcast")
+		if errors.Is(err, mempool.ErrAlreadySeen) {
+			fmt.Println("got tx that are already in mempool. Skipping broadcast")
+		}
 		return
 	}
 
 	// Broadcast lagi ke list validator
 	node.Broadcast(message)
-
-	// Cek jumlah tx
-	if len(node.txPool) >= MaxBlockTxs {
-		node.Consensus.StartRound()
-	}
 }
 
 func (node *Node) handleBlockSend(message p2p.Message) {
 	var blockPayload p2p.BlockPayload
-	if err := json.Unmarshal(message.Payload, &blockPayload); err != nil {
-		fmt.Print("block payload unmarshal failed")
+	if err := message.DecodePayload(&blockPayload); err != nil {
+		fmt.Printf("block payload unmarshal failed: %v\n", err)
+		return
 	}
 
+	node.syncIfBehind(blockPayload.Block.Header.Height)
 	node.Consensus.HandleIncomingBlock(blockPayload.Block)
 }
+
+// Id pengirim diambil dari peer yang sudah diautentikasi saat handshake, bukan dari SenderID pesan
+func (node *Node) handleProposal(peer *p2p.Peer, message p2p.Message) {
+	var proposal p2p.ProposalPayload
+	if err := message.DecodePayload(&proposal); err != nil {
+		fmt.Printf("proposal payload unmarshal failed: %v\n", err)
+		return
+	}
+
+	// Proposal dikirim langsung oleh leader, tidak pernah di relay
+	if proposal.LeaderID != peer.ID {
+		fmt.Printf("proposal from %s sent by %s, ignoring\n", proposal.LeaderID, peer.ID)
+		return
+	}
+
+	node.syncIfBehind(proposal.Block.Header.Height)
+	node.Consensus.HandleProposal(proposal)
+}
+
+func (node *Node) handleVote(peer *p2p.Peer, message p2p.Message) {
+	var vote p2p.VotePayload
+	if err := message.DecodePayload(&vote); err != nil {
+		fmt.Printf("vote payload unmarshal failed: %v\n", err)
+		return
+	}
+
+	// Vote harus dikirim oleh validator itu sendiri
+	if vote.NodeID != peer.ID {
+		fmt.Printf("vote from %s relayed by %s, ignoring\n", vote.NodeID, peer.ID)
+		return
+	}
+
+	node.Consensus.HandleVote(vote)
+}
+
+func (node *Node) handlePrepareQC(message p2p.Message) {
+	var qcPayload p2p.PrepareQCPayload
+	if err := message.DecodePayload(&qcPayload); err != nil {
+		fmt.Printf("prepare QC payload unmarshal failed: %v\n", err)
+		return
+	}
+
+	node.syncIfBehind(qcPayload.QC.Height)
+	node.Consensus.HandlePrepareQC(qcPayload.QC)
+}
+
+func (node *Node) handleViewChange(message p2p.Message) {
+	var vc p2p.ViewChangePayload
+	if err := message.DecodePayload(&vc); err != nil {
+		fmt.Printf("view change payload unmarshal failed: %v\n", err)
+		return
+	}
+
+	node.Consensus.HandleViewChange(vc)
+}
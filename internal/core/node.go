@@ -2,18 +2,22 @@
 package core
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"strings"
+	"path/filepath"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bpjs-hackathon/sehat-chain/internal/api"
 	"github.com/bpjs-hackathon/sehat-chain/internal/consensus"
+	"github.com/bpjs-hackathon/sehat-chain/internal/mempool"
+	"github.com/bpjs-hackathon/sehat-chain/internal/outbox"
 	"github.com/bpjs-hackathon/sehat-chain/internal/p2p"
+	"github.com/bpjs-hackathon/sehat-chain/internal/registry"
 	smartcontract "github.com/bpjs-hackathon/sehat-chain/internal/smart_contract"
 	"github.com/bpjs-hackathon/sehat-chain/internal/state"
 	"github.com/bpjs-hackathon/sehat-chain/types"
@@ -21,20 +25,22 @@ import (
 	"github.com/google/uuid"
 )
 
-const (
-	MaxBlockTxs = 1
+var (
+	errStaleNonce    = errors.New("stale nonce")
+	errUnknownTariff = errors.New("unknown tariff table")
 )
 
 type Node struct {
 	// Identitas node
-	ID          string
-	cred        *utils.CryptoCred
-	isValidator bool
+	ID   string
+	cred *utils.CryptoCred
 
-	// NB: Untuk MVP Proto kita tidak menggunakan keypairs melainkan hanya string secret (mock)
-	// List peers map[id]secret
-	validators map[string]types.ValidatorConfig
-	peers      map[string]string
+	// Genesis chain yang diikuti node, peer dengan genesis berbeda ditolak
+	Genesis     *types.Genesis
+	GenesisHash string
+
+	// List peers map[id]public key (hex)
+	peers map[string]string
 
 	// Data
 	Blockchain *Blockchain
@@ -42,66 +48,151 @@ type Node struct {
 	Executor   *smartcontract.Executor
 	P2P        *p2p.P2PManager
 	Consensus  *consensus.RoundRobin
+	Registry   *registry.Registry // pengirim tx yang diotorisasi
+
+	AddressBook *p2p.AddressBook // alamat peer yang diketahui (peer exchange)
+	Outbox      *outbox.Outbox   // event block yang diteruskan ke database BPJS
 
 	// Pool
-	txPool  []types.Transaction
-	txMap   map[string]types.Transaction
-	seenTxs map[string]any // key tx id
-	txMux   sync.RWMutex
+	Mempool     *mempool.Mempool      // tx yang menunggu masuk block
+	blockConfig BlockProductionConfig // interval & budget produksi block
+	poolFull    chan struct{}         // sinyal pool melewati budget block
+	rejectedTxs map[string]uint64     // jumlah tx ditolak per alasan
+	txMux       sync.RWMutex
 
 	// API
 	Server *api.Server
 
-	mux sync.RWMutex
+	commitMux sync.Mutex
+	nonceMux  sync.Mutex  // tx milik node ini diberi nonce dan masuk pool satu per satu
+	syncing   atomic.Bool // hanya satu proses sync chain berjalan
+	mux       sync.RWMutex
 }
 
-func CreateNode(ID string, secret string, port string, APIPort string, validators []types.ValidatorConfig) *Node {
-	// 1. Convert Slice to Map untuk lookup cepat
-	validatorsMap := make(map[string]types.ValidatorConfig)
-	for _, v := range validators {
-		validatorsMap[v.ID] = v
+func CreateNode(ID string, cred *utils.CryptoCred, port string, APIPort string, dataDir string, genesis *types.Genesis, tariffs *smartcontract.TariffRegistry, mempoolConfig mempool.Config, blockConfig BlockProductionConfig) (*Node, error) {
+	// Sertifikat TLS p2p ditandatangani key node, peer memverifikasi public key yang sama
+	cert, err := cred.TLSCertificate(ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tls certificate: %w", err)
 	}
-	_, isValidator := validatorsMap[ID]
+	p2pMan := p2p.CreateP2PManager(ID, port, cert)
 
-	p2pMan := p2p.CreateP2PManager(ID, port)
-
-	blockchain := InitializeBlockChain()
+	// State awal (validator, faskes, versi tarif, asset) hanya berasal dari genesis.
+	// Perubahan validator setelah genesis hanya lewat tx governance
 	ws := state.CreateWorldState()
+	applyGenesis(ws, genesis)
+	ws.Commit(0) // snapshot state genesis
+
+	blockchain, err := InitializeBlockChain(dataDir, createGenesisBlock(genesis, ws.CalculateHash()))
+	if err != nil {
+		return nil, err
+	}
+
+	executor := smartcontract.NewExecutor(ws, tariffs)
 
-	executor := smartcontract.NewExecutor(ws)
+	eventOutbox, err := outbox.OpenOutbox(filepath.Join(dataDir, "outbox.json"), outbox.ClaimStatusDispatcher(outbox.DefaultDatabaseURL))
+	if err != nil {
+		return nil, err
+	}
+
+	// Validator genesis selalu menjadi titik awal peer discovery
+	addressBook, err := p2p.OpenAddressBook(filepath.Join(dataDir, "addrbook.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, validator := range genesis.Validators {
+		addressBook.Add(validator.ID, validator.Address)
+	}
+
+	// Replay block yang tersimpan di disk untuk membangun ulang world state
+	if err := replayChain(blockchain, executor, eventOutbox); err != nil {
+		return nil, err
+	}
+
+	// Tabel tarif yang berbeda isi dengan versi on-chain membuat hasil eksekusi claim berbeda
+	tariffVersion, _ := ws.GetConfig(state.ConfigTariffVersion)
+	tariffHash, _ := ws.GetConfig(state.ConfigTariffHash)
+	if _, err := tariffs.Verify(tariffVersion, tariffHash); err != nil {
+		return nil, err
+	}
 
 	node := Node{
 		ID:          ID,
-		isValidator: isValidator,
-		validators:  validatorsMap,
+		Genesis:     genesis,
+		GenesisHash: genesis.Hash(),
 		peers:       make(map[string]string),
-		cred:        utils.NewCred(secret),
+		cred:        cred,
 		Blockchain:  blockchain,
 		WorldState:  ws,
 		Executor:    executor,
 		P2P:         p2pMan,
-		txPool:      make([]types.Transaction, 0),
-		txMap:       make(map[string]types.Transaction),
-		seenTxs:     make(map[string]any, 0),
+		Registry:    registry.CreateRegistry(genesis.Submitters),
+		Outbox:      eventOutbox,
+		AddressBook: addressBook,
+		Mempool:     mempool.New(mempoolConfig),
+		blockConfig: blockConfig.withDefaults(),
+		poolFull:    make(chan struct{}, 1),
+		rejectedTxs: make(map[string]uint64),
 	}
 
-	node.Consensus = consensus.NewRoundRobin(ID, &node, validatorsMap)
+	node.Consensus = consensus.NewRoundRobin(ID, &node)
 	node.P2P.Subscribe(node.handleIncomingMessage)
+	node.P2P.OnDisconnect(node.handlePeerDisconnected)
 
 	handler := api.CreateAPIHandler()
 
 	handler.AddEndpoint("POST /api/rekam_medis/fk1", cors(node.handleFK1RekamMedisPost))
 	handler.AddEndpoint("POST /api/rekam_medis/fk2", cors(node.handleFK2RekamMedisPost))
 	handler.AddEndpoint("GET /api/rujukan/{id}", cors(node.handleAPIRequestRujukan))
+	handler.AddEndpoint("POST /api/rujukan/{id}/cancel", cors(node.handleRujukanCancel))
+	handler.AddEndpoint("GET /api/peserta/{nik}/rujukan", cors(node.handleAPIPesertaRujukan))
+	handler.AddEndpoint("GET /api/claims", cors(node.handleAPIClaims))
+	handler.AddEndpoint("GET /api/claims/{id}", cors(node.handleAPIClaimByID))
+	handler.AddEndpoint("GET /api/visits/{rekam_medis_id}", cors(node.handleAPIVisit))
 	handler.AddEndpoint("POST /api/claim", cors(node.handleClaimExecute))
+	handler.AddEndpoint("POST /api/tariff/version", cors(node.handleTariffVersionSet))
+	handler.AddEndpoint("POST /api/governance/validator/sign", cors(node.handleValidatorChangeSign))
+	handler.AddEndpoint("POST /api/governance/validator", cors(node.handleValidatorChange))
+	handler.AddEndpoint("GET /api/validators", cors(node.handleAPIValidators))
+	handler.AddEndpoint("GET /api/peers", cors(node.handleAPIPeers))
 	handler.AddEndpoint("GET /api/total_block", cors(node.handleBlockTotalReq))
 	handler.AddEndpoint("GET /api/block/{height}", cors(node.handleAPIBlockRequest))
+	handler.AddEndpoint("GET /api/proof/{namespace}/{id}", cors(node.handleAPIStateProof))
+	handler.AddEndpoint("GET /api/tx/rejected", cors(node.handleAPIRejectedTxs))
+	handler.AddEndpoint("GET /api/tx/{id}", cors(node.handleAPITxLookup))
+	handler.AddEndpoint("GET /api/tx/{id}/receipt", cors(node.handleAPITxReceipt))
 	handler.AddEndpoint("GET /api/ping", cors(node.handleAPIPing))
 
 	server := api.CreateServer(handler, APIPort)
 	node.Server = server
 
-	return &node
+	return &node, nil
+}
+
+// Eksekusi ulang semua block dan cocokkan state root serta receipts root setiap block.
+// Event dari block yang belum sempat masuk outbox (crash setelah block disimpan) di enqueue ulang
+func replayChain(blockchain *Blockchain, executor *smartcontract.Executor, events *outbox.Outbox) error {
+	latestHeight := blockchain.GetLatestHeight()
+	for height := uint64(1); height <= latestHeight; height++ {
+		block, err := blockchain.GetBlock(height)
+		if err != nil {
+			return err
+		}
+
+		receipts, err := executor.VerifyAndApplyBlock(block)
+		if err != nil {
+			return fmt.Errorf("replaying block %d: %w", height, err)
+		}
+
+		if err := events.Enqueue(height, smartcontract.BlockEvents(receipts)); err != nil {
+			return err
+		}
+	}
+
+	if latestHeight > 0 {
+		fmt.Printf("📦 Replayed %d blocks from disk\n", latestHeight)
+	}
+	return nil
 }
 
 func cors(next http.HandlerFunc) http.HandlerFunc {
@@ -119,10 +210,14 @@ func (node *Node) Start() {
 	}
 
 	go node.Server.Run()
+	go node.Outbox.Run()
+	go node.runPeerExchange()
 
 	//node.ConnectToNetwork()
 	// node.EfficientConnectToNetwork()
 	node.RobustConnectToNetwork()
+	go node.maintainValidatorConnections()
+	go node.runBlockProduction()
 }
 
 func (node *Node) ConnectToNetwork() {
@@ -131,7 +226,7 @@ func (node *Node) ConnectToNetwork() {
 	// connect to fixed validators
 	var wg sync.WaitGroup
 
-	for _, validator := range node.validators {
+	for _, validator := range node.Validators() {
 		if validator.ID == node.ID {
 			continue
 		}
@@ -153,7 +248,7 @@ func (node *Node) ConnectToNetwork() {
 		Height: node.Blockchain.GetLatestHeight() + 1,
 	}
 
-	reqPayloadRaw, _ := json.Marshal(reqPayload)
+	reqPayloadRaw, _ := p2p.EncodePayload(reqPayload)
 
 	reqMessage := p2p.Message{
 		SenderID:  node.ID,
@@ -163,18 +258,18 @@ func (node *Node) ConnectToNetwork() {
 	}
 
 	//var latestHeight uint64
-	for k := range node.validators {
-		if k == node.ID {
+	for _, validator := range node.Validators() {
+		if validator.ID == node.ID {
 			continue
 		}
 
-		resp, err := node.P2P.Request(k, reqMessage, time.Second*5)
+		resp, err := node.P2P.Request(validator.ID, reqMessage, time.Second*5)
 		if err != nil {
 			continue
 		}
 
 		var blockPayload p2p.BlockPayload
-		if err := json.Unmarshal(resp.Payload, &blockPayload); err != nil {
+		if err := resp.DecodePayload(&blockPayload); err != nil {
 			continue
 		}
 
@@ -191,7 +286,7 @@ func (node *Node) EfficientConnectToNetwork() {
 
 	var wg sync.WaitGroup
 
-	for _, validator := range node.validators {
+	for _, validator := range node.Validators() {
 		if validator.ID == node.ID {
 			continue
 		}
@@ -223,7 +318,7 @@ func (node *Node) RobustConnectToNetwork() {
 	connectionAttempts := make(map[string]int)
 	var connMux sync.Mutex
 
-	for _, validator := range node.validators {
+	for _, validator := range node.Validators() {
 		if validator.ID == node.ID {
 			continue
 		}
@@ -290,121 +385,6 @@ func (node *Node) RobustConnectToNetwork() {
 	node.triggerSync()
 }
 
-func (node *Node) triggerSync() {
-
-	currentHeight := node.Blockchain.GetLatestHeight()
-	reqPayload := p2p.BlockRequestPayload{
-		Height: currentHeight + 1, // Minta blok selanjutnya (dummy request untuk dapat metadata height)
-	}
-	// Note: Sebaiknya ada message tipe CHAIN_STATE_REQ untuk minta height saja,
-	// tapi pakai BlockReq juga bisa jika responnya mengandung LatestHeight.
-
-	reqPayloadRaw, _ := json.Marshal(reqPayload)
-	reqMessage := p2p.Message{
-		SenderID:  node.ID,
-		RequestID: uuid.NewString(),
-		Type:      p2p.MsgTypeBlockReq,
-		Payload:   reqPayloadRaw,
-	}
-
-	var maxNetworkHeight uint64 = 0
-
-	// Tanya ke semua validator yang terhubung
-	for id := range node.validators {
-		if id == node.ID {
-			continue
-		}
-
-		// Gunakan Request dengan timeout pendek
-		resp, err := node.P2P.Request(id, reqMessage, 2*time.Second)
-		if err != nil {
-			fmt.Printf("request error : %v", err)
-			continue
-		}
-
-		var blockPayload p2p.BlockPayload
-		if err := json.Unmarshal(resp.Payload, &blockPayload); err == nil {
-			if blockPayload.LatestHeight > maxNetworkHeight {
-				maxNetworkHeight = blockPayload.LatestHeight
-			}
-		}
-	}
-
-	if maxNetworkHeight > currentHeight {
-		fmt.Printf("📉 Node behind! Current: %d, Network: %d. Starting sync...\n", currentHeight, maxNetworkHeight)
-		go node.syncChain(maxNetworkHeight)
-	} else {
-		fmt.Println("✅ Node up-to-date.")
-	}
-}
-
-func (node *Node) syncChain(targetHeight uint64) {
-	fmt.Printf("🔄 Syncing chain up to height %d\n", targetHeight)
-
-	for {
-		currentHeight := node.Blockchain.GetLatestHeight()
-		if currentHeight >= targetHeight {
-			fmt.Println("✅ Sync Complete!")
-			return
-		}
-
-		nextHeight := currentHeight + 1
-		fmt.Printf("⬇️ Requesting Block #%d...\n", nextHeight)
-
-		reqPayload := p2p.BlockRequestPayload{Height: nextHeight}
-		reqPayloadRaw, _ := json.Marshal(reqPayload)
-
-		reqMessage := p2p.Message{
-			SenderID:  node.ID,
-			RequestID: uuid.NewString(),
-			Type:      p2p.MsgTypeBlockReq,
-			Payload:   reqPayloadRaw,
-		}
-
-		blockReceived := false
-
-		// Coba minta ke validator satu per satu sampai dapat
-		for id := range node.validators {
-			if id == node.ID {
-				continue
-			}
-
-			resp, err := node.P2P.Request(id, reqMessage, 2*time.Second)
-			if err != nil {
-				continue
-			}
-
-			var blockPayload p2p.BlockPayload
-			if err := json.Unmarshal(resp.Payload, &blockPayload); err != nil {
-				continue
-			}
-
-			// Validasi blok yang diterima sebelum commit
-			newBlock := blockPayload.Block
-			if newBlock.Header.Height == nextHeight {
-				// CommitBlock sudah handle validasi lanjutan & insert DB
-				node.CommitBlock(newBlock)
-				blockReceived = true
-
-				// Update target jika network tumbuh saat kita sync
-				if blockPayload.LatestHeight > targetHeight {
-					targetHeight = blockPayload.LatestHeight
-				}
-
-				break // Lanjut ke blok berikutnya
-			}
-		}
-
-		if !blockReceived {
-			fmt.Printf("⚠️ Failed to fetch Block #%d from any peer. Retrying...\n", nextHeight)
-			time.Sleep(2 * time.Second)
-		} else {
-			// Istirahat sebentar biar gak spam network
-			time.Sleep(50 * time.Millisecond)
-		}
-	}
-}
-
 func (node *Node) startHandshake(address string) error {
 	// Buat hubungan ke node
 	peer, err := node.P2P.Connect(address)
@@ -412,21 +392,34 @@ func (node *Node) startHandshake(address string) error {
 		return err
 	}
 
+	// Koneksi yang gagal handshake ditutup dan registrasi sementara (key address) dihapus
+	connected := false
+	defer func() {
+		if !connected {
+			peer.Close()
+			node.P2P.RemovePeer(address)
+		}
+	}()
+
 	// Buat message handshake
 	handshake := p2p.HandshakePayload{
-		NodeID: node.ID,
-		Port:   node.P2P.Port,
-		Secret: node.cred.GetSecret(),
+		NodeID:    node.ID,
+		Port:      node.P2P.Port,
+		PublicKey: node.cred.PublicKey(),
+
+		ChainID:     node.Genesis.ChainID,
+		GenesisHash: node.GenesisHash,
+		WireVersion: p2p.WireVersionCurrent,
 	}
 
-	handshakeJson, _ := json.Marshal(handshake)
+	handshakeRaw, _ := p2p.EncodePayload(handshake)
 
 	// wrap message
 	message := p2p.Message{
 		SenderID:  node.ID,
 		RequestID: uuid.NewString(),
 		Type:      p2p.MsgHandshakeReq,
-		Payload:   handshakeJson,
+		Payload:   handshakeRaw,
 	}
 
 	// Kirim message dan tunggu balasan (blocking)
@@ -437,47 +430,79 @@ func (node *Node) startHandshake(address string) error {
 
 	// Parsing balasan
 	var respPayload p2p.HandshakePayload
-	if err := json.Unmarshal(responseMessage.Payload, &respPayload); err != nil {
+	if err := responseMessage.DecodePayload(&respPayload); err != nil {
+		return err
+	}
+
+	if err := node.verifyPeerIdentity(peer, respPayload); err != nil {
 		return err
 	}
 
 	// Register peer
 	node.mux.Lock()
-	node.peers[respPayload.NodeID] = respPayload.Secret
+	node.peers[respPayload.NodeID] = respPayload.PublicKey
 	node.mux.Unlock()
 
 	node.P2P.RemovePeer(address)
+	peer.SetWireVersion(p2p.NegotiateWireVersion(respPayload.WireVersion))
 	node.P2P.RegisterPeer(peer, respPayload.NodeID)
+	node.onPeerConnected(peer, respPayload)
+	connected = true
 
 	fmt.Printf("connecting & handshake to %s got id as such %s", address, respPayload.NodeID)
 
 	return nil
 }
 
+// Daftar peer disalin dengan lock karena peer yang terputus dihapus dari goroutine keepalive
 func (node *Node) Broadcast(message p2p.Message) {
-	nodeIDs := make([]string, 0, len(node.peers))
-	for k := range node.peers {
-		nodeIDs = append(nodeIDs, k)
-	}
-	node.P2P.Broadcast(message, nodeIDs)
+	node.P2P.Broadcast(message, node.connectedPeerIDs())
+}
+
+func (node *Node) Send(peerID string, message p2p.Message) error {
+	return node.P2P.Send(peerID, message)
 }
 
 func (node *Node) GetLatestBlock() types.Block {
 	return node.Blockchain.GetLatestBlock()
 }
 
-func (node *Node) CommitBlock(block types.Block) {
-	node.Blockchain.AddBlock(block)
+// Block yang tidak valid atau hasil eksekusinya berbeda dengan header ditolak tanpa mengubah world state
+func (node *Node) CommitBlock(block types.Block) error {
+	// Satu block di eksekusi dan disimpan dalam satu waktu (consensus & sync)
+	node.commitMux.Lock()
+	defer node.commitMux.Unlock()
 
-	node.Executor.ApplyBlock(block)
+	if err := node.Blockchain.ValidateNext(block); err != nil {
+		return err
+	}
+	if err := node.ValidateBlockTxs(block); err != nil {
+		return err
+	}
 
-	node.RemoveTxsByID(block.Transactions)
+	// Receipt hasil eksekusi disimpan bersama block
+	receipts, err := node.Executor.VerifyAndApplyBlock(block)
+	if err != nil {
+		return err
+	}
+	if err := node.Blockchain.AddBlock(block, receipts); err != nil {
+		// World state sudah berubah tapi block gagal disimpan / di index, replay dari disk saat restart
+		panic(fmt.Sprintf("failed to persist block %d after execution: %v", block.Header.Height, err))
+	}
+
+	// Side effect ke database dijalankan terpisah lewat outbox
+	if err := node.Outbox.Enqueue(block.Header.Height, smartcontract.BlockEvents(receipts)); err != nil {
+		fmt.Printf("failed to enqueue events of block %d: %v\n", block.Header.Height, err)
+	}
+
+	node.Mempool.Remove(block.Transactions)
+	node.revalidateMempool()
 
 	blockPayload := p2p.BlockPayload{
 		LatestHeight: node.Blockchain.GetLatestHeight(),
 		Block:        block,
 	}
-	blockPayloadRaw, _ := json.Marshal(blockPayload)
+	blockPayloadRaw, _ := p2p.EncodePayload(blockPayload)
 	node.Broadcast(p2p.Message{
 		SenderID:   node.ID,
 		RequestID:  uuid.NewString(),
@@ -485,10 +510,18 @@ func (node *Node) CommitBlock(block types.Block) {
 		Type:       p2p.MsgTypeBlockSend,
 		Payload:    blockPayloadRaw,
 	})
+	return nil
 }
 
+// Node termasuk validator set aktif di world state
 func (node *Node) IsValidator() bool {
-	return node.isValidator
+	_, isValidator := node.WorldState.GetValidator(node.ID)
+	return isValidator
+}
+
+// Validator set aktif (berubah lewat tx ADD_VALIDATOR / REMOVE_VALIDATOR)
+func (node *Node) Validators() []types.ValidatorConfig {
+	return node.WorldState.GetValidators()
 }
 
 // Sign dan return hex encoded signature
@@ -497,106 +530,242 @@ func (node *Node) SignData(data []byte) string {
 }
 
 func (node *Node) CreateBlock() types.Block {
-	node.txMux.RLock()
-	txCount := min(len(node.txPool), MaxBlockTxs)
-	txs := make([]types.Transaction, txCount)
-	copy(txs, node.txPool[:txCount]) // Make a copy
-	node.txMux.RUnlock()
+	// Tx yang akan membuat proposal ditolak validator (replay) tidak dimasukkan
+	checker := node.newReplayChecker()
+	txs := slices.DeleteFunc(node.Mempool.Reap(node.blockConfig.MaxBlockTxs, node.blockConfig.MaxBlockBytes), func(tx types.Transaction) bool {
+		return checker.check(tx) != nil
+	})
 
 	prevBlock := node.Blockchain.GetLatestBlock()
 
-	txRoot := calculateTxRoot(txs)
-	stateRoot := node.WorldState.CalculateHash()
-
 	header := types.BlockHeader{
 		Height:     prevBlock.Header.Height + 1,
-		Timestamp:  time.Now().Unix(),
+		Timestamp:  max(time.Now().Unix(), prevBlock.Header.Timestamp), // timestamp block tidak boleh mundur
 		PrevHash:   prevBlock.HeaderHash(),
-		StateRoot:  stateRoot,
-		TxRoot:     txRoot,
+		TxRoot:     types.CalculateTxRoot(txs),
 		ProposerID: node.ID,
 	}
 
-	return types.Block{
+	block := types.Block{
 		Header:       header,
 		Transactions: txs,
 		QC:           types.QuorumCertificate{},
 	}
+
+	// State root dan receipts root adalah hasil eksekusi tx block ini (dijalankan pada overlay world state)
+	block.Header.StateRoot, block.Header.ReceiptsRoot = node.CalculateRoots(block)
+
+	return block
 }
 
-func calculateTxRoot(txs []types.Transaction) string {
-	if len(txs) == 0 {
-		return strings.Repeat("0", 64)
+// Signature setiap tx diverifikasi ulang (leader bisa menyisipkan tx yang tidak lewat mempool kita).
+// Replay protection: id tx unik di block & seluruh chain, dan nonce setiap pengirim
+// harus lebih besar dari nonce tx sebelumnya (di state maupun di block yang sama)
+func (node *Node) ValidateBlockTxs(block types.Block) error {
+	checker := node.newReplayChecker()
+	for _, tx := range block.Transactions {
+		if err := node.verifyTransaction(tx); err != nil {
+			return fmt.Errorf("tx %s: %w", tx.ID, err)
+		}
+		if err := checker.check(tx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Pengecekan replay tx satu per satu sesuai urutan di block
+type replayChecker struct {
+	node   *Node
+	ids    map[string]bool   // id tx yang sudah ada di block
+	nonces map[string]uint64 // nonce terakhir pengirim di block
+}
+
+func (node *Node) newReplayChecker() *replayChecker {
+	return &replayChecker{
+		node:   node,
+		ids:    make(map[string]bool),
+		nonces: make(map[string]uint64),
+	}
+}
+
+func (c *replayChecker) check(tx types.Transaction) error {
+	if c.ids[tx.ID] || c.node.Blockchain.HasTx(tx.ID) {
+		return fmt.Errorf("%w: %s", ErrTxDuplicate, tx.ID)
 	}
-	data := ""
-	for _, tx := range txs {
-		data += tx.ID
+
+	last, exists := c.nonces[tx.SenderID]
+	if !exists {
+		last = c.node.WorldState.GetNonce(tx.SenderID)
+	}
+	if tx.Nonce <= last {
+		return fmt.Errorf("%w: tx %s from %s has nonce %d, expecting > %d", errStaleNonce, tx.ID, tx.SenderID, tx.Nonce, last)
 	}
-	hash := sha256.Sum256([]byte(data))
-	return hex.EncodeToString(hash[:])
+
+	c.ids[tx.ID] = true
+	c.nonces[tx.SenderID] = tx.Nonce
+	return nil
+}
+
+// Beri nonce, tandatangani, lalu kirim tx milik node ini.
+// Nonce menyambung nonce di state maupun tx pending di pool, tx yang gagal masuk pool tidak meninggalkan lubang
+func (node *Node) signAndSubmit(tx *types.Transaction) error {
+	node.nonceMux.Lock()
+	defer node.nonceMux.Unlock()
+
+	nonce := node.WorldState.GetNonce(node.ID)
+	if pending, exists := node.Mempool.LastNonce(node.ID); exists {
+		nonce = max(nonce, pending)
+	}
+
+	tx.Nonce = nonce + 1
+	tx.Signature = node.SignData([]byte(tx.Hash()))
+	return node.submitTransactionToNetwork(*tx)
+}
+
+// Hitung state root dan receipts root setelah tx block dieksekusi tanpa mengubah world state.
+// commitMux menahan commit block selama eksekusi berjalan di atas overlay
+func (node *Node) CalculateRoots(block types.Block) (string, string) {
+	node.commitMux.Lock()
+	defer node.commitMux.Unlock()
+
+	return node.Executor.CalculateRoots(block)
 }
 
 // Helper submit tx ke network
-func (node *Node) submitTransactionToNetwork(tx types.Transaction) {
-	node.AddTxToPool(tx)
+func (node *Node) submitTransactionToNetwork(tx types.Transaction) error {
+	if err := node.AddTxToPool(tx); err != nil {
+		return err
+	}
 
 	payload := p2p.TxGossipPayload{
 		Transaction: tx,
 	}
-	payloadJson, _ := json.Marshal(payload)
+	payloadRaw, _ := p2p.EncodePayload(payload)
 
 	msg := p2p.Message{
 		SenderID:  node.ID,
 		RequestID: uuid.NewString(),
 		Type:      p2p.MsgTypeTxGossip,
-		Payload:   payloadJson,
+		Payload:   payloadRaw,
 	}
 
 	// 3. Broadcast to peers
 	node.Broadcast(msg)
+	return nil
 }
 
-// Add tx to pool
-func (node *Node) AddTxToPool(tx types.Transaction) bool {
-	node.txMux.Lock()
-	defer node.txMux.Unlock()
+// Tx governance boleh dikirim validator aktif walau tidak terdaftar sebagai submitter
+func (node *Node) verifyTransaction(tx types.Transaction) error {
+	if tx.Type == types.TxTypeAddValidator || tx.Type == types.TxTypeRemoveValidator {
+		if validator, isValidator := node.WorldState.GetValidator(tx.SenderID); isValidator {
+			if err := utils.Verify(validator.PublicKey, tx.Signature, []byte(tx.Hash())); err != nil {
+				return fmt.Errorf("%w: %v", registry.ErrInvalidSignature, err)
+			}
+			return nil
+		}
+	}
+
+	if err := node.Registry.VerifyTransaction(tx); err != nil {
+		return err
+	}
 
-	_, exists := node.seenTxs[tx.ID]
-	if exists {
+	if tx.Type == types.TxTypeSetTariffVersion {
+		return node.verifyTariffVersion(tx)
+	}
+	return nil
+}
+
+// Versi tabel tarif baru harus dimuat di node ini dengan isi yang sama (hash cocok)
+func (node *Node) verifyTariffVersion(tx types.Transaction) error {
+	var payload types.TxSetTariffVersion
+	if err := json.Unmarshal(tx.Payload, &payload); err != nil {
+		return fmt.Errorf("%w: invalid payload: %v", errUnknownTariff, err)
+	}
+
+	if _, err := node.Executor.Tariffs.Verify(payload.Version, payload.Hash); err != nil {
+		return fmt.Errorf("%w: %v", errUnknownTariff, err)
+	}
+	return nil
+}
+
+// Add tx to pool setelah signature diverifikasi terhadap registry
+func (node *Node) AddTxToPool(tx types.Transaction) error {
+	// Dedup gossip sebelum verifikasi signature
+	if node.Mempool.Seen(tx.ID) || node.Blockchain.HasTx(tx.ID) {
 		fmt.Print("skipping tx because it already been seen\n")
-		return false
+		return mempool.ErrAlreadySeen
+	}
+
+	if err := node.verifyTransaction(tx); err != nil {
+		node.recordRejectedTx(tx, err)
+		return err
 	}
 
-	node.txPool = append(node.txPool, tx)
-	node.txMap[tx.ID] = tx
-	node.seenTxs[tx.ID] = nil
+	// Tx lama yang di broadcast ulang dengan id baru tetap memakai nonce lama
+	if nonce := node.WorldState.GetNonce(tx.SenderID); tx.Nonce <= nonce {
+		err := fmt.Errorf("%w: nonce %d, sender %s is at %d", errStaleNonce, tx.Nonce, tx.SenderID, nonce)
+		node.recordRejectedTx(tx, err)
+		return err
+	}
+
+	if err := node.Mempool.Add(tx, node.WorldState.GetNonce(tx.SenderID)); err != nil {
+		fmt.Printf("tx %s not added to the pool: %v\n", tx.ID, err)
+		return err
+	}
 
 	fmt.Print("added 1 tx to the pool\n")
-	return true
+	node.notifyPendingTxs()
+	return nil
 }
 
-func (node *Node) RemoveTxsByID(txs []types.Transaction) {
-	node.txMux.Lock()
-	defer node.txMux.Unlock()
-
-	// Build a set of IDs to remove
-	toRemove := make(map[string]bool)
-	for _, tx := range txs {
-		toRemove[tx.ID] = true
-	}
-
-	// Filter the pool
-	newPool := make([]types.Transaction, 0, len(node.txPool))
-	for _, tx := range node.txPool {
-		if !toRemove[tx.ID] {
-			newPool = append(newPool, tx)
-		} else {
-			delete(node.txMap, tx.ID)
-			node.seenTxs[tx.ID] = nil
-			fmt.Printf("🗑️  removed tx %s from pool\n", tx.ID)
+// Buang tx di pool yang tidak lagi valid setelah block di commit
+// (sudah masuk chain, pengirim tidak lagi berhak, atau kedaluwarsa)
+func (node *Node) revalidateMempool() {
+	removed := node.Mempool.Revalidate(func(tx types.Transaction) error {
+		if node.Blockchain.HasTx(tx.ID) {
+			return errors.New("already committed")
 		}
+		if nonce := node.WorldState.GetNonce(tx.SenderID); tx.Nonce <= nonce {
+			return fmt.Errorf("%w: nonce %d, sender %s is at %d", errStaleNonce, tx.Nonce, tx.SenderID, nonce)
+		}
+		return node.verifyTransaction(tx)
+	})
+
+	if removed > 0 {
+		fmt.Printf("📊 Pool size after revalidation: %d (%d dropped)\n", node.Mempool.Size(), removed)
+	}
+}
+
+// Hitung dan log tx yang ditolak beserta alasannya
+func (node *Node) recordRejectedTx(tx types.Transaction, err error) {
+	reason := "MALFORMED"
+	switch {
+	case errors.Is(err, registry.ErrUnknownSender):
+		reason = "UNKNOWN_SENDER"
+	case errors.Is(err, registry.ErrInvalidSignature):
+		reason = "INVALID_SIGNATURE"
+	case errors.Is(err, errStaleNonce):
+		reason = "STALE_NONCE"
 	}
 
-	node.txPool = newPool
-	fmt.Printf("📊 Pool size after removal: %d\n", len(node.txPool))
+	node.txMux.Lock()
+	node.rejectedTxs[reason]++
+	count := node.rejectedTxs[reason]
+	node.txMux.Unlock()
+
+	fmt.Printf("🚫 rejected tx %s from %s (%s, total %d): %v\n", tx.ID, tx.SenderID, reason, count, err)
+}
+
+// Snapshot jumlah tx yang ditolak per alasan
+func (node *Node) RejectedTxStats() map[string]uint64 {
+	node.txMux.RLock()
+	defer node.txMux.RUnlock()
+
+	stats := make(map[string]uint64, len(node.rejectedTxs))
+	for reason, count := range node.rejectedTxs {
+		stats[reason] = count
+	}
+	return stats
 }
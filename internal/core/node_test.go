@@ -0,0 +1,181 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bpjs-hackathon/sehat-chain/internal/mempool"
+	"github.com/bpjs-hackathon/sehat-chain/internal/p2p"
+	smartcontract "github.com/bpjs-hackathon/sehat-chain/internal/smart_contract"
+	"github.com/bpjs-hackathon/sehat-chain/types"
+	"github.com/bpjs-hackathon/sehat-chain/utils"
+	"github.com/google/uuid"
+)
+
+func testCred(t *testing.T) *utils.CryptoCred {
+	t.Helper()
+
+	cred, err := utils.GenerateCred()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cred
+}
+
+// Genesis dengan satu validator (juga admin BPJS) dan satu faskes
+func testGenesis(validator *utils.CryptoCred, faskes *utils.CryptoCred) *types.Genesis {
+	return &types.Genesis{
+		ChainID:     "sehat-test",
+		GenesisTime: 1700000000,
+		Validators: []types.ValidatorConfig{
+			{ID: "validator-1", PublicKey: validator.PublicKey(), Address: "127.0.0.1:0"},
+		},
+		Submitters: []types.SubmitterConfig{
+			{ID: "validator-1", Role: types.RoleBPJSAdmin, PublicKey: validator.PublicKey()},
+			{ID: "faskes-1", Role: types.RoleFaskes, PublicKey: faskes.PublicKey(), RegionalTier: "1"},
+		},
+		TariffVersion: smartcontract.MockTariffVersion,
+		TariffHash:    smartcontract.NewMockTariffEngine().Hash(),
+	}
+}
+
+// Node validator-1 dengan data dir sementara, p2p belum dibuka
+func testNode(t *testing.T, cred *utils.CryptoCred, genesis *types.Genesis) *Node {
+	t.Helper()
+
+	tariffs := smartcontract.NewTariffRegistry(smartcontract.NewMockTariffEngine())
+	node, err := CreateNode("validator-1", cred, freePort(t), freePort(t), t.TempDir(), genesis, tariffs, mempool.Config{}, BlockProductionConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { node.Blockchain.Close() })
+	return node
+}
+
+func freePort(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	return port
+}
+
+func TestStartHandshakeDisconnectsMismatchedGenesis(t *testing.T) {
+	validator := testCred(t)
+	node := testNode(t, validator, testGenesis(validator, testCred(t)))
+
+	// Peer remote menjawab handshake dengan genesis hash chain lain
+	remoteCred := testCred(t)
+	cert, err := remoteCred.TLSCertificate("remote-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remotePort := freePort(t)
+	remote := p2p.CreateP2PManager("remote-1", remotePort, cert)
+
+	disconnected := make(chan string, 1)
+	remote.OnDisconnect(func(peerID string) { disconnected <- peerID })
+	remote.Subscribe(func(peer *p2p.Peer, message p2p.Message) {
+		if message.Type != p2p.MsgHandshakeReq {
+			return
+		}
+		payload, _ := p2p.EncodePayload(p2p.HandshakePayload{
+			NodeID:      "remote-1",
+			Port:        remotePort,
+			PublicKey:   remoteCred.PublicKey(),
+			ChainID:     node.Genesis.ChainID,
+			GenesisHash: "other-genesis",
+			WireVersion: p2p.WireVersionCurrent,
+		})
+		response := p2p.Message{
+			SenderID:   "remote-1",
+			Type:       p2p.MsgHandshakeResp,
+			RequestID:  message.RequestID,
+			ResponseID: message.RequestID,
+			Payload:    payload,
+		}
+		if err := peer.RespondHandshake(response, p2p.WireVersionCurrent); err != nil {
+			t.Error(err)
+			return
+		}
+		remote.RegisterPeer(peer, message.SenderID)
+	})
+	if err := remote.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := node.startHandshake("127.0.0.1:" + remotePort); err == nil {
+		t.Fatal("handshake with mismatched genesis accepted")
+	}
+
+	node.P2P.PeersMux.RLock()
+	peers := len(node.P2P.Peers)
+	node.P2P.PeersMux.RUnlock()
+	if peers != 0 {
+		t.Fatalf("%d peers left after rejected handshake", peers)
+	}
+
+	// Koneksi benar-benar ditutup: remote melihat node terputus
+	select {
+	case peerID := <-disconnected:
+		if peerID != node.ID {
+			t.Fatalf("remote dropped %s, want %s", peerID, node.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection to mismatched peer left open")
+	}
+}
+
+func TestSetTariffVersionRequiresLoadedTable(t *testing.T) {
+	mock := smartcontract.NewMockTariffEngine()
+
+	tests := []struct {
+		name    string
+		version string
+		hash    string
+		valid   bool
+	}{
+		{"loaded table", mock.Version(), mock.Hash(), true},
+		{"unknown version", "ina-cbg-2099", mock.Hash(), false},
+		{"hash differs", mock.Version(), "other-hash", false},
+		{"empty hash", mock.Version(), "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := testCred(t)
+			node := testNode(t, validator, testGenesis(validator, testCred(t)))
+
+			payload, _ := json.Marshal(types.TxSetTariffVersion{Version: tt.version, Hash: tt.hash})
+			tx := types.Transaction{
+				ID:        uuid.NewString(),
+				Type:      types.TxTypeSetTariffVersion,
+				Timestamp: time.Now().Unix(),
+				SenderID:  "validator-1",
+				Nonce:     1,
+				Payload:   payload,
+			}
+			tx.Signature = validator.Sign([]byte(tx.Hash()))
+
+			// Ditolak di mempool maupun saat validasi block dari leader lain
+			poolErr := node.AddTxToPool(tx)
+			blockErr := node.ValidateBlockTxs(nextBlock(node, tx))
+			for _, err := range []error{poolErr, blockErr} {
+				if tt.valid && err != nil {
+					t.Fatalf("tariff version rejected: %v", err)
+				}
+				if !tt.valid && !errors.Is(err, errUnknownTariff) {
+					t.Fatalf("err %v, want errUnknownTariff", err)
+				}
+			}
+		})
+	}
+}
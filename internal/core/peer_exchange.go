@@ -0,0 +1,119 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/bpjs-hackathon/sehat-chain/internal/p2p"
+	"github.com/google/uuid"
+)
+
+const (
+	TargetPeerCount      = 8                // jumlah peer yang dijaga node
+	PeerExchangeInterval = 30 * time.Second // interval meminta daftar peer & dial peer baru
+)
+
+// Catat peer yang berhasil handshake di address book
+func (node *Node) onPeerConnected(peer *p2p.Peer, handshake p2p.HandshakePayload) {
+	// Peer yang kita dial: alamat yang kita pakai. Peer masuk: ip koneksi + port yang diumumkan
+	if peer.Address != "" {
+		peer.ListenAddress = peer.Address
+	} else if host := peer.RemoteHost(); host != "" && handshake.Port != "" {
+		peer.ListenAddress = net.JoinHostPort(host, handshake.Port)
+	}
+
+	node.AddressBook.MarkSeen(handshake.NodeID, peer.ListenAddress)
+
+	// Validator yang (kembali) terhubung mungkin punya block yang terlewat selama terputus.
+	// Dijalankan terpisah karena request sync dibalas lewat read loop peer ini
+	if _, isValidator := node.WorldState.GetValidator(handshake.NodeID); isValidator {
+		go node.triggerSync()
+	}
+}
+
+// Loop peer exchange: minta daftar peer ke semua peer terhubung lalu
+// dial alamat dari address book sampai jumlah peer mencapai TargetPeerCount
+func (node *Node) runPeerExchange() {
+	ticker := time.NewTicker(PeerExchangeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		node.exchangePeers()
+		node.dialNewPeers()
+
+		if err := node.AddressBook.Save(); err != nil {
+			fmt.Printf("⚠️ Failed to save address book: %v\n", err)
+		}
+	}
+}
+
+func (node *Node) exchangePeers() {
+	message := p2p.Message{
+		SenderID:  node.ID,
+		RequestID: uuid.NewString(),
+		Type:      p2p.MsgTypePeersReq,
+	}
+
+	for _, peerID := range node.connectedPeerIDs() {
+		message.RequestID = uuid.NewString()
+		resp, err := node.P2P.Request(peerID, message, 3*time.Second)
+		if err != nil {
+			continue
+		}
+
+		var peerPayload p2p.PeerPayload
+		if err := resp.DecodePayload(&peerPayload); err != nil {
+			continue
+		}
+
+		learned := 0
+		for id, address := range peerPayload.Peers {
+			if id == node.ID {
+				continue
+			}
+			if node.AddressBook.Add(id, address) {
+				learned++
+			}
+		}
+		if learned > 0 {
+			fmt.Printf("📒 Learned %d peer address(es) from %s\n", learned, peerID)
+		}
+	}
+}
+
+func (node *Node) dialNewPeers() {
+	connected := make(map[string]bool)
+	for _, peerID := range node.connectedPeerIDs() {
+		connected[peerID] = true
+	}
+
+	for _, entry := range node.AddressBook.Entries() {
+		if len(connected) >= TargetPeerCount {
+			return
+		}
+		if entry.ID == node.ID || connected[entry.ID] {
+			continue
+		}
+
+		if err := node.startHandshake(entry.Address); err != nil {
+			node.AddressBook.MarkFailed(entry.ID)
+			continue
+		}
+
+		connected[entry.ID] = true
+		fmt.Printf("🔗 Connected to discovered peer %s (%s)\n", entry.ID, entry.Address)
+	}
+}
+
+// Id peer yang sudah handshake
+func (node *Node) connectedPeerIDs() []string {
+	node.mux.RLock()
+	defer node.mux.RUnlock()
+
+	ids := make([]string, 0, len(node.peers))
+	for id := range node.peers {
+		ids = append(ids, id)
+	}
+	return ids
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	minRedialBackoff = 1 * time.Second
+	maxRedialBackoff = 2 * time.Minute
+)
+
+// Peer terputus: hapus dari daftar peer yang menerima broadcast
+func (node *Node) handlePeerDisconnected(peerID string) {
+	node.mux.Lock()
+	delete(node.peers, peerID)
+	node.mux.Unlock()
+}
+
+// Jaga koneksi ke validator aktif. Validator yang terputus di dial ulang
+// dengan exponential backoff (ditambah jitter agar dua node tidak saling dial bersamaan)
+func (node *Node) maintainValidatorConnections() {
+	backoff := make(map[string]time.Duration)
+	nextDial := make(map[string]time.Time)
+
+	ticker := time.NewTicker(minRedialBackoff)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		connected := make(map[string]bool)
+		for _, peerID := range node.connectedPeerIDs() {
+			connected[peerID] = true
+		}
+
+		for _, validator := range node.Validators() {
+			if validator.ID == node.ID {
+				continue
+			}
+
+			if connected[validator.ID] {
+				delete(backoff, validator.ID)
+				delete(nextDial, validator.ID)
+				continue
+			}
+
+			if time.Now().Before(nextDial[validator.ID]) {
+				continue
+			}
+
+			if err := node.startHandshake(validator.Address); err == nil {
+				fmt.Printf("🔁 Reconnected to validator %s\n", validator.ID)
+				delete(backoff, validator.ID)
+				delete(nextDial, validator.ID)
+				continue
+			}
+
+			delay := min(max(backoff[validator.ID]*2, minRedialBackoff), maxRedialBackoff)
+			backoff[validator.ID] = delay
+			jitter := time.Duration(rand.Int64N(int64(delay) / 5))
+			nextDial[validator.ID] = time.Now().Add(delay + jitter)
+			fmt.Printf("⚠️ Validator %s unreachable, retrying in %s\n", validator.ID, (delay + jitter).Round(time.Millisecond))
+		}
+	}
+}
@@ -0,0 +1,346 @@
+// Sinkronisasi chain dengan peer: header dulu, lalu isi block paralel per range
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bpjs-hackathon/sehat-chain/internal/p2p"
+	"github.com/bpjs-hackathon/sehat-chain/types"
+	"github.com/google/uuid"
+)
+
+const (
+	HeaderBatchSize    = 512             // jumlah header per request (dan per putaran sync)
+	BlockRangeSize     = 32              // jumlah block per BLOCK_RANGE_REQUEST
+	SyncWorkers        = 4               // range yang diunduh bersamaan
+	SyncRequestTimeout = 5 * time.Second // timeout satu request sync ke peer
+	syncRetryDelay     = 2 * time.Second
+
+	// Batas ukuran balasan range, sisa block diminta ulang oleh peer yang sync
+	maxBlockRangeBytes = p2p.MaxFrameSize / 2
+)
+
+var errNoSyncPeer = errors.New("no peer could serve the request")
+
+// Tanya height terbaru ke peer lalu mulai sync jika tertinggal
+func (node *Node) triggerSync() {
+	currentHeight := node.Blockchain.GetLatestHeight()
+	heights := node.peerHeights()
+
+	var maxNetworkHeight uint64 = 0
+	for _, height := range heights {
+		maxNetworkHeight = max(maxNetworkHeight, height)
+	}
+
+	if maxNetworkHeight > currentHeight {
+		fmt.Printf("📉 Node behind! Current: %d, Network: %d. Starting sync...\n", currentHeight, maxNetworkHeight)
+		go node.syncChain()
+	} else {
+		fmt.Println("✅ Node up-to-date.")
+	}
+}
+
+// Block, QC, atau proposal untuk height di depan height berikutnya kita berarti
+// ada block yang terlewat (misal saat terputus), consensus hanya menerima latest+1
+func (node *Node) syncIfBehind(height uint64) {
+	latest := node.Blockchain.GetLatestHeight()
+	if height <= latest+1 || node.syncing.Load() {
+		return
+	}
+
+	fmt.Printf("📉 Got message for height %d at height %d. Starting sync...\n", height, latest)
+	go node.syncChain()
+}
+
+// Height terbaru setiap peer yang terhubung (peer yang tidak membalas diabaikan)
+func (node *Node) peerHeights() map[string]uint64 {
+	heights := make(map[string]uint64)
+	var mux sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, peerID := range node.connectedPeerIDs() {
+		wg.Add(1)
+		go func(peerID string) {
+			defer wg.Done()
+
+			headers, err := node.requestHeaders(peerID, 0, 0)
+			if err != nil {
+				return
+			}
+
+			mux.Lock()
+			heights[peerID] = headers.LatestHeight
+			mux.Unlock()
+		}(peerID)
+	}
+
+	wg.Wait()
+	return heights
+}
+
+func (node *Node) syncChain() {
+	// Hanya satu sync berjalan, trigger lain cukup menunggu sync ini selesai
+	if !node.syncing.CompareAndSwap(false, true) {
+		return
+	}
+	defer node.syncing.Store(false)
+
+	for {
+		currentHeight := node.Blockchain.GetLatestHeight()
+
+		// Peer yang punya block setelah height kita
+		var targetHeight uint64
+		peers := make([]string, 0)
+		for peerID, height := range node.peerHeights() {
+			if height > currentHeight {
+				peers = append(peers, peerID)
+				targetHeight = max(targetHeight, height)
+			}
+		}
+
+		if len(peers) == 0 {
+			fmt.Println("✅ Sync Complete!")
+			return
+		}
+
+		to := min(targetHeight, currentHeight+HeaderBatchSize)
+		fmt.Printf("🔄 Syncing blocks %d-%d (network height %d, %d peers)\n", currentHeight+1, to, targetHeight, len(peers))
+
+		headers, err := node.downloadHeaders(peers, currentHeight+1, to)
+		if err == nil {
+			err = node.downloadBlocks(peers, headers)
+		}
+		if err != nil {
+			fmt.Printf("⚠️ Sync from height %d failed: %v. Retrying...\n", currentHeight+1, err)
+			time.Sleep(syncRetryDelay)
+		}
+	}
+}
+
+// Unduh header [from, to] dan pastikan tersambung ke block terakhir kita.
+// Signature QC baru diverifikasi saat commit karena validator set bisa berubah di tengah range
+func (node *Node) downloadHeaders(peers []string, from uint64, to uint64) ([]p2p.SyncHeader, error) {
+	headers := make([]p2p.SyncHeader, 0, to-from+1)
+	latest := node.GetLatestBlock()
+	prevHash := latest.HeaderHash()
+	peerIndex := 0
+	failures := 0
+
+	for next := from; next <= to; {
+		if failures == len(peers) {
+			return nil, fmt.Errorf("headers from %d: %w", next, errNoSyncPeer)
+		}
+
+		peerID := peers[peerIndex%len(peers)]
+		resp, err := node.requestHeaders(peerID, next, min(to-next+1, HeaderBatchSize))
+		if err == nil && len(resp.Headers) == 0 {
+			err = fmt.Errorf("peer returned no headers")
+		}
+		if err == nil {
+			err = verifyHeaderChain(resp.Headers, next, prevHash)
+		}
+		if err != nil {
+			fmt.Printf("header sync from %s failed: %v\n", peerID, err)
+			peerIndex++
+			failures++
+			continue
+		}
+
+		// Peer bisa mengirim lebih dari yang kita butuhkan
+		batch := resp.Headers[:min(uint64(len(resp.Headers)), to-next+1)]
+		headers = append(headers, batch...)
+		prevHash = syncHeaderHash(batch[len(batch)-1])
+		next += uint64(len(batch))
+		failures = 0
+	}
+
+	return headers, nil
+}
+
+// Header harus berurutan, saling tersambung lewat PrevHash,
+// dan masing-masing membawa QC COMMIT untuk header tersebut
+func verifyHeaderChain(headers []p2p.SyncHeader, from uint64, prevHash string) error {
+	for i, header := range headers {
+		height := from + uint64(i)
+		if header.Header.Height != height {
+			return fmt.Errorf("expecting header %d, got %d", height, header.Header.Height)
+		}
+
+		if header.Header.PrevHash != prevHash {
+			return fmt.Errorf("header %d does not link to previous header", height)
+		}
+
+		hash := syncHeaderHash(header)
+		if header.QC.VoteType != types.VoteTypeCommit || header.QC.HeaderHash != hash || header.QC.Height != height {
+			return fmt.Errorf("header %d has no commit QC", height)
+		}
+
+		prevHash = hash
+	}
+
+	return nil
+}
+
+func syncHeaderHash(header p2p.SyncHeader) string {
+	block := types.Block{Header: header.Header}
+	return block.HeaderHash()
+}
+
+// Unduh isi block per range secara paralel dari beberapa peer lalu commit berurutan
+func (node *Node) downloadBlocks(peers []string, headers []p2p.SyncHeader) error {
+	ranges := make([][]p2p.SyncHeader, 0, len(headers)/BlockRangeSize+1)
+	for start := 0; start < len(headers); start += BlockRangeSize {
+		ranges = append(ranges, headers[start:min(start+BlockRangeSize, len(headers))])
+	}
+
+	// Hasil setiap range (nil jika gagal dari semua peer)
+	results := make([]chan []types.Block, len(ranges))
+	for i := range results {
+		results[i] = make(chan []types.Block, 1)
+	}
+
+	jobs := make(chan int, len(ranges))
+	for i := range ranges {
+		jobs <- i
+	}
+	close(jobs)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	for w := 0; w < min(SyncWorkers, len(ranges)); w++ {
+		go func() {
+			for i := range jobs {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				// Range dibagi rata ke peer, pindah ke peer lain jika gagal
+				var blocks []types.Block
+				for attempt := 0; attempt < len(peers); attempt++ {
+					peerID := peers[(i+attempt)%len(peers)]
+
+					fetched, err := node.fetchBlockRange(peerID, ranges[i])
+					if err != nil {
+						fmt.Printf("block range %d-%d from %s failed: %v\n", ranges[i][0].Header.Height, ranges[i][len(ranges[i])-1].Header.Height, peerID, err)
+						continue
+					}
+
+					blocks = fetched
+					break
+				}
+				results[i] <- blocks
+			}
+		}()
+	}
+
+	for i := range ranges {
+		blocks := <-results[i]
+		if blocks == nil {
+			return fmt.Errorf("block range from %d: %w", ranges[i][0].Header.Height, errNoSyncPeer)
+		}
+
+		for _, block := range blocks {
+			if err := node.commitSyncedBlock(block); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Minta range block dan cocokkan dengan header yang sudah diverifikasi.
+// Balasan parsial dilanjutkan dengan request berikutnya ke peer yang sama
+func (node *Node) fetchBlockRange(peerID string, headers []p2p.SyncHeader) ([]types.Block, error) {
+	blocks := make([]types.Block, 0, len(headers))
+
+	for len(blocks) < len(headers) {
+		expected := headers[len(blocks):]
+		reqPayload := p2p.BlockRangeRequestPayload{
+			From: expected[0].Header.Height,
+			To:   expected[len(expected)-1].Header.Height,
+		}
+		reqPayloadRaw, _ := p2p.EncodePayload(reqPayload)
+
+		resp, err := node.P2P.Request(peerID, p2p.Message{
+			SenderID:  node.ID,
+			RequestID: uuid.NewString(),
+			Type:      p2p.MsgTypeBlockRangeReq,
+			Payload:   reqPayloadRaw,
+		}, SyncRequestTimeout)
+		if err != nil {
+			return nil, err
+		}
+
+		var rangePayload p2p.BlockRangePayload
+		if err := resp.DecodePayload(&rangePayload); err != nil {
+			return nil, err
+		}
+		if len(rangePayload.Blocks) == 0 || len(rangePayload.Blocks) > len(expected) {
+			return nil, fmt.Errorf("peer returned %d blocks for %d requested", len(rangePayload.Blocks), len(expected))
+		}
+
+		for i, block := range rangePayload.Blocks {
+			if block.HeaderHash() != syncHeaderHash(expected[i]) {
+				return nil, fmt.Errorf("block %d does not match its header", expected[i].Header.Height)
+			}
+
+			if txRoot := types.CalculateTxRoot(block.Transactions); block.Header.TxRoot != txRoot {
+				return nil, fmt.Errorf("invalid tx root for block %d. Expecting %s, got %s", block.Header.Height, block.Header.TxRoot, txRoot)
+			}
+		}
+
+		blocks = append(blocks, rangePayload.Blocks...)
+	}
+
+	return blocks, nil
+}
+
+// QC diverifikasi terhadap validator set hasil block sebelumnya, lalu block di commit
+func (node *Node) commitSyncedBlock(block types.Block) error {
+	height := block.Header.Height
+
+	// Block sudah di commit lewat consensus selama sync berjalan
+	if height <= node.Blockchain.GetLatestHeight() {
+		return nil
+	}
+
+	if err := node.Consensus.VerifyCommitQC(block); err != nil {
+		return fmt.Errorf("invalid QC for block %d: %w", height, err)
+	}
+
+	if err := node.CommitBlock(block); err != nil {
+		return fmt.Errorf("block %d was not committed: %w", height, err)
+	}
+	node.Consensus.ResetAfterSync()
+
+	return nil
+}
+
+func (node *Node) requestHeaders(peerID string, from uint64, count uint64) (p2p.HeadersPayload, error) {
+	reqPayload := p2p.HeadersRequestPayload{From: from, Count: count}
+	reqPayloadRaw, _ := p2p.EncodePayload(reqPayload)
+
+	resp, err := node.P2P.Request(peerID, p2p.Message{
+		SenderID:  node.ID,
+		RequestID: uuid.NewString(),
+		Type:      p2p.MsgTypeHeadersReq,
+		Payload:   reqPayloadRaw,
+	}, SyncRequestTimeout)
+	if err != nil {
+		return p2p.HeadersPayload{}, err
+	}
+
+	var headers p2p.HeadersPayload
+	if err := resp.DecodePayload(&headers); err != nil {
+		return p2p.HeadersPayload{}, err
+	}
+
+	return headers, nil
+}
@@ -0,0 +1,350 @@
+// Pool tx yang menunggu masuk block
+package mempool
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bpjs-hackathon/sehat-chain/types"
+)
+
+const (
+	DefaultCapacity      = 5000
+	DefaultMaxPerSender  = 500
+	DefaultTTL           = 10 * time.Minute
+	DefaultSeenCacheSize = 50000
+)
+
+var (
+	ErrAlreadySeen = errors.New("tx already seen")
+	ErrPoolFull    = errors.New("mempool is full")
+	ErrSenderLimit = errors.New("too many pending txs from sender")
+	ErrNonceInUse  = errors.New("nonce already used by a pending tx")
+	ErrNonceGap    = errors.New("nonce skips pending txs of sender")
+)
+
+// Prioritas tx saat disusun ke block, lebih besar lebih dulu.
+// Tx dari pengirim yang sama tetap berurutan sesuai nonce (visit sebelum claim-nya)
+var txPriority = map[string]int{
+	types.TxTypeAddValidator:     3,
+	types.TxTypeRemoveValidator:  3,
+	types.TxTypeSetTariffVersion: 3,
+	types.TxTypeExecuteClaim:     2,
+	types.TxTypeSubmitClaim:      1,
+	types.TxTypeCreateRujukan:    1,
+	types.TxTypeRedeemRujukan:    1,
+	types.TxTypeRecordVisit:      0,
+}
+
+func Priority(txType string) int {
+	return txPriority[txType]
+}
+
+// Nilai 0 diganti default
+type Config struct {
+	Capacity      int   `json:"capacity"`        // jumlah tx maksimal di pool
+	MaxPerSender  int   `json:"max_per_sender"`  // tx pending maksimal per pengirim
+	TTLSeconds    int64 `json:"ttl_seconds"`     // tx yang belum masuk block setelah TTL dibuang
+	SeenCacheSize int   `json:"seen_cache_size"` // jumlah id tx yang diingat untuk dedup gossip
+}
+
+type entry struct {
+	tx       types.Transaction
+	priority int
+	addedAt  time.Time
+	seq      uint64 // urutan masuk pool
+	size     int    // ukuran tx (json) dalam byte
+}
+
+type Mempool struct {
+	capacity     int
+	maxPerSender int
+	ttl          time.Duration
+
+	txs     map[string]*entry   // id tx -> entry
+	senders map[string][]*entry // tx pending per pengirim, urut nonce
+	seen    *seenCache
+	seq     uint64
+	bytes   int // total ukuran tx di pool
+	mux     sync.RWMutex
+}
+
+func New(config Config) *Mempool {
+	mp := &Mempool{
+		capacity:     config.Capacity,
+		maxPerSender: config.MaxPerSender,
+		ttl:          time.Duration(config.TTLSeconds) * time.Second,
+		txs:          make(map[string]*entry),
+		senders:      make(map[string][]*entry),
+	}
+
+	if mp.capacity <= 0 {
+		mp.capacity = DefaultCapacity
+	}
+	if mp.maxPerSender <= 0 {
+		mp.maxPerSender = DefaultMaxPerSender
+	}
+	if mp.ttl <= 0 {
+		mp.ttl = DefaultTTL
+	}
+
+	seenCacheSize := config.SeenCacheSize
+	if seenCacheSize <= 0 {
+		seenCacheSize = DefaultSeenCacheSize
+	}
+	mp.seen = newSeenCache(seenCacheSize)
+
+	return mp
+}
+
+// Tambah tx yang sudah diverifikasi signature-nya.
+// Jika pool penuh, tx terakhir dengan prioritas paling rendah dibuang demi tx berprioritas lebih tinggi
+func (mp *Mempool) Add(tx types.Transaction, stateNonce uint64) error {
+	mp.mux.Lock()
+	defer mp.mux.Unlock()
+
+	if mp.seen.has(tx.ID) {
+		return ErrAlreadySeen
+	}
+
+	if len(mp.senders[tx.SenderID]) >= mp.maxPerSender {
+		return fmt.Errorf("%w %s (limit %d)", ErrSenderLimit, tx.SenderID, mp.maxPerSender)
+	}
+
+	// Dua tx dengan nonce sama tidak bisa masuk chain bersamaan
+	last := stateNonce
+	for _, pending := range mp.senders[tx.SenderID] {
+		if pending.tx.Nonce == tx.Nonce {
+			return fmt.Errorf("%w: %d by %s", ErrNonceInUse, tx.Nonce, pending.tx.ID)
+		}
+		last = max(last, pending.tx.Nonce)
+	}
+
+	// Nonce harus menyambung nonce terakhir pengirim (state atau tx pending)
+	if tx.Nonce > last+1 {
+		return fmt.Errorf("%w: nonce %d from %s, expecting at most %d", ErrNonceGap, tx.Nonce, tx.SenderID, last+1)
+	}
+
+	newEntry := &entry{
+		tx:       tx,
+		priority: Priority(tx.Type),
+		addedAt:  time.Now(),
+		seq:      mp.seq,
+		size:     TxSize(tx),
+	}
+
+	if len(mp.txs) >= mp.capacity {
+		mp.expire()
+	}
+	if len(mp.txs) >= mp.capacity {
+		victim := mp.evictionCandidate()
+		if victim == nil || victim.priority >= newEntry.priority {
+			return fmt.Errorf("%w (capacity %d)", ErrPoolFull, mp.capacity)
+		}
+
+		mp.remove(victim.tx.ID)
+		fmt.Printf("♻️ evicted tx %s (%s) from full mempool\n", victim.tx.ID, victim.tx.Type)
+	}
+
+	mp.seq++
+	mp.txs[tx.ID] = newEntry
+	mp.senders[tx.SenderID] = insertByNonce(mp.senders[tx.SenderID], newEntry)
+	mp.bytes += newEntry.size
+	mp.seen.add(tx.ID)
+
+	return nil
+}
+
+// Ambil maksimal maxTxs tx dengan total maxBytes berdasarkan prioritas tanpa menghapusnya dari pool.
+// Antar pengirim diurutkan prioritas lalu waktu masuk, tx satu pengirim tetap urut nonce
+func (mp *Mempool) Reap(maxTxs int, maxBytes int) []types.Transaction {
+	mp.mux.RLock()
+	defer mp.mux.RUnlock()
+
+	next := make(map[string]int, len(mp.senders)) // pengirim -> index tx berikutnya
+	txs := make([]types.Transaction, 0, min(maxTxs, len(mp.txs)))
+	size := 0
+
+	for len(txs) < maxTxs {
+		var best *entry
+		for sender, queue := range mp.senders {
+			if next[sender] >= len(queue) {
+				continue
+			}
+
+			candidate := queue[next[sender]]
+			if best == nil || candidate.before(best) {
+				best = candidate
+			}
+		}
+
+		// Berhenti di tx pertama yang melebihi budget agar urutan tetap sama.
+		// Tx pertama selalu diambil agar tx yang lebih besar dari budget tidak tertahan selamanya
+		if best == nil || (len(txs) > 0 && size+best.size > maxBytes) {
+			break
+		}
+
+		size += best.size
+		txs = append(txs, best.tx)
+		next[best.tx.SenderID]++
+	}
+
+	return txs
+}
+
+// Hapus tx yang sudah masuk block. Id tetap diingat agar gossip ulang diabaikan
+func (mp *Mempool) Remove(txs []types.Transaction) {
+	mp.mux.Lock()
+	defer mp.mux.Unlock()
+
+	for _, tx := range txs {
+		mp.remove(tx.ID)
+		mp.seen.add(tx.ID)
+	}
+}
+
+// Buang tx yang kedaluwarsa atau tidak lolos check terhadap state terbaru.
+// Dipanggil setelah setiap commit block, return jumlah tx yang dibuang
+func (mp *Mempool) Revalidate(check func(tx types.Transaction) error) int {
+	mp.mux.Lock()
+	defer mp.mux.Unlock()
+
+	removed := mp.expire()
+	for id, e := range mp.txs {
+		if err := check(e.tx); err != nil {
+			fmt.Printf("🗑️ dropping tx %s from mempool: %v\n", id, err)
+			mp.remove(id)
+			removed++
+		}
+	}
+
+	return removed
+}
+
+// Tx pernah diterima (masih di pool, sudah masuk block, atau sudah dibuang)
+func (mp *Mempool) Seen(id string) bool {
+	mp.mux.RLock()
+	defer mp.mux.RUnlock()
+
+	return mp.seen.has(id)
+}
+
+// Nonce terbesar tx pending milik pengirim
+func (mp *Mempool) LastNonce(senderID string) (uint64, bool) {
+	mp.mux.RLock()
+	defer mp.mux.RUnlock()
+
+	queue := mp.senders[senderID]
+	if len(queue) == 0 {
+		return 0, false
+	}
+	return queue[len(queue)-1].tx.Nonce, true
+}
+
+func (mp *Mempool) Get(id string) (types.Transaction, bool) {
+	mp.mux.RLock()
+	defer mp.mux.RUnlock()
+
+	e, exists := mp.txs[id]
+	if !exists {
+		return types.Transaction{}, false
+	}
+	return e.tx, true
+}
+
+func (mp *Mempool) Size() int {
+	mp.mux.RLock()
+	defer mp.mux.RUnlock()
+
+	return len(mp.txs)
+}
+
+// Total ukuran tx di pool dalam byte
+func (mp *Mempool) Bytes() int {
+	mp.mux.RLock()
+	defer mp.mux.RUnlock()
+
+	return mp.bytes
+}
+
+// Ukuran tx saat dikirim dalam block (json)
+func TxSize(tx types.Transaction) int {
+	data, _ := json.Marshal(tx)
+	return len(data)
+}
+
+// Buang tx yang lebih lama dari TTL
+func (mp *Mempool) expire() int {
+	deadline := time.Now().Add(-mp.ttl)
+
+	removed := 0
+	for id, e := range mp.txs {
+		if e.addedAt.Before(deadline) {
+			fmt.Printf("⌛ tx %s expired in mempool\n", id)
+			mp.remove(id)
+			removed++
+		}
+	}
+
+	return removed
+}
+
+// Tx terakhir (nonce terbesar) setiap pengirim dengan prioritas terendah (paling baru jika sama).
+// Hanya tx terakhir yang dibuang agar urutan tx pengirim tidak berlubang
+func (mp *Mempool) evictionCandidate() *entry {
+	var victim *entry
+	for _, queue := range mp.senders {
+		tail := queue[len(queue)-1]
+		if victim == nil || victim.before(tail) {
+			victim = tail
+		}
+	}
+
+	return victim
+}
+
+func (mp *Mempool) remove(id string) {
+	e, exists := mp.txs[id]
+	if !exists {
+		return
+	}
+	delete(mp.txs, id)
+	mp.bytes -= e.size
+
+	sender := e.tx.SenderID
+	queue := mp.senders[sender]
+	for i, queued := range queue {
+		if queued == e {
+			queue = append(queue[:i], queue[i+1:]...)
+			break
+		}
+	}
+
+	if len(queue) == 0 {
+		delete(mp.senders, sender)
+	} else {
+		mp.senders[sender] = queue
+	}
+}
+
+// Sisipkan entry ke antrian pengirim sesuai nonce.
+// Gossip bisa datang tidak berurutan, urutan nonce yang menentukan urutan eksekusi
+func insertByNonce(queue []*entry, e *entry) []*entry {
+	i, _ := slices.BinarySearchFunc(queue, e.tx.Nonce, func(queued *entry, nonce uint64) int {
+		return cmp.Compare(queued.tx.Nonce, nonce)
+	})
+	return slices.Insert(queue, i, e)
+}
+
+// Entry ini disusun ke block lebih dulu dari other
+func (e *entry) before(other *entry) bool {
+	if e.priority != other.priority {
+		return e.priority > other.priority
+	}
+	return e.seq < other.seq
+}
@@ -0,0 +1,40 @@
+package mempool
+
+import "container/list"
+
+// LRU id tx yang pernah diterima, dipakai untuk dedup gossip.
+// Tidak thread safe, dijaga oleh lock Mempool
+type seenCache struct {
+	capacity int
+	order    *list.List               // depan = paling baru
+	items    map[string]*list.Element // id tx -> elemen di order
+}
+
+func newSeenCache(capacity int) *seenCache {
+	return &seenCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element, capacity),
+	}
+}
+
+func (c *seenCache) has(id string) bool {
+	_, exists := c.items[id]
+	return exists
+}
+
+// Tandai id sebagai terlihat, id paling lama dibuang jika cache penuh
+func (c *seenCache) add(id string) {
+	if element, exists := c.items[id]; exists {
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[id] = c.order.PushFront(id)
+
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(string))
+	}
+}
@@ -0,0 +1,58 @@
+package outbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bpjs-hackathon/sehat-chain/types"
+)
+
+// Alamat service database BPJS
+const DefaultDatabaseURL = "http://localhost:8080"
+
+// Dispatcher yang meneruskan perubahan status claim ke database BPJS
+func ClaimStatusDispatcher(baseURL string) Dispatcher {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	return func(event types.Event) error {
+		if event.Type != types.EventClaimStatusChanged {
+			return nil
+		}
+
+		type UpdateStatus struct {
+			Status string `json:"status"`
+		}
+
+		jsonData, err := json.Marshal(UpdateStatus{Status: event.Attributes["status"]})
+		if err != nil {
+			return err
+		}
+
+		url := fmt.Sprintf("%s/admin/claims/%s/status", baseURL, event.Attributes["claim_id"])
+		req, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(jsonData))
+		if err != nil {
+			return fmt.Errorf("gagal hit database: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("gagal hit database: %w", err)
+		}
+		defer resp.Body.Close()
+
+		// 4xx tidak akan berhasil walau dikirim ulang, event dibuang agar antrian tidak macet
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			fmt.Printf("⚠️ Database rejected status %s for claim %s: %s\n", event.Attributes["status"], event.Attributes["claim_id"], resp.Status)
+			return nil
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("database responded %s", resp.Status)
+		}
+
+		return nil
+	}
+}
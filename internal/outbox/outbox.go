@@ -0,0 +1,215 @@
+// Antrian event hasil eksekusi block yang diteruskan ke sistem luar setelah block di commit.
+// Antrian disimpan di disk dan dikirim ulang dengan backoff sampai berhasil,
+// terpisah dari state transition sehingga kegagalan database tidak mempengaruhi consensus
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bpjs-hackathon/sehat-chain/types"
+)
+
+const (
+	pollInterval = 1 * time.Second
+	minBackoff   = 1 * time.Second
+	maxBackoff   = 5 * time.Minute
+)
+
+// Handler yang meneruskan satu event ke sistem luar
+type Dispatcher func(event types.Event) error
+
+type Entry struct {
+	ID          string      `json:"id"` // height-index, unik per event
+	Height      uint64      `json:"height"`
+	Event       types.Event `json:"event"`
+	Attempts    int         `json:"attempts"`
+	NextAttempt int64       `json:"next_attempt"` // Unix timestamp
+	LastError   string      `json:"last_error,omitempty"`
+}
+
+// Isi file outbox di disk
+type snapshot struct {
+	LastHeight uint64  `json:"last_height"` // height terakhir yang event-nya sudah masuk antrian
+	Entries    []Entry `json:"entries"`
+}
+
+type Outbox struct {
+	path     string
+	dispatch Dispatcher
+
+	lastHeight uint64
+	entries    []Entry
+	mux        sync.Mutex
+
+	wake chan struct{}
+}
+
+// Buka outbox dari file path (dibuat jika belum ada)
+func OpenOutbox(path string, dispatch Dispatcher) (*Outbox, error) {
+	o := &Outbox{
+		path:     path,
+		dispatch: dispatch,
+		wake:     make(chan struct{}, 1),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return o, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to decode outbox %s: %w", path, err)
+	}
+	o.lastHeight = snap.LastHeight
+	o.entries = snap.Entries
+
+	return o, nil
+}
+
+// Height terakhir yang event-nya sudah masuk antrian
+func (o *Outbox) LastHeight() uint64 {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	return o.lastHeight
+}
+
+// Jumlah event yang belum berhasil dikirim
+func (o *Outbox) Pending() int {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	return len(o.entries)
+}
+
+// Masukkan event block pada height ke antrian. Block yang sudah pernah
+// di enqueue (misal saat replay setelah restart) diabaikan
+func (o *Outbox) Enqueue(height uint64, events []types.Event) error {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	if height <= o.lastHeight {
+		return nil
+	}
+
+	for i, event := range events {
+		o.entries = append(o.entries, Entry{
+			ID:     fmt.Sprintf("%d-%d", height, i),
+			Height: height,
+			Event:  event,
+		})
+	}
+	o.lastHeight = height
+
+	if err := o.save(); err != nil {
+		return err
+	}
+
+	if len(events) > 0 {
+		select {
+		case o.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Loop pengiriman event. Event dikirim berurutan, event yang gagal
+// menahan event setelahnya agar urutan update status tetap terjaga
+func (o *Outbox) Run() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		o.flush()
+
+		select {
+		case <-ticker.C:
+		case <-o.wake:
+		}
+	}
+}
+
+func (o *Outbox) flush() {
+	for {
+		o.mux.Lock()
+		if len(o.entries) == 0 || o.entries[0].NextAttempt > time.Now().Unix() {
+			o.mux.Unlock()
+			return
+		}
+		entry := o.entries[0]
+		o.mux.Unlock()
+
+		err := o.dispatch(entry.Event)
+
+		o.mux.Lock()
+		if err != nil {
+			entry.Attempts++
+			entry.LastError = err.Error()
+			entry.NextAttempt = time.Now().Add(backoff(entry.Attempts)).Unix()
+			o.entries[0] = entry
+			fmt.Printf("⚠️ Outbox event %s failed (attempt %d): %v\n", entry.ID, entry.Attempts, err)
+		} else {
+			o.entries = o.entries[1:]
+		}
+
+		if saveErr := o.save(); saveErr != nil {
+			fmt.Printf("failed to save outbox: %v\n", saveErr)
+		}
+		o.mux.Unlock()
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Exponential backoff: 1s, 2s, 4s, ... maksimal 5 menit
+func backoff(attempts int) time.Duration {
+	delay := minBackoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
+
+// Tulis antrian ke file sementara lalu rename agar file tidak rusak saat crash (dipanggil dengan lock)
+func (o *Outbox) save() error {
+	data, err := json.Marshal(snapshot{LastHeight: o.lastHeight, Entries: o.entries})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(o.path), 0755); err != nil {
+		return err
+	}
+
+	tmp := o.path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, o.path)
+}
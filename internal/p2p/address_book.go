@@ -0,0 +1,157 @@
+package p2p
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	MaxAddressBookSize = 1000
+	minAddressScore    = -5 // alamat dengan score di bawah ini dihapus dari address book
+	maxAddressScore    = 20
+)
+
+// Alamat peer yang pernah diketahui node (dari config, handshake atau peer exchange)
+type AddressEntry struct {
+	ID       string `json:"id"`
+	Address  string `json:"address"`   // host:port p2p
+	LastSeen int64  `json:"last_seen"` // Unix timestamp handshake terakhir yang berhasil (0 = belum pernah)
+	Score    int    `json:"score"`     // naik saat berhasil terhubung, turun saat gagal
+}
+
+// Address book peer yang disimpan di disk agar node bisa menemukan network
+// kembali setelah restart tanpa bergantung pada daftar validator saja
+type AddressBook struct {
+	path    string
+	entries map[string]AddressEntry // key id peer
+	mux     sync.RWMutex
+}
+
+// Buka address book dari file path (dibuat saat Save jika belum ada)
+func OpenAddressBook(path string) (*AddressBook, error) {
+	book := &AddressBook{
+		path:    path,
+		entries: make(map[string]AddressEntry),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return book, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []AddressEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode address book %s: %w", path, err)
+	}
+	for _, entry := range entries {
+		book.entries[entry.ID] = entry
+	}
+
+	return book, nil
+}
+
+// Tambah alamat yang didapat dari peer lain. Alamat peer yang sudah dikenal tidak diubah
+// kecuali belum pernah berhasil terhubung
+func (b *AddressBook) Add(id string, address string) bool {
+	if id == "" || address == "" {
+		return false
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	entry, exists := b.entries[id]
+	if exists && (entry.LastSeen > 0 || entry.Address == address) {
+		return false
+	}
+	if !exists && len(b.entries) >= MaxAddressBookSize {
+		return false
+	}
+
+	b.entries[id] = AddressEntry{ID: id, Address: address, Score: entry.Score}
+	return true
+}
+
+// Handshake dengan peer berhasil
+func (b *AddressBook) MarkSeen(id string, address string) {
+	if id == "" || address == "" {
+		return
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	entry := b.entries[id]
+	entry.ID = id
+	entry.Address = address
+	entry.LastSeen = time.Now().Unix()
+	entry.Score = min(entry.Score+1, maxAddressScore)
+	b.entries[id] = entry
+}
+
+// Dial ke peer gagal. Alamat yang terus gagal dihapus
+func (b *AddressBook) MarkFailed(id string) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	entry, exists := b.entries[id]
+	if !exists {
+		return
+	}
+
+	entry.Score--
+	if entry.Score < minAddressScore {
+		delete(b.entries, id)
+		return
+	}
+	b.entries[id] = entry
+}
+
+// Semua alamat, urut berdasarkan score lalu last seen (terbaik di depan)
+func (b *AddressBook) Entries() []AddressEntry {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+
+	entries := make([]AddressEntry, 0, len(b.entries))
+	for _, entry := range b.entries {
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Score != entries[j].Score {
+			return entries[i].Score > entries[j].Score
+		}
+		if entries[i].LastSeen != entries[j].LastSeen {
+			return entries[i].LastSeen > entries[j].LastSeen
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	return entries
+}
+
+// Simpan address book ke disk (tulis file sementara lalu rename)
+func (b *AddressBook) Save() error {
+	data, err := json.MarshalIndent(b.Entries(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+		return err
+	}
+
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, b.path)
+}
@@ -0,0 +1,96 @@
+package p2p
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Payload pesan di encode dengan CBOR (field mengikuti tag json struct payload).
+// Peer versi lama (json) dilayani dengan transcode payload sesuai tipe pesan
+var (
+	payloadEncoder = func() cbor.EncMode {
+		mode, err := cbor.CoreDetEncOptions().EncMode()
+		if err != nil {
+			panic(err)
+		}
+		return mode
+	}()
+
+	payloadDecoder = func() cbor.DecMode {
+		mode, err := cbor.DecOptions{DupMapKey: cbor.DupMapKeyEnforcedAPF}.DecMode()
+		if err != nil {
+			panic(err)
+		}
+		return mode
+	}()
+)
+
+// Struct payload per tipe pesan untuk transcode json <-> CBOR.
+// Tipe tanpa payload (ping, pong, peers request) tidak ada di tabel
+var payloadTypes = map[string]func() any{
+	MsgHandshakeReq:       func() any { return &HandshakePayload{} },
+	MsgHandshakeResp:      func() any { return &HandshakePayload{} },
+	MsgTypeBlockReq:       func() any { return &BlockRequestPayload{} },
+	MsgTypeBlockSend:      func() any { return &BlockPayload{} },
+	MsgTypePeersSend:      func() any { return &PeerPayload{} },
+	MsgTypeTxGossip:       func() any { return &TxGossipPayload{} },
+	MsgTypeProposal:       func() any { return &ProposalPayload{} },
+	MsgTypeVote:           func() any { return &VotePayload{} },
+	MsgTypePrepareQC:      func() any { return &PrepareQCPayload{} },
+	MsgTypeViewChange:     func() any { return &ViewChangePayload{} },
+	MsgTypeHeadersReq:     func() any { return &HeadersRequestPayload{} },
+	MsgTypeHeadersSend:    func() any { return &HeadersPayload{} },
+	MsgTypeBlockRangeReq:  func() any { return &BlockRangeRequestPayload{} },
+	MsgTypeBlockRangeSend: func() any { return &BlockRangePayload{} },
+}
+
+// Encode payload untuk Message.Payload
+func EncodePayload(payload any) ([]byte, error) {
+	return payloadEncoder.Marshal(payload)
+}
+
+// Decode Message.Payload ke struct payload
+func (message Message) DecodePayload(payload any) error {
+	if len(message.Payload) == 0 {
+		return fmt.Errorf("message %s has no payload", message.Type)
+	}
+	return payloadDecoder.Unmarshal(message.Payload, payload)
+}
+
+// Payload CBOR -> json untuk peer versi lama
+func payloadToJSON(messageType string, payload []byte) (json.RawMessage, error) {
+	if len(payload) == 0 {
+		return nil, nil
+	}
+
+	newPayload, exists := payloadTypes[messageType]
+	if !exists {
+		return nil, fmt.Errorf("message %s cannot carry a payload", messageType)
+	}
+
+	decoded := newPayload()
+	if err := payloadDecoder.Unmarshal(payload, decoded); err != nil {
+		return nil, err
+	}
+	return json.Marshal(decoded)
+}
+
+// Payload json dari peer versi lama -> CBOR
+func payloadFromJSON(messageType string, payload json.RawMessage) ([]byte, error) {
+	if len(payload) == 0 || string(payload) == "null" {
+		return nil, nil
+	}
+
+	newPayload, exists := payloadTypes[messageType]
+	if !exists {
+		return nil, fmt.Errorf("message %s cannot carry a payload", messageType)
+	}
+
+	decoded := newPayload()
+	if err := json.Unmarshal(payload, decoded); err != nil {
+		return nil, err
+	}
+	return EncodePayload(decoded)
+}
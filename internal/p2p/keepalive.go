@@ -0,0 +1,121 @@
+package p2p
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	PingInterval    = 10 * time.Second // interval ping ke setiap peer
+	PingTimeout     = 5 * time.Second
+	DeadPeerTimeout = 30 * time.Second // peer tanpa pesan masuk selama ini dianggap mati
+)
+
+// Status koneksi satu peer untuk monitoring
+type PeerStatus struct {
+	ID          string `json:"id"`
+	Address     string `json:"address"`
+	Connected   bool   `json:"connected"`
+	ConnectedAt int64  `json:"connected_at"` // Unix timestamp
+	LastMessage int64  `json:"last_message"` // Unix timestamp pesan terakhir yang diterima
+	RTTMillis   int64  `json:"rtt_ms"`       // round trip ping terakhir (0 = belum ada)
+}
+
+// Daftarkan callback yang dipanggil saat koneksi peer yang sudah handshake terputus
+func (p2p *P2PManager) OnDisconnect(handler func(peerID string)) {
+	p2p.disconnectHandler = handler
+}
+
+// Koneksi peer terputus (read error / dead peer). Peer hanya dihapus jika
+// belum digantikan koneksi baru dengan id yang sama
+func (p2p *P2PManager) dropPeer(peer *Peer) {
+	peer.Close()
+
+	p2p.PeersMux.Lock()
+	current, exists := p2p.Peers[peer.ID]
+	removed := exists && current == peer
+	if removed {
+		delete(p2p.Peers, peer.ID)
+	}
+	if peer.Address != "" && p2p.Peers[peer.Address] == peer {
+		delete(p2p.Peers, peer.Address)
+	}
+	p2p.PeersMux.Unlock()
+
+	if removed {
+		fmt.Printf("🔌 Peer %s disconnected\n", peer.ID)
+		if p2p.disconnectHandler != nil {
+			p2p.disconnectHandler(peer.ID)
+		}
+	}
+}
+
+// Ping semua peer secara berkala dan putuskan peer yang tidak mengirim pesan
+// selama DeadPeerTimeout (readLoop peer tersebut akan berhenti dan peer dihapus)
+func (p2p *P2PManager) runKeepalive() {
+	ticker := time.NewTicker(PingInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, peer := range p2p.connectedPeers() {
+			if time.Since(peer.lastMessageTime()) > DeadPeerTimeout {
+				fmt.Printf("💀 Peer %s silent for %s, closing connection\n", peer.ID, DeadPeerTimeout)
+				peer.Close()
+				continue
+			}
+
+			go p2p.ping(peer)
+		}
+	}
+}
+
+func (p2p *P2PManager) ping(peer *Peer) {
+	start := time.Now()
+	_, err := p2p.Request(peer.ID, Message{
+		SenderID:  p2p.ID,
+		RequestID: uuid.NewString(),
+		Type:      MsgTypePing,
+	}, PingTimeout)
+	if err != nil {
+		return
+	}
+
+	peer.setRTT(time.Since(start))
+}
+
+// Balas ping langsung di layer p2p
+func (p2p *P2PManager) handlePing(peer *Peer, message Message) {
+	peer.write(Message{
+		SenderID:   p2p.ID,
+		RequestID:  message.RequestID,
+		ResponseID: message.RequestID,
+		Type:       MsgTypePong,
+	})
+}
+
+// Peer yang sudah handshake (key id, bukan alamat sementara)
+func (p2p *P2PManager) connectedPeers() []*Peer {
+	p2p.PeersMux.RLock()
+	defer p2p.PeersMux.RUnlock()
+
+	peers := make([]*Peer, 0, len(p2p.Peers))
+	for key, peer := range p2p.Peers {
+		if peer.ID == key {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
+
+// Status semua peer yang sedang terhubung
+func (p2p *P2PManager) PeerStatuses() []PeerStatus {
+	peers := p2p.connectedPeers()
+
+	statuses := make([]PeerStatus, 0, len(peers))
+	for _, peer := range peers {
+		statuses = append(statuses, peer.Status())
+	}
+	return statuses
+}
@@ -1,18 +1,16 @@
 package p2p
 
 import (
-	"encoding/json"
-
 	"github.com/bpjs-hackathon/sehat-chain/types"
 )
 
 // Wrapping message untuk dikirim antar peer
 type Message struct {
-	SenderID   string          `json:"sender_id"`
-	RequestID  string          `json:"request_id"`  // UUID Pesan
-	ResponseID string          `json:"response_id"` // RequestID diulang jika balasan
-	Type       string          `json:"type"`
-	Payload    json.RawMessage `json:"payload"`
+	SenderID   string
+	RequestID  string // UUID Pesan
+	ResponseID string // RequestID diulang jika balasan
+	Type       string
+	Payload    []byte // CBOR, lihat EncodePayload & DecodePayload
 }
 
 // Konstanta type pesan p2p
@@ -27,8 +25,22 @@ const (
 	MsgTypePeersReq  = "PEERS_REQUEST"
 	MsgTypePeersSend = "PEERS_SEND"
 
+	// SYNC (header dulu, lalu isi block per range dari beberapa peer)
+	MsgTypeHeadersReq     = "HEADERS_REQUEST"
+	MsgTypeHeadersSend    = "HEADERS_SEND"
+	MsgTypeBlockRangeReq  = "BLOCK_RANGE_REQUEST"
+	MsgTypeBlockRangeSend = "BLOCK_RANGE_SEND"
+
+	// KEEPALIVE
+	MsgTypePing = "PING" // dibalas PONG oleh layer p2p, dipakai untuk deteksi peer mati & RTT
+	MsgTypePong = "PONG"
+
 	// CONSENSUS
-	MsgTypeTxGossip = "CONSENSUS_TX_GOSSIP" // Node menyebar tx dari frontend/node lain agar semua node menerima tx
+	MsgTypeTxGossip   = "CONSENSUS_TX_GOSSIP"   // Node menyebar tx dari frontend/node lain agar semua node menerima tx
+	MsgTypeProposal   = "CONSENSUS_PROPOSAL"    // Leader mengirim block proposal ke validator
+	MsgTypeVote       = "CONSENSUS_VOTE"        // Validator mengirim vote PREPARE/COMMIT ke leader
+	MsgTypePrepareQC  = "CONSENSUS_PREPARE_QC"  // Leader menyebar QC PREPARE agar validator mengirim vote COMMIT
+	MsgTypeViewChange = "CONSENSUS_VIEW_CHANGE" // Validator meminta pergantian leader karena timeout
 )
 
 // Payloads
@@ -37,9 +49,16 @@ const (
 // Setelah menerima handshake diteruskan di node dimana ia akan mendeterminasi
 // Node ini termasuk ke list validator atau tidak
 type HandshakePayload struct {
-	NodeID string `json:"node_id"`
-	Port   string `json:"port"`
-	Secret string `json:"secret"`
+	NodeID    string `json:"node_id"`
+	Port      string `json:"port"`
+	PublicKey string `json:"public_key"` // hex encoded Ed25519 public key
+
+	ChainID     string `json:"chain_id"`
+	GenesisHash string `json:"genesis_hash"` // node dengan genesis berbeda tidak boleh terhubung
+
+	// Request: versi wire tertinggi yang didukung pengirim.
+	// Response: versi yang disepakati (0 / kosong = json lama)
+	WireVersion uint8 `json:"wire_version,omitempty"`
 }
 
 type BlockRequestPayload struct {
@@ -51,16 +70,70 @@ type BlockPayload struct {
 	Block        types.Block `json:"block"`
 }
 
+// Count 0 hanya menanyakan LatestHeight peer
+type HeadersRequestPayload struct {
+	From  uint64 `json:"from"`
+	Count uint64 `json:"count"`
+}
+
+// Header block beserta QC-nya, tanpa tx
+type SyncHeader struct {
+	Header types.BlockHeader       `json:"header"`
+	QC     types.QuorumCertificate `json:"qc"`
+}
+
+type HeadersPayload struct {
+	LatestHeight uint64       `json:"latest_height"`
+	Headers      []SyncHeader `json:"headers"`
+}
+
+// Range block inklusif [From, To]. Peer boleh membalas lebih sedikit block (batas ukuran frame)
+type BlockRangeRequestPayload struct {
+	From uint64 `json:"from"`
+	To   uint64 `json:"to"`
+}
+
+type BlockRangePayload struct {
+	LatestHeight uint64        `json:"latest_height"`
+	Blocks       []types.Block `json:"blocks"`
+}
+
 type PeerPayload struct {
 	Peers map[string]string `json:"peers"` // map id dan address
 }
 
+type ProposalPayload struct {
+	View  uint64      `json:"view"` // view saat proposal dikirim (bisa lebih tinggi dari view header jika block locked di propose ulang)
+	Block types.Block `json:"block"`
+
+	LeaderID  string `json:"leader_id"` // leader view ini (bukan ProposerID header jika block di propose ulang)
+	Signature string `json:"signature"` // signature leader atas types.ProposalSignBytes
+}
+
+type PrepareQCPayload struct {
+	QC types.QuorumCertificate `json:"qc"`
+}
+
 type VotePayload struct {
 	NodeID      string `json:"node_id"`
 	BlockHeight uint64 `json:"block_height"`
+	View        uint64 `json:"view"`
 	BlockHash   string `json:"block_hash"`
 	VoteType    string `json:"vote_type"` // "PREPARE" or "COMMIT"
-	Signature   string `json:"signature"` // **INI SIGNATURE DARI BLOCK BUKAN DARI PESAN
+	Signature   string `json:"signature"` // **INI SIGNATURE DARI BLOCK BUKAN DARI PESAN (atas types.VoteSignBytes)
+}
+
+type ViewChangePayload struct {
+	NodeID string `json:"node_id"`
+	Height uint64 `json:"height"`
+	View   uint64 `json:"view"` // view baru yang diminta
+
+	// Block yang sudah di vote COMMIT oleh validator ini beserta QC PREPARE-nya (jika ada)
+	// agar leader view baru mem-propose ulang block yang sama
+	LockedBlock *types.Block             `json:"locked_block,omitempty"`
+	LockedQC    *types.QuorumCertificate `json:"locked_qc,omitempty"`
+
+	Signature string `json:"signature"` // signature atas types.ViewChangeSignBytes
 }
 
 type TxGossipPayload struct {
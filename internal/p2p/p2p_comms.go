@@ -1,7 +1,7 @@
 package p2p
 
 import (
-	"encoding/json"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"time"
@@ -11,21 +11,20 @@ import (
 func (p2p *P2PManager) Connect(address string) (*Peer, error) {
 	var empty Peer
 
-	conn, err := net.Dial("tcp", address)
+	dialer := &net.Dialer{Timeout: TLSHandshakeTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", address, p2p.tls)
 	if err != nil {
 		return &empty, fmt.Errorf("failed to establish connection to %s, reason %v", address, err)
 	}
 
-	peer := &Peer{
-		conn:    conn,
-		Address: address,
-		encoder: json.NewEncoder(conn),
-		decoder: json.NewDecoder(conn),
+	peer, err := newTLSPeer(conn, address)
+	if err != nil {
+		return &empty, fmt.Errorf("failed to establish connection to %s, reason %v", address, err)
 	}
 
 	go peer.readLoop(func(message Message) {
 		p2p.handleIncomingMessage(peer, message)
-	})
+	}, func() { p2p.dropPeer(peer) })
 
 	// Register temporarily with address as key
 	p2p.PeersMux.Lock()
@@ -47,11 +46,8 @@ func (p2p *P2PManager) Send(peerID string, message Message) error {
 		return fmt.Errorf("failed to send p2p message: peer (%s) not found", peerID)
 	}
 
-	// Kirim message ke encoder untuk dikirim ke peer
-	// Gunakan mutex untuk memastikan write safety saat pengiriman
-	peer.mux.Lock()
-	defer peer.mux.Unlock()
-	return peer.encoder.Encode(message)
+	// Kirim message ke peer (write dilindungi mutex peer)
+	return peer.write(message)
 }
 
 // Pengiriman pesan two-way (mengirim pesan dan menunggu pesan balasan)
@@ -88,27 +84,22 @@ func (p2p *P2PManager) Request(peerID string, message Message, timeout time.Dura
 
 // Pengiriman pesan one-way ke semua peer terhubung
 func (p2p *P2PManager) Broadcast(message Message, sendToIDs []string) {
-	// ambil list peers
+	// ambil peer ter-list
 	p2p.PeersMux.RLock()
-	peers := p2p.Peers
-	p2p.PeersMux.RUnlock()
-
-	// kirim pesan ke peer ter-list
-	for index := range sendToIDs {
-		peer, exists := peers[sendToIDs[index]]
+	peers := make([]*Peer, 0, len(sendToIDs))
+	for _, id := range sendToIDs {
+		peer, exists := p2p.Peers[id]
 		if !exists {
-			fmt.Printf("broadcast warning: skipped sending to id %s because it's not on list of active connection", sendToIDs[index])
+			fmt.Printf("broadcast warning: skipped sending to id %s because it's not on list of active connection", id)
 			continue
 		}
+		peers = append(peers, peer)
+	}
+	p2p.PeersMux.RUnlock()
 
-		// goroutine untuk mengirim pesan. tidak menggunakan Send karena
-		// ada beberapa checking yang tidak perlu dilakukan disini (performance)
-		go func(peer *Peer) {
-			peer.mux.Lock()
-			defer peer.mux.Unlock()
-			if err := peer.encoder.Encode(message); err != nil {
-				fmt.Printf("broadcast error to peer (%s): %v\n", peer.ID, err)
-			}
-		}(peer)
+	// Tidak menggunakan Send karena ada beberapa checking yang tidak perlu dilakukan disini (performance).
+	// Pesan ke satu peer dikirim berurutan oleh write loop peer tersebut
+	for _, peer := range peers {
+		peer.enqueue(message)
 	}
 }
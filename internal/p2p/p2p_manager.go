@@ -1,7 +1,7 @@
 package p2p
 
 import (
-	"encoding/json"
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net"
@@ -19,18 +19,21 @@ type P2PManager struct {
 	pendingMessages map[string]chan Message
 	pendingMux      sync.Mutex
 
-	// server listener
+	// server listener (TLS 1.3, mutual auth dengan sertifikat node)
 	listener net.Listener
+	tls      *tls.Config
 
 	// Callback handler (meneruskan pesan ke layer atas)
-	messageHandler func(peer *Peer, msg Message)
+	messageHandler    func(peer *Peer, msg Message)
+	disconnectHandler func(peerID string)
 }
 
 // Membuat instance p2p manager
-func CreateP2PManager(nodeID string, port string) *P2PManager {
+func CreateP2PManager(nodeID string, port string, cert tls.Certificate) *P2PManager {
 	return &P2PManager{
 		ID:              nodeID,
 		Port:            port,
+		tls:             tlsConfig(cert),
 		Peers:           make(map[string]*Peer),
 		pendingMessages: make(map[string]chan Message),
 	}
@@ -38,7 +41,7 @@ func CreateP2PManager(nodeID string, port string) *P2PManager {
 
 // Membuka dan menerima koneksi p2p
 func (p2p *P2PManager) Open() error {
-	listener, err := net.Listen("tcp", ":"+p2p.Port)
+	listener, err := tls.Listen("tcp", ":"+p2p.Port, p2p.tls)
 	if err != nil {
 		return err
 	}
@@ -47,9 +50,10 @@ func (p2p *P2PManager) Open() error {
 
 	// jalankan loop untuk menerima request koneksi
 	go p2p.acceptLoop()
+	go p2p.runKeepalive()
 
 	// Logging
-	log.Printf("TCP P2P Node terbuka pada port %s\n", p2p.Port)
+	log.Printf("TLS P2P Node terbuka pada port %s\n", p2p.Port)
 	return nil
 }
 
@@ -68,17 +72,19 @@ func (p2p *P2PManager) acceptLoop() {
 			return
 		}
 
-		// Buat peer sementara (belum ada ID karena belum melakukan handshake)
-		peer := &Peer{
-			conn:    conn,
-			encoder: json.NewEncoder(conn),
-			decoder: json.NewDecoder(conn),
-		}
-
-		// Jalankan readloop untuk peer
-		go peer.readLoop(func(message Message) {
-			p2p.handleIncomingMessage(peer, message)
-		})
+		// TLS handshake di goroutine agar peer lambat tidak menahan accept loop
+		go func(conn *tls.Conn) {
+			// Buat peer sementara (belum ada ID karena belum melakukan handshake)
+			peer, err := newTLSPeer(conn, "")
+			if err != nil {
+				fmt.Printf("rejecting connection from %s: %v\n", conn.RemoteAddr(), err)
+				return
+			}
+
+			peer.readLoop(func(message Message) {
+				p2p.handleIncomingMessage(peer, message)
+			}, func() { p2p.dropPeer(peer) })
+		}(conn.(*tls.Conn))
 	}
 }
 
@@ -95,6 +101,14 @@ func (p2p *P2PManager) handleIncomingMessage(peer *Peer, message Message) {
 		}
 	}
 
+	switch message.Type {
+	case MsgTypePing:
+		p2p.handlePing(peer, message)
+		return
+	case MsgTypePong:
+		return // pong yang datang setelah request ping timeout
+	}
+
 	if p2p.messageHandler != nil {
 		p2p.messageHandler(peer, message)
 	}
@@ -110,8 +124,8 @@ func (p2p *P2PManager) RegisterPeer(peer *Peer, nodeID string) {
 	}
 
 	peer.ID = nodeID
-	if oldPeer, exists := p2p.Peers[nodeID]; exists {
-		oldPeer.conn.Close()
+	if oldPeer, exists := p2p.Peers[nodeID]; exists && oldPeer != peer {
+		oldPeer.Close()
 		fmt.Printf("peer %s reconnected and old connection is closed\n", nodeID)
 	}
 
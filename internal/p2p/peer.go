@@ -1,32 +1,163 @@
 package p2p
 
 import (
-	"encoding/json"
+	"bufio"
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// Pesan broadcast yang menunggu dikirim per peer, pesan baru dibuang jika antrian penuh
+const SendQueueSize = 1024
+
 type Peer struct {
-	ID      string // identifier setelah melakukan handshake
-	Address string
+	ID        string // identifier setelah melakukan handshake
+	Address   string
+	PublicKey string // hex encoded Ed25519 public key dari sertifikat TLS peer
+
+	// Alamat p2p peer yang bisa di dial node lain (untuk peer masuk: ip koneksi + port handshake)
+	ListenAddress string
+
+	mux sync.Mutex // write lock & wireVersion
 
-	mux sync.Mutex
+	conn        net.Conn
+	reader      *bufio.Reader
+	wireVersion uint8 // format penulisan, legacy json sampai dinegosiasikan saat handshake
 
-	conn    net.Conn
-	encoder *json.Encoder
-	decoder *json.Decoder
+	// Broadcast dikirim berurutan oleh satu goroutine (urutan nonce tx gossip terjaga)
+	sendQueue chan Message
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	// Versi hasil negosiasi untuk validasi format pesan masuk (-1 = handshake belum selesai)
+	negotiated atomic.Int32
+
+	// Health (Unix nano / nanodetik, diakses atomic)
+	connectedAt int64
+	lastMessage atomic.Int64
+	rtt         atomic.Int64
+}
+
+func newPeer(conn net.Conn, address string, publicKey string) *Peer {
+	peer := &Peer{
+		Address:     address,
+		PublicKey:   publicKey,
+		conn:        conn,
+		reader:      bufio.NewReader(conn),
+		wireVersion: WireVersionLegacy,
+		sendQueue:   make(chan Message, SendQueueSize),
+		closed:      make(chan struct{}),
+		connectedAt: time.Now().UnixNano(),
+	}
+	peer.lastMessage.Store(peer.connectedAt)
+	peer.negotiated.Store(-1)
+
+	go peer.writeLoop()
+	return peer
+}
+
+func (p *Peer) writeLoop() {
+	for {
+		select {
+		case message := <-p.sendQueue:
+			if err := p.write(message); err != nil {
+				fmt.Printf("broadcast error to peer (%s): %v\n", p.ID, err)
+			}
+		case <-p.closed:
+			return
+		}
+	}
+}
+
+// Antrikan pesan tanpa menunggu pesan terkirim
+func (p *Peer) enqueue(message Message) {
+	select {
+	case p.sendQueue <- message:
+	case <-p.closed:
+	default:
+		fmt.Printf("broadcast warning: send queue of peer %s is full, dropping %s\n", p.ID, message.Type)
+	}
 }
 
 // loop membaca pesan yang masuk pada koneksi oleh peer
-// dan mengirimnya ke sebuah callback
-func (p *Peer) readLoop(handler func(message Message)) {
+// dan mengirimnya ke sebuah callback. onClose dipanggil saat koneksi terputus
+func (p *Peer) readLoop(handler func(message Message), onClose func()) {
+	defer onClose()
+
 	for {
-		var msg Message
-		if err := p.decoder.Decode(&msg); err != nil {
+		msg, err := readMessage(p.reader, int(p.negotiated.Load()))
+		if err != nil {
 			fmt.Printf("peer read error: %v\n", err)
 			return
 		}
+		p.lastMessage.Store(time.Now().UnixNano())
 		handler(msg)
 	}
 }
+
+func (p *Peer) lastMessageTime() time.Time {
+	return time.Unix(0, p.lastMessage.Load())
+}
+
+func (p *Peer) setRTT(rtt time.Duration) {
+	p.rtt.Store(int64(rtt))
+}
+
+func (p *Peer) Status() PeerStatus {
+	return PeerStatus{
+		ID:          p.ID,
+		Address:     p.ListenAddress,
+		Connected:   true,
+		ConnectedAt: time.Unix(0, p.connectedAt).Unix(),
+		LastMessage: p.lastMessageTime().Unix(),
+		RTTMillis:   time.Duration(p.rtt.Load()).Milliseconds(),
+	}
+}
+
+// Kirim pesan ke peer dengan versi wire yang sudah disepakati
+func (p *Peer) write(message Message) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	return writeMessage(p.conn, p.wireVersion, message)
+}
+
+// Pakai versi wire hasil negosiasi handshake untuk pesan berikutnya
+func (p *Peer) SetWireVersion(version uint8) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	p.wireVersion = version
+	p.negotiated.Store(int32(version))
+}
+
+// Kirim balasan handshake dengan format lama lalu pakai versi hasil negosiasi.
+// Dilakukan dalam satu lock agar tidak ada pesan lain yang terkirim di antaranya
+func (p *Peer) RespondHandshake(message Message, version uint8) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if err := writeMessage(p.conn, p.wireVersion, message); err != nil {
+		return err
+	}
+
+	p.wireVersion = version
+	p.negotiated.Store(int32(version))
+	return nil
+}
+
+// Host dari alamat remote koneksi
+func (p *Peer) RemoteHost() string {
+	host, _, err := net.SplitHostPort(p.conn.RemoteAddr().String())
+	if err != nil {
+		return ""
+	}
+	return host
+}
+
+func (p *Peer) Close() error {
+	p.closeOnce.Do(func() { close(p.closed) })
+	return p.conn.Close()
+}
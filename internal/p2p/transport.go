@@ -0,0 +1,78 @@
+package p2p
+
+import (
+	"crypto/ed25519"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Batas waktu TLS handshake sebelum koneksi diputus
+const TLSHandshakeTimeout = 10 * time.Second
+
+// Konfigurasi TLS 1.3 dengan mutual auth. Tidak ada CA: sertifikat peer hanya
+// harus self-signed dengan key Ed25519, public key-nya kemudian dicocokkan
+// dengan identitas yang diklaim di HandshakePayload oleh layer core
+func tlsConfig(cert tls.Certificate) *tls.Config {
+	return &tls.Config{
+		MinVersion:            tls.VersionTLS13,
+		Certificates:          []tls.Certificate{cert},
+		ClientAuth:            tls.RequireAnyClientCert,
+		InsecureSkipVerify:    true, // verifikasi dilakukan di verifyPeerCertificate
+		VerifyPeerCertificate: verifyPeerCertificate,
+	}
+}
+
+func verifyPeerCertificate(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) != 1 {
+		return fmt.Errorf("expecting exactly 1 peer certificate, got %d", len(rawCerts))
+	}
+
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return err
+	}
+
+	if _, ok := cert.PublicKey.(ed25519.PublicKey); !ok {
+		return errors.New("peer certificate key is not Ed25519")
+	}
+
+	// Self-signed: signature sertifikat harus valid terhadap key di sertifikat itu sendiri
+	return cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature)
+}
+
+// Hex encoded public key peer yang sudah dibuktikan lewat TLS handshake
+func peerPublicKey(conn *tls.Conn) (string, error) {
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return "", errors.New("peer did not present a certificate")
+	}
+
+	publicKey, ok := certs[0].PublicKey.(ed25519.PublicKey)
+	if !ok {
+		return "", errors.New("peer certificate key is not Ed25519")
+	}
+
+	return hex.EncodeToString(publicKey), nil
+}
+
+// Selesaikan TLS handshake dan buat peer (belum melakukan handshake aplikasi)
+func newTLSPeer(conn *tls.Conn, address string) (*Peer, error) {
+	conn.SetDeadline(time.Now().Add(TLSHandshakeTimeout))
+	if err := conn.Handshake(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("tls handshake failed: %w", err)
+	}
+	conn.SetDeadline(time.Time{})
+
+	publicKey, err := peerPublicKey(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return newPeer(conn, address, publicKey), nil
+}
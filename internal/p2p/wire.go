@@ -0,0 +1,365 @@
+package p2p
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Versi wire protocol
+//
+//	0: legacy, satu objek json Message per pesan
+//	1: frame biner [versi 1B][tipe 1B][panjang body 4B big endian][body]
+//	   body: sender id, request id, response id (uvarint panjang + bytes),
+//	   nama tipe (hanya jika kode tipe 0), lalu payload json
+//	2: header frame yang sama, body berisi wireBody CBOR dengan payload CBOR
+//
+// Handshake selalu dikirim dengan format versi 0, setelahnya kedua peer memakai
+// versi hasil negosiasi. Json tanpa frame hanya diterima dari peer versi 0
+const (
+	WireVersionLegacy  uint8 = 0
+	WireVersionFramed  uint8 = 1
+	WireVersionCBOR    uint8 = 2
+	WireVersionCurrent       = WireVersionCBOR
+)
+
+// Ukuran maksimal satu frame (header tidak dihitung). Peer yang mengirim
+// frame lebih besar dianggap rusak dan koneksinya diputus
+const MaxFrameSize = 16 << 20
+
+const frameHeaderSize = 6
+
+var (
+	ErrFrameTooLarge    = errors.New("frame exceeds max frame size")
+	ErrMalformedFrame   = errors.New("malformed frame body")
+	ErrUnexpectedLegacy = errors.New("unframed json from peer using a newer wire version")
+)
+
+// Body frame versi 2
+type wireBody struct {
+	_          struct{} `cbor:",toarray"`
+	SenderID   string
+	RequestID  string
+	ResponseID string
+	Type       string // hanya jika kode tipe 0
+	Payload    []byte
+}
+
+// Pesan versi 0 (payload json apa adanya)
+type legacyMessage struct {
+	SenderID   string          `json:"sender_id"`
+	RequestID  string          `json:"request_id"`
+	ResponseID string          `json:"response_id"`
+	Type       string          `json:"type"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// Kode tipe pesan pada header frame. Tipe yang tidak ada di tabel dikirim dengan kode 0
+var messageTypeCodes = map[string]uint8{
+	MsgHandshakeReq:       1,
+	MsgHandshakeResp:      2,
+	MsgTypeBlockReq:       3,
+	MsgTypeBlockSend:      4,
+	MsgTypePeersReq:       5,
+	MsgTypePeersSend:      6,
+	MsgTypeTxGossip:       7,
+	MsgTypeProposal:       8,
+	MsgTypeVote:           9,
+	MsgTypePrepareQC:      10,
+	MsgTypeViewChange:     11,
+	MsgTypePing:           12,
+	MsgTypePong:           13,
+	MsgTypeHeadersReq:     14,
+	MsgTypeHeadersSend:    15,
+	MsgTypeBlockRangeReq:  16,
+	MsgTypeBlockRangeSend: 17,
+}
+
+var messageTypeNames = func() map[uint8]string {
+	names := make(map[uint8]string, len(messageTypeCodes))
+	for name, code := range messageTypeCodes {
+		names[code] = name
+	}
+	return names
+}()
+
+// Versi yang dipakai kedua peer: versi tertinggi yang didukung keduanya
+func NegotiateWireVersion(remote uint8) uint8 {
+	return min(remote, WireVersionCurrent)
+}
+
+// Tulis pesan sesuai versi wire peer
+func writeMessage(w io.Writer, version uint8, message Message) error {
+	if version == WireVersionLegacy {
+		payload, err := payloadToJSON(message.Type, message.Payload)
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(w).Encode(legacyMessage{
+			SenderID:   message.SenderID,
+			RequestID:  message.RequestID,
+			ResponseID: message.ResponseID,
+			Type:       message.Type,
+			Payload:    payload,
+		})
+	}
+
+	frame, err := encodeFrame(version, message)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(frame)
+	return err
+}
+
+func encodeFrame(version uint8, message Message) ([]byte, error) {
+	code := messageTypeCodes[message.Type]
+
+	var body []byte
+	var err error
+	if version == WireVersionFramed {
+		body, err = encodeFramedBody(code, message)
+	} else {
+		body, err = encodeCBORBody(code, message)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(body) > MaxFrameSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, len(body))
+	}
+
+	frame := make([]byte, frameHeaderSize, frameHeaderSize+len(body))
+	frame[0] = version
+	frame[1] = code
+	binary.BigEndian.PutUint32(frame[2:], uint32(len(body)))
+	return append(frame, body...), nil
+}
+
+func encodeCBORBody(code uint8, message Message) ([]byte, error) {
+	body := wireBody{
+		SenderID:   message.SenderID,
+		RequestID:  message.RequestID,
+		ResponseID: message.ResponseID,
+		Payload:    message.Payload,
+	}
+	if code == 0 {
+		body.Type = message.Type
+	}
+	return payloadEncoder.Marshal(body)
+}
+
+func encodeFramedBody(code uint8, message Message) ([]byte, error) {
+	payload, err := payloadToJSON(message.Type, message.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	body := make([]byte, 0, len(payload)+len(message.SenderID)+len(message.RequestID)+len(message.ResponseID)+16)
+	body = appendString(body, message.SenderID)
+	body = appendString(body, message.RequestID)
+	body = appendString(body, message.ResponseID)
+	if code == 0 {
+		body = appendString(body, message.Type)
+	}
+	return append(body, payload...), nil
+}
+
+// Baca satu pesan. negotiated < 0 berarti handshake belum selesai: json (pesan handshake)
+// dan frame versi apapun diterima. Setelahnya hanya format versi hasil negosiasi
+func readMessage(r *bufio.Reader, negotiated int) (Message, error) {
+	// Encoder json lama menulis newline setelah setiap objek
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return Message{}, err
+		}
+		if b[0] != '\n' && b[0] != '\r' && b[0] != ' ' && b[0] != '\t' {
+			break
+		}
+		r.ReadByte()
+	}
+
+	first, _ := r.Peek(1)
+	if first[0] == '{' {
+		if negotiated > int(WireVersionLegacy) {
+			return Message{}, fmt.Errorf("%w (version %d)", ErrUnexpectedLegacy, negotiated)
+		}
+		return readLegacyMessage(r)
+	}
+	return readFrame(r, negotiated)
+}
+
+func readFrame(r *bufio.Reader, negotiated int) (Message, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return Message{}, err
+	}
+
+	version := header[0]
+	if version == WireVersionLegacy || version > WireVersionCurrent {
+		return Message{}, fmt.Errorf("unsupported wire version %d", version)
+	}
+	if negotiated >= 0 && int(version) != negotiated {
+		return Message{}, fmt.Errorf("frame version %d, negotiated version %d", version, negotiated)
+	}
+
+	size := binary.BigEndian.Uint32(header[2:])
+	if size > MaxFrameSize {
+		return Message{}, fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, size)
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return Message{}, err
+	}
+
+	if version == WireVersionFramed {
+		return decodeFramedBody(header[1], body)
+	}
+	return decodeCBORBody(header[1], body)
+}
+
+// Nama tipe pesan dari kode header, kode 0 berarti nama dikirim di body
+func messageTypeName(code uint8, name string) (string, error) {
+	if code == 0 {
+		if name == "" {
+			return "", fmt.Errorf("%w: missing message type", ErrMalformedFrame)
+		}
+		return name, nil
+	}
+
+	name, exists := messageTypeNames[code]
+	if !exists {
+		return "", fmt.Errorf("unknown message type code %d", code)
+	}
+	return name, nil
+}
+
+func decodeCBORBody(code uint8, raw []byte) (Message, error) {
+	var body wireBody
+	if err := payloadDecoder.Unmarshal(raw, &body); err != nil {
+		return Message{}, fmt.Errorf("%w: %v", ErrMalformedFrame, err)
+	}
+
+	messageType, err := messageTypeName(code, body.Type)
+	if err != nil {
+		return Message{}, err
+	}
+
+	message := Message{
+		SenderID:   body.SenderID,
+		RequestID:  body.RequestID,
+		ResponseID: body.ResponseID,
+		Type:       messageType,
+	}
+	if len(body.Payload) > 0 {
+		message.Payload = body.Payload
+	}
+	return message, nil
+}
+
+func decodeFramedBody(code uint8, body []byte) (Message, error) {
+	var message Message
+	var err error
+
+	if message.SenderID, body, err = readString(body); err != nil {
+		return Message{}, err
+	}
+	if message.RequestID, body, err = readString(body); err != nil {
+		return Message{}, err
+	}
+	if message.ResponseID, body, err = readString(body); err != nil {
+		return Message{}, err
+	}
+
+	var name string
+	if code == 0 {
+		if name, body, err = readString(body); err != nil {
+			return Message{}, err
+		}
+	}
+	if message.Type, err = messageTypeName(code, name); err != nil {
+		return Message{}, err
+	}
+
+	if message.Payload, err = payloadFromJSON(message.Type, body); err != nil {
+		return Message{}, fmt.Errorf("%w: %v", ErrMalformedFrame, err)
+	}
+	return message, nil
+}
+
+// Baca satu objek json (format lama) dengan batas MaxFrameSize
+func readLegacyMessage(r *bufio.Reader) (Message, error) {
+	var raw []byte
+	depth := 0
+	inString := false
+	escaped := false
+
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return Message{}, err
+		}
+
+		raw = append(raw, b)
+		if len(raw) > MaxFrameSize {
+			return Message{}, fmt.Errorf("%w: legacy message", ErrFrameTooLarge)
+		}
+
+		switch {
+		case escaped:
+			escaped = false
+		case inString && b == '\\':
+			escaped = true
+		case b == '"':
+			inString = !inString
+		case inString:
+		case b == '{' || b == '[':
+			depth++
+		case b == '}' || b == ']':
+			depth--
+		}
+
+		if depth == 0 {
+			break
+		}
+	}
+
+	var legacy legacyMessage
+	if err := json.Unmarshal(raw, &legacy); err != nil {
+		return Message{}, err
+	}
+
+	payload, err := payloadFromJSON(legacy.Type, legacy.Payload)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		SenderID:   legacy.SenderID,
+		RequestID:  legacy.RequestID,
+		ResponseID: legacy.ResponseID,
+		Type:       legacy.Type,
+		Payload:    payload,
+	}, nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func readString(buf []byte) (string, []byte, error) {
+	length, n := binary.Uvarint(buf)
+	if n <= 0 || uint64(len(buf)-n) < length {
+		return "", nil, ErrMalformedFrame
+	}
+
+	end := n + int(length)
+	return string(buf[n:end]), buf[end:], nil
+}
@@ -0,0 +1,129 @@
+package p2p
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testMessage(t *testing.T) Message {
+	t.Helper()
+
+	payload, err := EncodePayload(VotePayload{NodeID: "v-1", BlockHeight: 7, View: 1, BlockHash: "ab", VoteType: "PREPARE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Message{SenderID: "v-1", RequestID: "req", Type: MsgTypeVote, Payload: payload}
+}
+
+func rawFrame(version uint8, code uint8, size uint32, body []byte) []byte {
+	frame := make([]byte, frameHeaderSize, frameHeaderSize+len(body))
+	frame[0] = version
+	frame[1] = code
+	binary.BigEndian.PutUint32(frame[2:], size)
+	return append(frame, body...)
+}
+
+func TestWireRoundTrip(t *testing.T) {
+	message := testMessage(t)
+	unknownType := Message{SenderID: "v-1", RequestID: "req", Type: "CUSTOM"}
+	ping := Message{SenderID: "v-1", RequestID: "req", Type: MsgTypePing}
+
+	tests := []struct {
+		name       string
+		version    uint8
+		negotiated int
+		message    Message
+	}{
+		{"legacy json before handshake", WireVersionLegacy, -1, message},
+		{"legacy json with legacy peer", WireVersionLegacy, int(WireVersionLegacy), message},
+		{"framed json", WireVersionFramed, int(WireVersionFramed), message},
+		{"cbor", WireVersionCBOR, int(WireVersionCBOR), message},
+		{"cbor before handshake", WireVersionCBOR, -1, message},
+		{"cbor without payload", WireVersionCBOR, int(WireVersionCBOR), ping},
+		{"cbor type name in body", WireVersionCBOR, int(WireVersionCBOR), unknownType},
+		{"framed type name in body", WireVersionFramed, int(WireVersionFramed), unknownType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeMessage(&buf, tt.version, tt.message); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := readMessage(bufio.NewReader(&buf), tt.negotiated)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.message) {
+				t.Fatalf("got %+v, want %+v", got, tt.message)
+			}
+		})
+	}
+}
+
+func TestWireRejectsBadFrames(t *testing.T) {
+	var legacy bytes.Buffer
+	if err := writeMessage(&legacy, WireVersionLegacy, testMessage(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	framed, err := encodeFrame(WireVersionFramed, testMessage(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cborFrame, err := encodeFrame(WireVersionCBOR, testMessage(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	voteCode := messageTypeCodes[MsgTypeVote]
+	cborBody := cborFrame[frameHeaderSize:]
+
+	tests := []struct {
+		name       string
+		input      []byte
+		negotiated int
+		wantErr    error // nil = cukup error apapun
+	}{
+		{"legacy json after cbor handshake", legacy.Bytes(), int(WireVersionCBOR), ErrUnexpectedLegacy},
+		{"legacy json after framed handshake", legacy.Bytes(), int(WireVersionFramed), ErrUnexpectedLegacy},
+		{"frame version differs from negotiated", framed, int(WireVersionCBOR), nil},
+		{"unsupported frame version", rawFrame(WireVersionCurrent+1, voteCode, 0, nil), -1, nil},
+		{"oversized frame", rawFrame(WireVersionCBOR, voteCode, MaxFrameSize+1, nil), int(WireVersionCBOR), ErrFrameTooLarge},
+		{"truncated header", cborFrame[:3], int(WireVersionCBOR), nil},
+		{"truncated body", cborFrame[:len(cborFrame)-2], int(WireVersionCBOR), nil},
+		{"unknown type code", rawFrame(WireVersionCBOR, 250, uint32(len(cborBody)), cborBody), int(WireVersionCBOR), nil},
+		{"cbor body garbage", rawFrame(WireVersionCBOR, voteCode, 3, []byte{0xff, 0x00, 0x01}), int(WireVersionCBOR), ErrMalformedFrame},
+		{"cbor body trailing bytes", rawFrame(WireVersionCBOR, voteCode, uint32(len(cborBody)+1), append(append([]byte{}, cborBody...), 0x00)), int(WireVersionCBOR), ErrMalformedFrame},
+		{"framed string length past body", rawFrame(WireVersionFramed, voteCode, 2, []byte{0x7f, 'a'}), int(WireVersionFramed), ErrMalformedFrame},
+		{"framed payload not json", rawFrame(WireVersionFramed, voteCode, 4, []byte{0, 0, 0, '{'}), int(WireVersionFramed), ErrMalformedFrame},
+		{"legacy json oversized", []byte("{\"payload\":\"" + strings.Repeat("a", MaxFrameSize) + "\"}"), -1, ErrFrameTooLarge},
+		{"legacy payload for message without payload", []byte(`{"type":"PING","payload":{"a":1}}`), -1, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readMessage(bufio.NewReader(bytes.NewReader(tt.input)), tt.negotiated)
+			if err == nil {
+				t.Fatal("bad frame accepted")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncodeFrameTooLarge(t *testing.T) {
+	message := Message{Type: MsgTypePing, Payload: make([]byte, MaxFrameSize+1)}
+	if _, err := encodeFrame(WireVersionCBOR, message); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("err %v, want ErrFrameTooLarge", err)
+	}
+}
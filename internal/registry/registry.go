@@ -0,0 +1,62 @@
+// Berisi daftar pengirim transaksi yang diotorisasi beserta public key-nya
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/bpjs-hackathon/sehat-chain/types"
+	"github.com/bpjs-hackathon/sehat-chain/utils"
+)
+
+var (
+	ErrUnknownSender    = errors.New("unknown sender")
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+type Registry struct {
+	submitters map[string]types.SubmitterConfig // key sender id
+	mux        sync.RWMutex
+}
+
+func CreateRegistry(submitters []types.SubmitterConfig) *Registry {
+	submittersMap := make(map[string]types.SubmitterConfig)
+	for _, s := range submitters {
+		submittersMap[s.ID] = s
+	}
+
+	return &Registry{
+		submitters: submittersMap,
+	}
+}
+
+func (r *Registry) Register(submitter types.SubmitterConfig) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.submitters[submitter.ID] = submitter
+}
+
+func (r *Registry) Get(id string) (types.SubmitterConfig, bool) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	submitter, exists := r.submitters[id]
+	return submitter, exists
+}
+
+// Verifikasi bahwa tx dikirim oleh submitter terdaftar dan signature
+// valid terhadap canonical hash tx
+func (r *Registry) VerifyTransaction(tx types.Transaction) error {
+	submitter, exists := r.Get(tx.SenderID)
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrUnknownSender, tx.SenderID)
+	}
+
+	if err := utils.Verify(submitter.PublicKey, tx.Signature, []byte(tx.Hash())); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package smartcontract
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/bpjs-hackathon/sehat-chain/types"
+)
+
+// Lifecycle claim: SUBMITTED -> PENDING -> APPROVED -> PAID.
+// REJECTED dan FAKED adalah status final
+var claimTransitions = map[string][]string{
+	types.ClaimStatusSubmitted: {types.ClaimStatusPending, types.ClaimStatusRejected, types.ClaimStatusFaked},
+	types.ClaimStatusPending:   {types.ClaimStatusApproved, types.ClaimStatusRejected, types.ClaimStatusFaked},
+	types.ClaimStatusApproved:  {types.ClaimStatusPaid, types.ClaimStatusRejected},
+}
+
+// Ubah status claim sesuai state machine, simpan ke world state lalu kirim event ke database
+func (e *Executor) transitionClaim(claim types.ClaimAsset, to string) error {
+	if !slices.Contains(claimTransitions[claim.Status], to) {
+		return fail(types.ErrCodeClaimTransition, "claim %s cannot change from %s to %s", claim.ClaimID, claim.Status, to)
+	}
+
+	claim.Status = to
+	e.putClaim(claim)
+	e.emitClaimStatus(claim.ClaimID, to)
+	return nil
+}
+
+// handleSubmitClaim: Faskes mengajukan klaim
+func (e *Executor) handleSubmitClaim(tx types.Transaction, header types.BlockHeader) error {
+	var payload types.TxSubmitClaim
+	if err := json.Unmarshal(tx.Payload, &payload); err != nil {
+		return fail(types.ErrCodeMalformedPayload, "invalid claim payload: %v", err)
+	}
+
+	// ID claim tidak boleh dipakai ulang
+	if _, exists := e.WorldState.GetClaim(payload.ClaimID); exists {
+		return fail(types.ErrCodeClaimExists, "claim %s already exists", payload.ClaimID)
+	}
+
+	// Tabel tarif versi on-chain harus tersedia sebelum state apapun berubah
+	engine, err := e.tariffEngine()
+	if err != nil {
+		return err
+	}
+
+	claimAsset := types.ClaimAsset{
+		ClaimID:        payload.ClaimID,
+		RujukanID:      payload.RujukanID,
+		FaskesID:       tx.SenderID,
+		PesertaID:      payload.PesertaID,
+		RekamMedisID:   payload.RekamMedisID,
+		RekamMedisHash: payload.RekamMedisHash,
+		DiagnosisCode:  payload.DiagnosisCode,
+		Status:         types.ClaimStatusSubmitted,
+		Timestamp:      tx.Timestamp,
+		SubmittedAt:    header.Timestamp,
+	}
+
+	// 1. Cek Validitas Rujukan (Jika ada rujukan ID).
+	// Rujukan tidak valid bukan fraud: tx gagal tanpa mencatat claim
+	var rujukan *types.RujukanAsset
+	if payload.RujukanID != "" {
+		active, err := e.activeRujukan(payload.RujukanID, header)
+		if err != nil {
+			return err
+		}
+
+		// Claim hanya boleh diajukan faskes tujuan rujukan
+		if active.FaskesTujuanID != tx.SenderID {
+			return fail(types.ErrCodeUnauthorized, "rujukan %s is addressed to %s, not %s", active.ID, active.FaskesTujuanID, tx.SenderID)
+		}
+
+		// Peserta claim mengikuti rujukan
+		if claimAsset.PesertaID != "" && claimAsset.PesertaID != active.PesertaID {
+			return fail(types.ErrCodeRujukanInvalid, "rujukan %s belongs to another peserta", active.ID)
+		}
+		claimAsset.PesertaID = active.PesertaID
+		rujukan = &active
+	}
+
+	// 2. Deteksi claim ganda sebelum rujukan dipakai
+	if reason := e.detectDuplicateClaim(claimAsset); reason != "" {
+		return e.markClaimFaked(claimAsset, fail(types.ErrCodeClaimDuplicate, "%s", reason))
+	}
+
+	// Tandai rujukan sebagai USED
+	if rujukan != nil {
+		if err := e.transitionRujukan(*rujukan, types.RujukanStatusUsed); err != nil {
+			return err
+		}
+	}
+
+	// 3. Hitung tarif INA-CBG dengan tabel versi on-chain
+	regionalTier, _ := e.WorldState.GetRegionalTier(tx.SenderID)
+	tariff, err := engine.Calculate(TariffRequest{
+		DiagnosisCode:  payload.DiagnosisCode,
+		ProcedureCodes: payload.ProcedureCodes,
+		CareClass:      payload.CareClass,
+		RegionalTier:   regionalTier,
+		LengthOfStay:   payload.LengthOfStay,
+	})
+
+	// 4. Simpan claim dengan status hasil verifikasi
+	claimAsset.Amount = tariff.Amount
+	claimAsset.CBGCode = tariff.CBGCode
+	claimAsset.TariffVersion = engine.Version()
+
+	// Claim tetap tercatat dengan status REJECTED, rujukan tidak jadi dipakai
+	if err != nil {
+		rejected := fail(types.ErrCodeClaimRejected, "claim %s rejected by INA-CBG engine: %v", payload.ClaimID, err)
+		return failAndRecord(rejected, func(e *Executor) error {
+			return e.transitionClaim(claimAsset, types.ClaimStatusRejected)
+		})
+	}
+
+	if err := e.transitionClaim(claimAsset, types.ClaimStatusPending); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ [SmartContract] Claim Submitted: %s (Status: %s)\n", payload.ClaimID, types.ClaimStatusPending)
+	return nil
+}
+
+// handleExecuteClaim: Admin BPJS menyetujui / membayar / menolak claim
+func (e *Executor) handleExecuteClaim(tx types.Transaction) error {
+	var payload types.TxExecuteClaim
+	if err := json.Unmarshal(tx.Payload, &payload); err != nil {
+		return fail(types.ErrCodeMalformedPayload, "invalid execute claim payload: %v", err)
+	}
+
+	// Hanya pengirim dengan role BPJS_ADMIN di world state yang boleh mengeksekusi claim
+	if role, _ := e.WorldState.GetRole(tx.SenderID); role != types.RoleBPJSAdmin {
+		return fail(types.ErrCodeUnauthorized, "sender %s is not a BPJS admin", tx.SenderID)
+	}
+
+	// Ambil data claim existing
+	claim, exists := e.WorldState.GetClaim(payload.ClaimID)
+	if !exists {
+		return fail(types.ErrCodeClaimNotFound, "claim %s not found", payload.ClaimID)
+	}
+
+	// Update status berdasarkan keputusan admin (APPROVED / PAID / REJECTED / FAKED)
+	if err := e.transitionClaim(claim, payload.Status); err != nil {
+		return err
+	}
+
+	fmt.Printf("💰 [SmartContract] Claim Executed: %s is now %s\n", claim.ClaimID, payload.Status)
+	return nil
+}
@@ -1,11 +1,8 @@
 package smartcontract
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"net/http"
-	"time"
 
 	"github.com/bpjs-hackathon/sehat-chain/internal/state"
 	"github.com/bpjs-hackathon/sehat-chain/types"
@@ -13,166 +10,139 @@ import (
 
 type Executor struct {
 	WorldState *state.WorldState
-	InaCBG     *MockInaCBGValidator
+	Tariffs    *TariffRegistry // tabel tarif INA-CBG per versi
+
+	receipt *types.Receipt // receipt tx yang sedang dieksekusi
 }
 
-func NewExecutor(ws *state.WorldState) *Executor {
+func NewExecutor(ws *state.WorldState, tariffs *TariffRegistry) *Executor {
 	return &Executor{
 		WorldState: ws,
-		InaCBG:     &MockInaCBGValidator{},
+		Tariffs:    tariffs,
 	}
 }
 
-func (e *Executor) ApplyBlock(block types.Block) {
+// Eksekusi semua tx block dan return receipt setiap tx.
+// Eksekusi deterministik: waktu hanya diambil dari header block dan tidak ada I/O ke luar
+func (e *Executor) ApplyBlock(block types.Block) []types.Receipt {
+	receipts := make([]types.Receipt, 0, len(block.Transactions))
 	for _, tx := range block.Transactions {
-		e.applyTransaction(tx)
+		receipts = append(receipts, e.applyTransaction(tx, block.Header))
 	}
-}
 
-func (e *Executor) applyTransaction(tx types.Transaction) {
-	switch tx.Type {
-	case types.TxTypeRecordVisit:
-		e.handleRecordVisit(tx)
-	case types.TxTypeCreateRujukan:
-		e.handleCreateRujukan(tx)
-	case types.TxTypeSubmitClaim:
-		e.handleSubmitClaim(tx)
-	case types.TxTypeExecuteClaim:
-		e.handleExecuteClaim(tx)
-	default:
-		fmt.Printf("Unknown transaction type: %s\n", tx.Type)
-	}
+	e.applyValidatorChanges(block.Header.Height)
+	e.WorldState.Commit(block.Header.Height)
+	return receipts
 }
 
-// handleRecordVisit: Mencatat kunjungan pasien biasa
-func (e *Executor) handleRecordVisit(tx types.Transaction) {
-	var payload types.TxVisit
-	if err := json.Unmarshal(tx.Payload, &payload); err != nil {
-		fmt.Println("Error unmarshal Visit payload:", err)
-		return
-	}
-
-	e.WorldState.AddVisit(payload)
-	fmt.Printf("✅ [SmartContract] Visit Recorded: %s\n", payload.RekamMedisID)
+// Eksekusi block pada overlay world state dan return state root serta receipts root hasilnya.
+// Overlay dibuang setelahnya sehingga world state tidak berubah
+func (e *Executor) CalculateRoots(block types.Block) (string, string) {
+	staged, receipts := e.applyOnOverlay(block)
+	return staged.CalculateHash(), types.CalculateReceiptsRoot(receipts)
 }
 
-// handleCreateRujukan: Membuat asset rujukan baru
-func (e *Executor) handleCreateRujukan(tx types.Transaction) {
-	var payload types.TxRujukan
-	if err := json.Unmarshal(tx.Payload, &payload); err != nil {
-		fmt.Println("Error unmarshal Rujukan payload:", err)
-		return
-	}
+// Eksekusi block pada overlay dan terapkan ke world state hanya jika state root dan
+// receipts root hasil eksekusi sama dengan header block (commit consensus, sync & replay)
+func (e *Executor) VerifyAndApplyBlock(block types.Block) ([]types.Receipt, error) {
+	staged, receipts := e.applyOnOverlay(block)
 
-	// Logic: Create Asset Rujukan
-	asset := types.RujukanAsset{
-		ID:              payload.RujukanID,
-		PesertaID:       payload.PesertaID,
-		FaskesPembuatID: payload.FaskesPembuatID,
-		FaskesTujuanID:  payload.FaskesTujuanID,
-		RekamMedisID:    payload.RekamMedisID,
-		RekamMedisHash:  payload.RekamMedisHash,
-		Status:          types.RujukanStatusActive,
-		IssueDate:       time.Now().Unix(),
-		ExpiryDate:      time.Now().AddDate(0, 3, 0).Unix(), // Berlaku 3 bulan
+	if stateRoot := staged.CalculateHash(); stateRoot != block.Header.StateRoot {
+		return nil, fmt.Errorf("state root mismatch for block %d. Expecting %s, got %s", block.Header.Height, block.Header.StateRoot, stateRoot)
+	}
+	if receiptsRoot := types.CalculateReceiptsRoot(receipts); receiptsRoot != block.Header.ReceiptsRoot {
+		return nil, fmt.Errorf("receipts root mismatch for block %d. Expecting %s, got %s", block.Header.Height, block.Header.ReceiptsRoot, receiptsRoot)
 	}
 
-	e.WorldState.AddRujukan(asset)
-	fmt.Printf("✅ [SmartContract] Rujukan Created: %s -> %s\n", payload.FaskesPembuatID, payload.FaskesTujuanID)
+	staged.Flush()
+	return receipts, nil
 }
 
-// handleSubmitClaim: Faskes mengajukan klaim
-func (e *Executor) handleSubmitClaim(tx types.Transaction) {
-	var payload types.TxSubmitClaim
-	if err := json.Unmarshal(tx.Payload, &payload); err != nil {
-		fmt.Println("Error unmarshal Claim payload:", err)
-		return
-	}
-
-	// 1. Cek Validitas Rujukan (Jika ada rujukan ID)
-	if payload.RujukanID != "" {
-		rujukan, exists := e.WorldState.GetRujukan(payload.RujukanID)
-		if !exists || rujukan.Status != types.RujukanStatusActive {
-			fmt.Println("❌ Claim Failed: Rujukan not found or expired")
-			e.updateClaimStatusSql(payload.ClaimID, types.ClaimStatusFaked)
-			return
-		}
-		// Tandai rujukan sebagai USED
-		rujukan.Status = types.RujukanStatusUsed
-		e.WorldState.AddRujukan(rujukan) // Update state
+func (e *Executor) applyOnOverlay(block types.Block) (*state.WorldState, []types.Receipt) {
+	scratch := &Executor{
+		WorldState: e.WorldState.Overlay(),
+		Tariffs:    e.Tariffs,
 	}
 
-	// 3. Validasi aturan medis
-	valid, amount, err := e.InaCBG.VerifyClaim(payload.DiagnosisCode)
-
-	status := types.ClaimStatusPending
-	if !valid || err != nil {
-		status = types.ClaimStatusRejected
-		e.updateClaimStatusSql(payload.ClaimID, types.ClaimStatusRejected)
-		fmt.Printf("⚠️ Claim Rejected by Engine: %v\n", err)
-	}
+	receipts := scratch.ApplyBlock(block)
+	return scratch.WorldState, receipts
+}
 
-	// 4. Buat Asset Claim
-	claimAsset := types.ClaimAsset{
-		ClaimID:        payload.ClaimID,
-		RujukanID:      payload.RujukanID,
-		FaskesID:       tx.SenderID,
-		RekamMedisID:   payload.RekamMedisID,
-		RekamMedisHash: payload.RekamMedisHash,
-		DiagnosisCode:  payload.DiagnosisCode,
-		Amount:         amount,
-		Status:         status,
-		Timestamp:      tx.Timestamp,
+func (e *Executor) applyTransaction(tx types.Transaction, header types.BlockHeader) types.Receipt {
+	receipt := types.Receipt{
+		TxID:        tx.ID,
+		BlockHeight: header.Height,
+		Status:      types.ReceiptStatusSuccess,
+		Events:      []types.Event{},
+		StateKeys:   []string{},
 	}
+	e.receipt = &receipt
+	defer func() { e.receipt = nil }()
 
-	e.WorldState.AddClaim(claimAsset)
-	fmt.Printf("✅ [SmartContract] Claim Submitted: %s (Status: %s)\n", payload.ClaimID, status)
-}
+	// Nonce dicatat walau tx gagal agar tx yang sama tidak bisa di eksekusi ulang
+	e.WorldState.SetNonce(tx.SenderID, tx.Nonce)
 
-// handleExecuteClaim: Admin BPJS menyetujui pembayaran
-func (e *Executor) handleExecuteClaim(tx types.Transaction) {
-	var payload types.TxExecuteClaim
-	if err := json.Unmarshal(tx.Payload, &payload); err != nil {
-		fmt.Println("Error unmarshal Execute Claim payload:", err)
-		return
+	// Perubahan state tx ditampung di overlay dan hanya diterapkan jika tx sukses
+	staged := &Executor{
+		WorldState: e.WorldState.Overlay(),
+		Tariffs:    e.Tariffs,
+		receipt:    &receipt,
 	}
 
-	// Ambil data claim existing
-	claim, exists := e.WorldState.GetClaim(payload.ClaimID)
-	if !exists {
-		fmt.Println("❌ Execute Failed: Claim ID not found")
-		return
+	err := staged.executeTransaction(tx, header)
+	if err == nil {
+		staged.WorldState.Flush()
+		return receipt
 	}
 
-	// Update status berdasarkan keputusan admin (PAID / REJECTED)
-	claim.Status = payload.Status
+	contractErr := asContractError(err)
+	receipt.Status = types.ReceiptStatusFailed
+	receipt.ErrorCode = contractErr.Code
+	receipt.ErrorMessage = contractErr.Message
+	receipt.StateKeys = []string{}
+	receipt.Events = []types.Event{}
 
-	e.WorldState.AddClaim(claim)
-	e.updateClaimStatusSql(claim.ClaimID, claim.Status)
-	fmt.Printf("💰 [SmartContract] Claim Executed: %s is now %s\n", claim.ClaimID, claim.Status)
-}
-
-func (e *Executor) updateClaimStatusSql(claimID string, status string) error {
-	type UpdateStatus struct {
-		Status string `json:"status"`
-	}
-
-	payload := UpdateStatus{
-		Status: status,
+	for _, record := range contractErr.records {
+		if err := record(e); err != nil {
+			fmt.Printf("⚠️ [SmartContract] Failed to record outcome of tx %s: %v\n", tx.ID, err)
+		}
 	}
 
-	jsonData, _ := json.Marshal(payload)
+	fmt.Printf("❌ [SmartContract] Tx %s failed: %v\n", tx.ID, contractErr)
+	return receipt
+}
 
-	client := &http.Client{}
-	req, err := http.NewRequest("PUT", fmt.Sprintf("http://localhost:8080/admin/claims/%s/status", claimID), bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("gagal hit database")
+func (e *Executor) executeTransaction(tx types.Transaction, header types.BlockHeader) error {
+	var err error
+	switch tx.Type {
+	case types.TxTypeRecordVisit:
+		err = e.handleRecordVisit(tx)
+	case types.TxTypeCreateRujukan:
+		err = e.handleCreateRujukan(tx, header)
+	case types.TxTypeRedeemRujukan:
+		err = e.handleBurnRujukan(tx, header)
+	case types.TxTypeSubmitClaim:
+		err = e.handleSubmitClaim(tx, header)
+	case types.TxTypeExecuteClaim:
+		err = e.handleExecuteClaim(tx)
+	case types.TxTypeSetTariffVersion:
+		err = e.handleSetTariffVersion(tx)
+	case types.TxTypeAddValidator, types.TxTypeRemoveValidator:
+		err = e.handleValidatorChange(tx, header)
+	default:
+		err = fail(types.ErrCodeUnknownTxType, "unknown transaction type: %s", tx.Type)
 	}
+	return err
+}
 
-	_, err = client.Do(req)
-	if err != nil {
-		return fmt.Errorf("gagal hit database")
+// handleRecordVisit: Mencatat kunjungan pasien biasa
+func (e *Executor) handleRecordVisit(tx types.Transaction) error {
+	var payload types.TxVisit
+	if err := json.Unmarshal(tx.Payload, &payload); err != nil {
+		return fail(types.ErrCodeMalformedPayload, "invalid visit payload: %v", err)
 	}
 
+	e.putVisit(payload)
+	fmt.Printf("✅ [SmartContract] Visit Recorded: %s\n", payload.RekamMedisID)
 	return nil
 }
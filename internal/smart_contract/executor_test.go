@@ -0,0 +1,620 @@
+package smartcontract
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/bpjs-hackathon/sehat-chain/internal/state"
+	"github.com/bpjs-hackathon/sehat-chain/types"
+)
+
+// Waktu block awal setiap test
+const testBlockTime int64 = 1700000000
+
+// Tabel tarif kecil: grup dengan syarat prosedur, grup tanpa prosedur dengan outlier lama rawat
+func testTariffTable(t *testing.T) *TableTariffEngine {
+	t.Helper()
+
+	engine, err := NewTableTariffEngine(TariffTable{
+		Version: "test-2024",
+		Groups: []TariffGroup{
+			{CBGCode: "K-1-10-I", Diagnoses: []string{"K35"}, Procedures: []string{"47.0"}},
+			{CBGCode: "K-4-17-I", Diagnoses: []string{"K35"}, LOSLimit: 3, LOSPerDay: 100000},
+			{CBGCode: "A-4-10-I", Diagnoses: []string{"A01"}},
+		},
+		Rates: []TariffRate{
+			{CBGCode: "K-1-10-I", CareClass: tariffAny, RegionalTier: tariffAny, Amount: 5000000},
+			{CBGCode: "K-4-17-I", CareClass: "1", RegionalTier: "1", Amount: 3000000},
+			{CBGCode: "K-4-17-I", CareClass: "1", RegionalTier: tariffAny, Amount: 2800000},
+			{CBGCode: "K-4-17-I", CareClass: tariffAny, RegionalTier: "2", Amount: 2500000},
+			{CBGCode: "K-4-17-I", CareClass: tariffAny, RegionalTier: tariffAny, Amount: 2000000},
+			{CBGCode: "A-4-10-I", CareClass: "3", RegionalTier: tariffAny, Amount: 1000000},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+// Executor dengan admin BPJS "bpjs", faskes "fk-1" (regional 1) dan "fk-2" (regional 2),
+// tabel tarif mock aktif
+func testExecutor(t *testing.T) *Executor {
+	t.Helper()
+
+	ws := state.CreateWorldState()
+	ws.SetRole("bpjs", types.RoleBPJSAdmin)
+	ws.SetRole("fk-1", types.RoleFaskes)
+	ws.SetRole("fk-2", types.RoleFaskes)
+	ws.SetRegionalTier("fk-1", "1")
+	ws.SetRegionalTier("fk-2", "2")
+
+	mock := NewMockTariffEngine()
+	ws.SetConfig(state.ConfigTariffVersion, mock.Version())
+	ws.SetConfig(state.ConfigTariffHash, mock.Hash())
+
+	return NewExecutor(ws, NewTariffRegistry(mock, testTariffTable(t)))
+}
+
+// Eksekusi satu tx di block dengan waktu at
+func execTx(t *testing.T, e *Executor, sender string, txType string, payload any, at int64) types.Receipt {
+	t.Helper()
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := types.Transaction{ID: txType + "-" + sender, Type: txType, Timestamp: at, SenderID: sender, Payload: data}
+	return e.applyTransaction(tx, types.BlockHeader{Height: 1, Timestamp: at})
+}
+
+// Error code "" berarti tx harus sukses
+func checkReceipt(t *testing.T, receipt types.Receipt, wantCode string) {
+	t.Helper()
+
+	if wantCode == "" {
+		if receipt.Status != types.ReceiptStatusSuccess {
+			t.Fatalf("tx failed: %s %s", receipt.ErrorCode, receipt.ErrorMessage)
+		}
+		return
+	}
+	if receipt.Status != types.ReceiptStatusFailed || receipt.ErrorCode != wantCode {
+		t.Fatalf("receipt %s %s, want FAILED %s", receipt.Status, receipt.ErrorCode, wantCode)
+	}
+}
+
+// Status claim di world state, "" jika claim tidak tercatat
+func claimStatus(e *Executor, claimID string) string {
+	claim, exists := e.WorldState.GetClaim(claimID)
+	if !exists {
+		return ""
+	}
+	return claim.Status
+}
+
+func rujukanStatus(e *Executor, rujukanID string) string {
+	rujukan, _ := e.WorldState.GetRujukan(rujukanID)
+	return rujukan.Status
+}
+
+// Rujukan fk-1 -> fk-2 untuk peserta P-1, terbit di testBlockTime
+func createRujukan(t *testing.T, e *Executor, rujukanID string) {
+	t.Helper()
+
+	checkReceipt(t, execTx(t, e, "fk-1", types.TxTypeCreateRujukan, types.TxRujukan{
+		RujukanID:       rujukanID,
+		PesertaID:       "P-1",
+		RekamMedisID:    "RM-" + rujukanID,
+		FaskesPembuatID: "fk-1",
+		FaskesTujuanID:  "fk-2",
+	}, testBlockTime), "")
+}
+
+func claimPayload(claimID string, rujukanID string, pesertaID string, rekamMedisID string, diagnosis string) types.TxSubmitClaim {
+	return types.TxSubmitClaim{
+		ClaimID:        claimID,
+		RujukanID:      rujukanID,
+		PesertaID:      pesertaID,
+		RekamMedisID:   rekamMedisID,
+		RekamMedisHash: "hash-" + rekamMedisID,
+		DiagnosisCode:  diagnosis,
+	}
+}
+
+func TestSubmitClaimRujukanChecks(t *testing.T) {
+	expired := testBlockTime + int64(100*24*time.Hour/time.Second) // lewat 3 bulan
+
+	tests := []struct {
+		name        string
+		setup       func(t *testing.T, e *Executor)
+		sender      string
+		claim       types.TxSubmitClaim
+		at          int64
+		wantCode    string
+		wantClaim   string // status claim C-1, "" = tidak tercatat
+		wantRujukan string
+	}{
+		{
+			name:        "valid rujukan",
+			sender:      "fk-2",
+			claim:       claimPayload("C-1", "R-1", "", "RM-1", "A01"),
+			at:          testBlockTime + 60,
+			wantClaim:   types.ClaimStatusPending,
+			wantRujukan: types.RujukanStatusUsed,
+		},
+		{
+			name:        "rujukan not found",
+			sender:      "fk-2",
+			claim:       claimPayload("C-1", "R-404", "", "RM-1", "A01"),
+			at:          testBlockTime + 60,
+			wantCode:    types.ErrCodeRujukanInvalid,
+			wantRujukan: types.RujukanStatusActive,
+		},
+		{
+			name:        "claim from faskes other than tujuan",
+			sender:      "fk-1",
+			claim:       claimPayload("C-1", "R-1", "", "RM-1", "A01"),
+			at:          testBlockTime + 60,
+			wantCode:    types.ErrCodeUnauthorized,
+			wantRujukan: types.RujukanStatusActive,
+		},
+		{
+			name:        "peserta differs from rujukan",
+			sender:      "fk-2",
+			claim:       claimPayload("C-1", "R-1", "P-2", "RM-1", "A01"),
+			at:          testBlockTime + 60,
+			wantCode:    types.ErrCodeRujukanInvalid,
+			wantRujukan: types.RujukanStatusActive,
+		},
+		{
+			name:        "expired rujukan",
+			sender:      "fk-2",
+			claim:       claimPayload("C-1", "R-1", "", "RM-1", "A01"),
+			at:          expired,
+			wantCode:    types.ErrCodeRujukanExpired,
+			wantRujukan: types.RujukanStatusExpired,
+		},
+		{
+			name: "rujukan already used",
+			setup: func(t *testing.T, e *Executor) {
+				checkReceipt(t, execTx(t, e, "fk-2", types.TxTypeSubmitClaim, claimPayload("C-0", "R-1", "", "RM-0", "A02"), testBlockTime+30), "")
+			},
+			sender:      "fk-2",
+			claim:       claimPayload("C-1", "R-1", "", "RM-1", "A01"),
+			at:          testBlockTime + 60,
+			wantCode:    types.ErrCodeRujukanInvalid,
+			wantRujukan: types.RujukanStatusUsed,
+		},
+		{
+			name: "cancelled rujukan",
+			setup: func(t *testing.T, e *Executor) {
+				checkReceipt(t, execTx(t, e, "fk-1", types.TxTypeRedeemRujukan, types.TxBurnRujukan{RujukanID: "R-1"}, testBlockTime+30), "")
+			},
+			sender:      "fk-2",
+			claim:       claimPayload("C-1", "R-1", "", "RM-1", "A01"),
+			at:          testBlockTime + 60,
+			wantCode:    types.ErrCodeRujukanInvalid,
+			wantRujukan: types.RujukanStatusCancelled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testExecutor(t)
+			createRujukan(t, e, "R-1")
+			if tt.setup != nil {
+				tt.setup(t, e)
+			}
+
+			receipt := execTx(t, e, tt.sender, types.TxTypeSubmitClaim, tt.claim, tt.at)
+			checkReceipt(t, receipt, tt.wantCode)
+
+			// Rujukan tidak valid bukan fraud: claim tidak tercatat sama sekali
+			if got := claimStatus(e, tt.claim.ClaimID); got != tt.wantClaim {
+				t.Fatalf("claim status %q, want %q", got, tt.wantClaim)
+			}
+			if got := rujukanStatus(e, "R-1"); got != tt.wantRujukan {
+				t.Fatalf("rujukan status %s, want %s", got, tt.wantRujukan)
+			}
+			if tt.wantClaim == types.ClaimStatusPending {
+				claim, _ := e.WorldState.GetClaim(tt.claim.ClaimID)
+				if claim.PesertaID != "P-1" || claim.FaskesID != tt.sender {
+					t.Fatalf("claim peserta %s faskes %s, want P-1 %s", claim.PesertaID, claim.FaskesID, tt.sender)
+				}
+			}
+		})
+	}
+}
+
+func TestFraudRules(t *testing.T) {
+	window := int64(DoubleBillingWindow / time.Second)
+
+	tests := []struct {
+		name       string
+		first      types.TxSubmitClaim
+		rejectedBy string // admin menolak claim pertama sebelum claim kedua
+		second     types.TxSubmitClaim
+		after      int64 // jarak waktu block claim kedua
+		wantFaked  bool
+	}{
+		{
+			name:      "rekam medis claimed twice",
+			first:     claimPayload("C-1", "", "P-1", "RM-1", "A01"),
+			second:    claimPayload("C-2", "", "P-2", "RM-1", "A02"),
+			after:     window * 2,
+			wantFaked: true,
+		},
+		{
+			name:       "rekam medis of rejected claim",
+			first:      claimPayload("C-1", "", "P-1", "RM-1", "A01"),
+			rejectedBy: "bpjs",
+			second:     claimPayload("C-2", "", "P-1", "RM-1", "A01"),
+			after:      60,
+		},
+		{
+			name:      "double billing within window",
+			first:     claimPayload("C-1", "", "P-1", "RM-1", "A01"),
+			second:    claimPayload("C-2", "", "P-1", "RM-2", "A01"),
+			after:     window - 1,
+			wantFaked: true,
+		},
+		{
+			name:   "same diagnosis after window",
+			first:  claimPayload("C-1", "", "P-1", "RM-1", "A01"),
+			second: claimPayload("C-2", "", "P-1", "RM-2", "A01"),
+			after:  window,
+		},
+		{
+			name:   "different diagnosis within window",
+			first:  claimPayload("C-1", "", "P-1", "RM-1", "A01"),
+			second: claimPayload("C-2", "", "P-1", "RM-2", "A02"),
+			after:  60,
+		},
+		{
+			name:   "different peserta same diagnosis",
+			first:  claimPayload("C-1", "", "P-1", "RM-1", "A01"),
+			second: claimPayload("C-2", "", "P-2", "RM-2", "A01"),
+			after:  60,
+		},
+		{
+			name:       "double billing of rejected claim",
+			first:      claimPayload("C-1", "", "P-1", "RM-1", "A01"),
+			rejectedBy: "bpjs",
+			second:     claimPayload("C-2", "", "P-1", "RM-2", "A01"),
+			after:      60,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testExecutor(t)
+			checkReceipt(t, execTx(t, e, "fk-1", types.TxTypeSubmitClaim, tt.first, testBlockTime), "")
+			if tt.rejectedBy != "" {
+				execute := types.TxExecuteClaim{ClaimID: tt.first.ClaimID, Status: types.ClaimStatusRejected}
+				checkReceipt(t, execTx(t, e, tt.rejectedBy, types.TxTypeExecuteClaim, execute, testBlockTime+1), "")
+			}
+
+			receipt := execTx(t, e, "fk-1", types.TxTypeSubmitClaim, tt.second, testBlockTime+tt.after)
+			claim, exists := e.WorldState.GetClaim(tt.second.ClaimID)
+			if !exists {
+				t.Fatal("second claim not recorded")
+			}
+
+			if !tt.wantFaked {
+				checkReceipt(t, receipt, "")
+				if claim.Status != types.ClaimStatusPending {
+					t.Fatalf("claim status %s, want %s", claim.Status, types.ClaimStatusPending)
+				}
+				return
+			}
+
+			// Claim tetap tercatat FAKED beserta alasannya walau tx gagal
+			checkReceipt(t, receipt, types.ErrCodeClaimDuplicate)
+			if claim.Status != types.ClaimStatusFaked || claim.FraudReason == "" {
+				t.Fatalf("claim status %s reason %q, want FAKED with reason", claim.Status, claim.FraudReason)
+			}
+			if got := claimStatus(e, tt.first.ClaimID); got != types.ClaimStatusPending {
+				t.Fatalf("first claim status %s, want %s", got, types.ClaimStatusPending)
+			}
+		})
+	}
+}
+
+func TestClaimTransitions(t *testing.T) {
+	type step struct {
+		sender   string
+		status   string
+		wantCode string
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+		want  string
+	}{
+		{"approve then pay", []step{
+			{"bpjs", types.ClaimStatusApproved, ""},
+			{"bpjs", types.ClaimStatusPaid, ""},
+		}, types.ClaimStatusPaid},
+		{"reject pending", []step{
+			{"bpjs", types.ClaimStatusRejected, ""},
+		}, types.ClaimStatusRejected},
+		{"reject approved", []step{
+			{"bpjs", types.ClaimStatusApproved, ""},
+			{"bpjs", types.ClaimStatusRejected, ""},
+		}, types.ClaimStatusRejected},
+		{"admin marks pending faked", []step{
+			{"bpjs", types.ClaimStatusFaked, ""},
+		}, types.ClaimStatusFaked},
+		{"pay without approval", []step{
+			{"bpjs", types.ClaimStatusPaid, types.ErrCodeClaimTransition},
+		}, types.ClaimStatusPending},
+		{"approved cannot be faked", []step{
+			{"bpjs", types.ClaimStatusApproved, ""},
+			{"bpjs", types.ClaimStatusFaked, types.ErrCodeClaimTransition},
+		}, types.ClaimStatusApproved},
+		{"paid is final", []step{
+			{"bpjs", types.ClaimStatusApproved, ""},
+			{"bpjs", types.ClaimStatusPaid, ""},
+			{"bpjs", types.ClaimStatusRejected, types.ErrCodeClaimTransition},
+		}, types.ClaimStatusPaid},
+		{"rejected is final", []step{
+			{"bpjs", types.ClaimStatusRejected, ""},
+			{"bpjs", types.ClaimStatusApproved, types.ErrCodeClaimTransition},
+		}, types.ClaimStatusRejected},
+		{"back to submitted", []step{
+			{"bpjs", types.ClaimStatusSubmitted, types.ErrCodeClaimTransition},
+		}, types.ClaimStatusPending},
+		{"unknown status", []step{
+			{"bpjs", "DONE", types.ErrCodeClaimTransition},
+		}, types.ClaimStatusPending},
+		{"faskes cannot execute", []step{
+			{"fk-1", types.ClaimStatusApproved, types.ErrCodeUnauthorized},
+		}, types.ClaimStatusPending},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testExecutor(t)
+			checkReceipt(t, execTx(t, e, "fk-1", types.TxTypeSubmitClaim, claimPayload("C-1", "", "P-1", "RM-1", "A01"), testBlockTime), "")
+
+			for i, step := range tt.steps {
+				receipt := execTx(t, e, step.sender, types.TxTypeExecuteClaim, types.TxExecuteClaim{ClaimID: "C-1", Status: step.status}, testBlockTime+int64(i+1))
+				checkReceipt(t, receipt, step.wantCode)
+
+				// Perubahan status sukses dikirim sebagai event
+				if step.wantCode == "" && (len(receipt.Events) != 1 || receipt.Events[0].Attributes["status"] != step.status) {
+					t.Fatalf("events %+v, want status change to %s", receipt.Events, step.status)
+				}
+			}
+
+			if got := claimStatus(e, "C-1"); got != tt.want {
+				t.Fatalf("claim status %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("unknown claim", func(t *testing.T) {
+		e := testExecutor(t)
+		receipt := execTx(t, e, "bpjs", types.TxTypeExecuteClaim, types.TxExecuteClaim{ClaimID: "C-404", Status: types.ClaimStatusApproved}, testBlockTime)
+		checkReceipt(t, receipt, types.ErrCodeClaimNotFound)
+	})
+
+	t.Run("claim id reused", func(t *testing.T) {
+		e := testExecutor(t)
+		checkReceipt(t, execTx(t, e, "fk-1", types.TxTypeSubmitClaim, claimPayload("C-1", "", "P-1", "RM-1", "A01"), testBlockTime), "")
+		receipt := execTx(t, e, "fk-2", types.TxTypeSubmitClaim, claimPayload("C-1", "", "P-2", "RM-2", "A02"), testBlockTime+1)
+		checkReceipt(t, receipt, types.ErrCodeClaimExists)
+
+		if claim, _ := e.WorldState.GetClaim("C-1"); claim.FaskesID != "fk-1" {
+			t.Fatalf("claim overwritten by %s", claim.FaskesID)
+		}
+	})
+}
+
+func TestRujukanTransitions(t *testing.T) {
+	expired := testBlockTime + int64(100*24*time.Hour/time.Second)
+
+	tests := []struct {
+		name     string
+		setup    func(t *testing.T, e *Executor)
+		sender   string
+		at       int64
+		wantCode string
+		want     string
+	}{
+		{"creator cancels", nil, "fk-1", testBlockTime + 60, "", types.RujukanStatusCancelled},
+		{"tujuan cannot cancel", nil, "fk-2", testBlockTime + 60, types.ErrCodeUnauthorized, types.RujukanStatusActive},
+		{"cancel expired", nil, "fk-1", expired, types.ErrCodeRujukanExpired, types.RujukanStatusExpired},
+		{"cancel twice", func(t *testing.T, e *Executor) {
+			checkReceipt(t, execTx(t, e, "fk-1", types.TxTypeRedeemRujukan, types.TxBurnRujukan{RujukanID: "R-1"}, testBlockTime+30), "")
+		}, "fk-1", testBlockTime + 60, types.ErrCodeRujukanInvalid, types.RujukanStatusCancelled},
+		{"cancel used", func(t *testing.T, e *Executor) {
+			checkReceipt(t, execTx(t, e, "fk-2", types.TxTypeSubmitClaim, claimPayload("C-1", "R-1", "", "RM-1", "A01"), testBlockTime+30), "")
+		}, "fk-1", testBlockTime + 60, types.ErrCodeRujukanInvalid, types.RujukanStatusUsed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testExecutor(t)
+			createRujukan(t, e, "R-1")
+			if tt.setup != nil {
+				tt.setup(t, e)
+			}
+
+			receipt := execTx(t, e, tt.sender, types.TxTypeRedeemRujukan, types.TxBurnRujukan{RujukanID: "R-1", Reason: "test"}, tt.at)
+			checkReceipt(t, receipt, tt.wantCode)
+			if got := rujukanStatus(e, "R-1"); got != tt.want {
+				t.Fatalf("rujukan status %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("create", func(t *testing.T) {
+		e := testExecutor(t)
+		createRujukan(t, e, "R-1")
+
+		rujukan, _ := e.WorldState.GetRujukan("R-1")
+		if rujukan.IssueDate != testBlockTime || rujukan.ExpiryDate <= rujukan.IssueDate {
+			t.Fatalf("rujukan issued %d expires %d", rujukan.IssueDate, rujukan.ExpiryDate)
+		}
+
+		// ID tidak boleh dipakai ulang, rujukan hanya dibuat atas nama pengirim
+		duplicate := types.TxRujukan{RujukanID: "R-1", PesertaID: "P-2", FaskesPembuatID: "fk-1", FaskesTujuanID: "fk-2"}
+		checkReceipt(t, execTx(t, e, "fk-1", types.TxTypeCreateRujukan, duplicate, testBlockTime+1), types.ErrCodeRujukanExists)
+
+		forged := types.TxRujukan{RujukanID: "R-2", PesertaID: "P-2", FaskesPembuatID: "fk-1", FaskesTujuanID: "fk-2"}
+		checkReceipt(t, execTx(t, e, "fk-2", types.TxTypeCreateRujukan, forged, testBlockTime+1), types.ErrCodeUnauthorized)
+		if _, exists := e.WorldState.GetRujukan("R-2"); exists {
+			t.Fatal("rujukan created for another faskes")
+		}
+	})
+}
+
+func TestTableTariffCalculate(t *testing.T) {
+	engine := testTariffTable(t)
+
+	tests := []struct {
+		name    string
+		req     TariffRequest
+		wantCBG string
+		want    uint64
+		wantErr bool
+	}{
+		{"procedure group first", TariffRequest{DiagnosisCode: "K35.8", ProcedureCodes: []string{"47.0"}, CareClass: "1", RegionalTier: "1"}, "K-1-10-I", 5000000, false},
+		{"other procedure falls back", TariffRequest{DiagnosisCode: "K35.8", ProcedureCodes: []string{"99.0"}, CareClass: "1", RegionalTier: "1"}, "K-4-17-I", 3000000, false},
+		{"class and any regional", TariffRequest{DiagnosisCode: "K35", CareClass: "1", RegionalTier: "3"}, "K-4-17-I", 2800000, false},
+		{"any class and regional", TariffRequest{DiagnosisCode: "K35", CareClass: "2", RegionalTier: "2"}, "K-4-17-I", 2500000, false},
+		{"wildcard rate", TariffRequest{DiagnosisCode: "K35", CareClass: "3", RegionalTier: "3"}, "K-4-17-I", 2000000, false},
+		{"length of stay within limit", TariffRequest{DiagnosisCode: "K35", CareClass: "3", RegionalTier: "3", LengthOfStay: 3}, "K-4-17-I", 2000000, false},
+		{"length of stay outlier", TariffRequest{DiagnosisCode: "K35", CareClass: "3", RegionalTier: "3", LengthOfStay: 5}, "K-4-17-I", 2200000, false},
+		{"codes are normalized", TariffRequest{DiagnosisCode: " k35.1 ", ProcedureCodes: []string{" 47.0"}, CareClass: "2", RegionalTier: "1"}, "K-1-10-I", 5000000, false},
+		{"no group", TariffRequest{DiagnosisCode: "B20", CareClass: "1", RegionalTier: "1"}, "", 0, true},
+		{"no rate", TariffRequest{DiagnosisCode: "A01", CareClass: "1", RegionalTier: "1"}, "", 0, true},
+		{"empty diagnosis", TariffRequest{CareClass: "1", RegionalTier: "1"}, "", 0, true},
+		{"negative length of stay", TariffRequest{DiagnosisCode: "K35", CareClass: "1", RegionalTier: "1", LengthOfStay: -1}, "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := engine.Calculate(tt.req)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %+v, want error", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.CBGCode != tt.wantCBG || result.Amount != tt.want {
+				t.Fatalf("got %s %d, want %s %d", result.CBGCode, result.Amount, tt.wantCBG, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubmitClaimTariff(t *testing.T) {
+	table := testTariffTable(t)
+
+	tests := []struct {
+		name        string
+		hash        string // hash tabel di world state
+		claim       types.TxSubmitClaim
+		wantCode    string
+		wantClaim   string
+		wantCBG     string
+		wantAmount  uint64
+		wantRujukan string
+	}{
+		{
+			name:        "amount from on-chain table version",
+			hash:        table.Hash(),
+			claim:       types.TxSubmitClaim{ClaimID: "C-1", RujukanID: "R-1", RekamMedisID: "RM-1", DiagnosisCode: "K35", CareClass: "2", LengthOfStay: 4},
+			wantClaim:   types.ClaimStatusPending,
+			wantCBG:     "K-4-17-I",
+			wantAmount:  2600000, // regional fk-2 = 2, satu hari outlier
+			wantRujukan: types.RujukanStatusUsed,
+		},
+		{
+			name:        "rejected by grouper",
+			hash:        table.Hash(),
+			claim:       types.TxSubmitClaim{ClaimID: "C-1", RujukanID: "R-1", RekamMedisID: "RM-1", DiagnosisCode: "B20", CareClass: "2"},
+			wantCode:    types.ErrCodeClaimRejected,
+			wantClaim:   types.ClaimStatusRejected,
+			wantRujukan: types.RujukanStatusActive,
+		},
+		{
+			name:        "table hash differs from chain",
+			hash:        "other-hash",
+			claim:       types.TxSubmitClaim{ClaimID: "C-1", RujukanID: "R-1", RekamMedisID: "RM-1", DiagnosisCode: "K35", CareClass: "2"},
+			wantCode:    types.ErrCodeTariffUnavailable,
+			wantRujukan: types.RujukanStatusActive,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testExecutor(t)
+			createRujukan(t, e, "R-1")
+			e.WorldState.SetConfig(state.ConfigTariffVersion, table.Version())
+			e.WorldState.SetConfig(state.ConfigTariffHash, tt.hash)
+
+			checkReceipt(t, execTx(t, e, "fk-2", types.TxTypeSubmitClaim, tt.claim, testBlockTime+60), tt.wantCode)
+			if got := rujukanStatus(e, "R-1"); got != tt.wantRujukan {
+				t.Fatalf("rujukan status %s, want %s", got, tt.wantRujukan)
+			}
+
+			claim, exists := e.WorldState.GetClaim(tt.claim.ClaimID)
+			if tt.wantClaim == "" {
+				if exists {
+					t.Fatalf("claim recorded as %s", claim.Status)
+				}
+				return
+			}
+			if claim.Status != tt.wantClaim {
+				t.Fatalf("claim status %s, want %s", claim.Status, tt.wantClaim)
+			}
+			if claim.TariffVersion != table.Version() || claim.CBGCode != tt.wantCBG || claim.Amount != tt.wantAmount {
+				t.Fatalf("claim tariff %s %s %d, want %s %s %d", claim.TariffVersion, claim.CBGCode, claim.Amount, table.Version(), tt.wantCBG, tt.wantAmount)
+			}
+		})
+	}
+}
+
+func TestSetTariffVersion(t *testing.T) {
+	table := testTariffTable(t)
+	mock := NewMockTariffEngine()
+
+	tests := []struct {
+		name        string
+		sender      string
+		version     string
+		hash        string
+		wantCode    string
+		wantVersion string
+	}{
+		{"loaded table", "bpjs", table.Version(), table.Hash(), "", table.Version()},
+		{"unknown version", "bpjs", "ina-cbg-2099", table.Hash(), types.ErrCodeTariffUnavailable, mock.Version()},
+		{"hash differs", "bpjs", table.Version(), mock.Hash(), types.ErrCodeTariffUnavailable, mock.Version()},
+		{"missing hash", "bpjs", table.Version(), "", types.ErrCodeMalformedPayload, mock.Version()},
+		{"faskes cannot set", "fk-1", table.Version(), table.Hash(), types.ErrCodeUnauthorized, mock.Version()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testExecutor(t)
+
+			receipt := execTx(t, e, tt.sender, types.TxTypeSetTariffVersion, types.TxSetTariffVersion{Version: tt.version, Hash: tt.hash}, testBlockTime)
+			checkReceipt(t, receipt, tt.wantCode)
+
+			if version, _ := e.WorldState.GetConfig(state.ConfigTariffVersion); version != tt.wantVersion {
+				t.Fatalf("tariff version %s, want %s", version, tt.wantVersion)
+			}
+			if _, err := e.tariffEngine(); err != nil {
+				t.Fatalf("active tariff table unusable: %v", err)
+			}
+		})
+	}
+}
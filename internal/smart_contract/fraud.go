@@ -0,0 +1,53 @@
+package smartcontract
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bpjs-hackathon/sehat-chain/types"
+)
+
+// Rentang waktu block di mana claim peserta dengan diagnosis yang sama dianggap double billing
+const DoubleBillingWindow = 7 * 24 * time.Hour
+
+// Claim yang dianggap masih berlaku (bukan REJECTED / FAKED)
+func isLiveClaim(claim types.ClaimAsset) bool {
+	return claim.Status != types.ClaimStatusRejected && claim.Status != types.ClaimStatusFaked
+}
+
+// Aturan anti-fraud deterministik. Return alasan jika claim dicurigai duplikat
+func (e *Executor) detectDuplicateClaim(claim types.ClaimAsset) string {
+	// 1. Satu rekam medis hanya boleh diklaim sekali
+	if claim.RekamMedisID != "" {
+		for _, existing := range e.WorldState.GetClaimsByRekamMedis(claim.RekamMedisID) {
+			if isLiveClaim(existing) {
+				return fmt.Sprintf("rekam medis %s already claimed by %s", claim.RekamMedisID, existing.ClaimID)
+			}
+		}
+	}
+
+	// 2. Peserta + diagnosis yang sama dalam rentang DoubleBillingWindow
+	if claim.PesertaID != "" {
+		window := int64(DoubleBillingWindow / time.Second)
+		for _, existing := range e.WorldState.GetClaimsByPesertaDiagnosis(claim.PesertaID, claim.DiagnosisCode) {
+			if isLiveClaim(existing) && claim.SubmittedAt-existing.SubmittedAt < window {
+				return fmt.Sprintf("double billing: peserta %s diagnosis %s already claimed by %s", claim.PesertaID, claim.DiagnosisCode, existing.ClaimID)
+			}
+		}
+	}
+
+	return ""
+}
+
+// Return error penyebab untuk receipt, claim tetap dicatat sebagai FAKED beserta alasannya
+func (e *Executor) markClaimFaked(claim types.ClaimAsset, cause error) error {
+	claim.FraudReason = cause.Error()
+	return failAndRecord(cause, func(e *Executor) error {
+		if err := e.transitionClaim(claim, types.ClaimStatusFaked); err != nil {
+			return err
+		}
+
+		fmt.Printf("🚨 [SmartContract] Claim %s marked FAKED: %s\n", claim.ClaimID, claim.FraudReason)
+		return nil
+	})
+}
@@ -0,0 +1,109 @@
+package smartcontract
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/bpjs-hackathon/sehat-chain/internal/state"
+	"github.com/bpjs-hackathon/sehat-chain/types"
+	"github.com/bpjs-hackathon/sehat-chain/utils"
+)
+
+// handleValidatorChange: ADD_VALIDATOR / REMOVE_VALIDATOR yang disetujui quorum validator aktif.
+// Perubahan hanya dijadwalkan, validator set baru berlaku mulai EffectiveHeight
+func (e *Executor) handleValidatorChange(tx types.Transaction, header types.BlockHeader) error {
+	var payload types.TxValidatorChange
+	if err := json.Unmarshal(tx.Payload, &payload); err != nil {
+		return fail(types.ErrCodeMalformedPayload, "invalid validator change payload: %v", err)
+	}
+
+	validator := payload.Validator
+	if validator.ID == "" {
+		return fail(types.ErrCodeMalformedPayload, "validator id is empty")
+	}
+
+	if payload.EffectiveHeight < header.Height+types.ValidatorChangeDelay {
+		return fail(types.ErrCodeInvalidValidator, "effective height %d must be at least %d", payload.EffectiveHeight, header.Height+types.ValidatorChangeDelay)
+	}
+
+	current := e.WorldState.GetValidators()
+	_, isValidator := e.WorldState.GetValidator(validator.ID)
+
+	switch tx.Type {
+	case types.TxTypeAddValidator:
+		if isValidator {
+			return fail(types.ErrCodeInvalidValidator, "%s is already a validator", validator.ID)
+		}
+		if pubKey, err := hex.DecodeString(validator.PublicKey); err != nil || len(pubKey) != ed25519.PublicKeySize {
+			return fail(types.ErrCodeInvalidValidator, "invalid public key for %s", validator.ID)
+		}
+		if validator.Address == "" {
+			return fail(types.ErrCodeInvalidValidator, "address of %s is empty", validator.ID)
+		}
+	case types.TxTypeRemoveValidator:
+		if !isValidator {
+			return fail(types.ErrCodeInvalidValidator, "%s is not a validator", validator.ID)
+		}
+		if len(current) <= 1 {
+			return fail(types.ErrCodeInvalidValidator, "cannot remove the last validator")
+		}
+		// Yang ditandatangani selalu data validator yang tercatat di state
+		validator, _ = e.WorldState.GetValidator(validator.ID)
+	}
+
+	for _, pending := range e.WorldState.GetValidatorChanges() {
+		if pending.Validator.ID == validator.ID {
+			return fail(types.ErrCodeInvalidValidator, "%s already has a pending change (%s)", validator.ID, pending.ID)
+		}
+	}
+
+	// Hitung approval valid dari validator set saat ini
+	signBytes := types.ValidatorChangeSignBytes(tx.Type, validator, payload.EffectiveHeight)
+	approved := 0
+	for _, approver := range current {
+		signature, exists := payload.Approvals[approver.ID]
+		if !exists {
+			continue
+		}
+		if err := utils.Verify(approver.PublicKey, signature, signBytes); err != nil {
+			fmt.Printf("⚠️ [SmartContract] Invalid approval from %s: %v\n", approver.ID, err)
+			continue
+		}
+		approved++
+	}
+
+	if approved < types.QuorumSize(len(current)) {
+		return fail(types.ErrCodeNoQuorum, "%d of %d approvals, need %d", approved, len(current), types.QuorumSize(len(current)))
+	}
+
+	e.WorldState.ScheduleValidatorChange(types.ValidatorChange{
+		ID:              tx.ID,
+		Action:          tx.Type,
+		Validator:       validator,
+		EffectiveHeight: payload.EffectiveHeight,
+	})
+	e.touch(state.NamespaceValidatorChange, tx.ID)
+	fmt.Printf("🗳️ [SmartContract] %s %s scheduled at height %d\n", tx.Type, validator.ID, payload.EffectiveHeight)
+	return nil
+}
+
+// Terapkan perubahan validator yang berlaku untuk block berikutnya (height+1).
+// Dipanggil di akhir ApplyBlock agar block height+1 sudah divalidasi dengan set baru
+func (e *Executor) applyValidatorChanges(height uint64) {
+	for _, change := range e.WorldState.GetValidatorChanges() {
+		if change.EffectiveHeight > height+1 {
+			break
+		}
+
+		switch change.Action {
+		case types.TxTypeAddValidator:
+			e.WorldState.SetValidator(change.Validator)
+		case types.TxTypeRemoveValidator:
+			e.WorldState.RemoveValidator(change.Validator.ID)
+		}
+		e.WorldState.RemoveValidatorChange(change.ID)
+		fmt.Printf("🗳️ [SmartContract] %s %s effective from height %d\n", change.Action, change.Validator.ID, change.EffectiveHeight)
+	}
+}
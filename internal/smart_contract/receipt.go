@@ -0,0 +1,93 @@
+package smartcontract
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bpjs-hackathon/sehat-chain/internal/state"
+	"github.com/bpjs-hackathon/sehat-chain/types"
+)
+
+// Error eksekusi tx, kode dicatat di receipt
+type ContractError struct {
+	Code    string
+	Message string
+
+	// Perubahan yang tetap dicatat walau tx gagal, dijalankan setelah perubahan tx dibuang
+	records []func(e *Executor) error
+}
+
+func (err *ContractError) Error() string {
+	return fmt.Sprintf("%s: %s", err.Code, err.Message)
+}
+
+func fail(code string, format string, args ...any) error {
+	return &ContractError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func asContractError(err error) *ContractError {
+	var contractErr *ContractError
+	if !errors.As(err, &contractErr) {
+		contractErr = &ContractError{Code: types.ErrCodeMalformedPayload, Message: err.Error()}
+	}
+	return contractErr
+}
+
+// Tx tetap gagal dengan err, tapi record tetap dicatat on-chain
+// (claim FAKED / REJECTED, rujukan EXPIRED)
+func failAndRecord(err error, record func(e *Executor) error) error {
+	contractErr := asContractError(err)
+	contractErr.records = append(contractErr.records, record)
+	return contractErr
+}
+
+// Gabungan event semua receipt sesuai urutan tx di block
+func BlockEvents(receipts []types.Receipt) []types.Event {
+	var events []types.Event
+	for _, receipt := range receipts {
+		events = append(events, receipt.Events...)
+	}
+	return events
+}
+
+// Catat key state yang diubah tx yang sedang dieksekusi
+func (e *Executor) touch(namespace string, id string) {
+	key := namespace + ":" + id
+	for _, existing := range e.receipt.StateKeys {
+		if existing == key {
+			return
+		}
+	}
+	e.receipt.StateKeys = append(e.receipt.StateKeys, key)
+}
+
+func (e *Executor) putVisit(visit types.TxVisit) {
+	e.WorldState.AddVisit(visit)
+	e.touch(state.NamespaceVisit, visit.RekamMedisID)
+}
+
+func (e *Executor) putRujukan(rujukan types.RujukanAsset) {
+	e.WorldState.AddRujukan(rujukan)
+	e.touch(state.NamespaceRujukan, rujukan.ID)
+}
+
+func (e *Executor) putClaim(claim types.ClaimAsset) {
+	e.WorldState.AddClaim(claim)
+	e.touch(state.NamespaceClaim, claim.ClaimID)
+}
+
+// Catat event untuk diteruskan ke sistem luar setelah block di commit
+func (e *Executor) emit(eventType string, attributes map[string]string) {
+	e.receipt.Events = append(e.receipt.Events, types.Event{
+		Type:       eventType,
+		Attributes: attributes,
+	})
+}
+
+// Catat perubahan status claim untuk diteruskan ke database setelah block di commit
+func (e *Executor) emitClaimStatus(claimID string, status string) {
+	e.emit(types.EventClaimStatusChanged, map[string]string{
+		"claim_id": claimID,
+		"status":   status,
+	})
+}
@@ -0,0 +1,109 @@
+package smartcontract
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/bpjs-hackathon/sehat-chain/types"
+)
+
+// Transisi status rujukan yang diperbolehkan
+var rujukanTransitions = map[string][]string{
+	types.RujukanStatusActive: {types.RujukanStatusUsed, types.RujukanStatusExpired, types.RujukanStatusCancelled},
+}
+
+// Ubah status rujukan sesuai state machine lalu simpan ke world state
+func (e *Executor) transitionRujukan(rujukan types.RujukanAsset, to string) error {
+	if !slices.Contains(rujukanTransitions[rujukan.Status], to) {
+		return fail(types.ErrCodeRujukanInvalid, "rujukan %s cannot change from %s to %s", rujukan.ID, rujukan.Status, to)
+	}
+
+	rujukan.Status = to
+	e.putRujukan(rujukan)
+	return nil
+}
+
+// Ambil rujukan yang masih ACTIVE pada waktu block.
+// Rujukan yang sudah lewat ExpiryDate ditandai EXPIRED
+func (e *Executor) activeRujukan(rujukanID string, header types.BlockHeader) (types.RujukanAsset, error) {
+	rujukan, exists := e.WorldState.GetRujukan(rujukanID)
+	if !exists {
+		return rujukan, fail(types.ErrCodeRujukanInvalid, "rujukan %s not found", rujukanID)
+	}
+
+	if rujukan.Status != types.RujukanStatusActive {
+		return rujukan, fail(types.ErrCodeRujukanInvalid, "rujukan %s is %s", rujukanID, rujukan.Status)
+	}
+
+	// Rujukan tetap tercatat EXPIRED walau tx gagal
+	if header.Timestamp > rujukan.ExpiryDate {
+		expired := fail(types.ErrCodeRujukanExpired, "rujukan %s expired at %d", rujukanID, rujukan.ExpiryDate)
+		return rujukan, failAndRecord(expired, func(e *Executor) error {
+			return e.transitionRujukan(rujukan, types.RujukanStatusExpired)
+		})
+	}
+
+	return rujukan, nil
+}
+
+// handleCreateRujukan: Membuat asset rujukan baru
+func (e *Executor) handleCreateRujukan(tx types.Transaction, header types.BlockHeader) error {
+	var payload types.TxRujukan
+	if err := json.Unmarshal(tx.Payload, &payload); err != nil {
+		return fail(types.ErrCodeMalformedPayload, "invalid rujukan payload: %v", err)
+	}
+
+	// Rujukan hanya dibuat oleh faskes pembuat itu sendiri
+	if payload.FaskesPembuatID != tx.SenderID {
+		return fail(types.ErrCodeUnauthorized, "sender %s cannot create rujukan for faskes %s", tx.SenderID, payload.FaskesPembuatID)
+	}
+
+	// ID rujukan tidak boleh dipakai ulang (rujukan USED tidak boleh kembali ACTIVE)
+	if _, exists := e.WorldState.GetRujukan(payload.RujukanID); exists {
+		return fail(types.ErrCodeRujukanExists, "rujukan %s already exists", payload.RujukanID)
+	}
+
+	// Logic: Create Asset Rujukan. Tanggal terbit = waktu block (bukan jam lokal node)
+	issued := time.Unix(header.Timestamp, 0).UTC()
+	asset := types.RujukanAsset{
+		ID:              payload.RujukanID,
+		PesertaID:       payload.PesertaID,
+		FaskesPembuatID: payload.FaskesPembuatID,
+		FaskesTujuanID:  payload.FaskesTujuanID,
+		RekamMedisID:    payload.RekamMedisID,
+		RekamMedisHash:  payload.RekamMedisHash,
+		Status:          types.RujukanStatusActive,
+		IssueDate:       issued.Unix(),
+		ExpiryDate:      issued.AddDate(0, 3, 0).Unix(), // Berlaku 3 bulan
+	}
+
+	e.putRujukan(asset)
+	fmt.Printf("✅ [SmartContract] Rujukan Created: %s -> %s\n", payload.FaskesPembuatID, payload.FaskesTujuanID)
+	return nil
+}
+
+// handleBurnRujukan: Faskes pembuat membatalkan rujukan yang belum dipakai
+func (e *Executor) handleBurnRujukan(tx types.Transaction, header types.BlockHeader) error {
+	var payload types.TxBurnRujukan
+	if err := json.Unmarshal(tx.Payload, &payload); err != nil {
+		return fail(types.ErrCodeMalformedPayload, "invalid burn rujukan payload: %v", err)
+	}
+
+	rujukan, err := e.activeRujukan(payload.RujukanID, header)
+	if err != nil {
+		return err
+	}
+
+	if rujukan.FaskesPembuatID != tx.SenderID {
+		return fail(types.ErrCodeUnauthorized, "only faskes %s can cancel rujukan %s", rujukan.FaskesPembuatID, rujukan.ID)
+	}
+
+	if err := e.transitionRujukan(rujukan, types.RujukanStatusCancelled); err != nil {
+		return err
+	}
+
+	fmt.Printf("🔥 [SmartContract] Rujukan Cancelled: %s (%s)\n", rujukan.ID, payload.Reason)
+	return nil
+}
@@ -0,0 +1,152 @@
+package smartcontract
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bpjs-hackathon/sehat-chain/internal/state"
+	"github.com/bpjs-hackathon/sehat-chain/types"
+)
+
+// Input penghitungan tarif INA-CBG untuk satu claim
+type TariffRequest struct {
+	DiagnosisCode  string   // ICD-10 diagnosis utama
+	ProcedureCodes []string // ICD-9-CM
+	CareClass      string   // kelas rawat (1 / 2 / 3)
+	RegionalTier   string   // regional tarif faskes
+	LengthOfStay   int      // lama rawat dalam hari
+}
+
+type TariffResult struct {
+	CBGCode string // kode grup INA-CBG hasil grouping
+	Amount  uint64
+}
+
+// TariffEngine menghitung tarif claim. Hasil harus deterministik
+// untuk versi tabel yang sama karena dipakai saat eksekusi block
+type TariffEngine interface {
+	Version() string
+	Hash() string // hash isi tabel, dicatat on-chain bersama versi
+	Calculate(req TariffRequest) (TariffResult, error)
+}
+
+// Kumpulan tariff engine per versi tabel. Versi yang dipakai ditentukan oleh world state
+type TariffRegistry struct {
+	engines map[string]TariffEngine
+}
+
+func NewTariffRegistry(engines ...TariffEngine) *TariffRegistry {
+	registry := &TariffRegistry{engines: make(map[string]TariffEngine)}
+	for _, engine := range engines {
+		registry.engines[engine.Version()] = engine
+	}
+	return registry
+}
+
+func (r *TariffRegistry) Register(engine TariffEngine) error {
+	if _, exists := r.engines[engine.Version()]; exists {
+		return fmt.Errorf("duplicate tariff table version %s", engine.Version())
+	}
+
+	r.engines[engine.Version()] = engine
+	return nil
+}
+
+func (r *TariffRegistry) Get(version string) (TariffEngine, bool) {
+	engine, exists := r.engines[version]
+	return engine, exists
+}
+
+func (r *TariffRegistry) Versions() []string {
+	versions := make([]string, 0, len(r.engines))
+	for version := range r.engines {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// Muat semua tabel tarif di dir: file *.json atau sub direktori berisi groups.csv & rates.csv
+func LoadTariffDir(dir string) (*TariffRegistry, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := NewTariffRegistry()
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !entry.IsDir() && !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		engine, err := LoadTariffTable(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tariff table %s: %w", path, err)
+		}
+
+		if err := registry.Register(engine); err != nil {
+			return nil, err
+		}
+	}
+
+	return registry, nil
+}
+
+// Pastikan tabel versi yang tercatat on-chain dimuat dengan isi yang sama (hash cocok)
+func (r *TariffRegistry) Verify(version string, hash string) (TariffEngine, error) {
+	engine, exists := r.Get(version)
+	if !exists {
+		return nil, fmt.Errorf("tariff table version %q is not loaded on this node", version)
+	}
+
+	if engine.Hash() != hash {
+		return nil, fmt.Errorf("tariff table version %q on this node has hash %s, chain expects %s", version, engine.Hash(), hash)
+	}
+
+	return engine, nil
+}
+
+// Tariff engine sesuai versi & hash tabel yang tercatat di world state
+func (e *Executor) tariffEngine() (TariffEngine, error) {
+	version, _ := e.WorldState.GetConfig(state.ConfigTariffVersion)
+	hash, _ := e.WorldState.GetConfig(state.ConfigTariffHash)
+
+	engine, err := e.Tariffs.Verify(version, hash)
+	if err != nil {
+		return nil, fail(types.ErrCodeTariffUnavailable, "%v", err)
+	}
+	return engine, nil
+}
+
+// handleSetTariffVersion: Admin BPJS mengganti versi tabel tarif untuk claim berikutnya
+func (e *Executor) handleSetTariffVersion(tx types.Transaction) error {
+	var payload types.TxSetTariffVersion
+	if err := json.Unmarshal(tx.Payload, &payload); err != nil {
+		return fail(types.ErrCodeMalformedPayload, "invalid tariff version payload: %v", err)
+	}
+
+	if role, _ := e.WorldState.GetRole(tx.SenderID); role != types.RoleBPJSAdmin {
+		return fail(types.ErrCodeUnauthorized, "sender %s is not a BPJS admin", tx.SenderID)
+	}
+
+	if payload.Version == "" || payload.Hash == "" {
+		return fail(types.ErrCodeMalformedPayload, "tariff version and hash are required")
+	}
+
+	// Versi yang tidak dimuat dengan isi yang sama tidak bisa dipakai menghitung claim
+	if _, err := e.Tariffs.Verify(payload.Version, payload.Hash); err != nil {
+		return fail(types.ErrCodeTariffUnavailable, "%v", err)
+	}
+
+	e.WorldState.SetConfig(state.ConfigTariffVersion, payload.Version)
+	e.WorldState.SetConfig(state.ConfigTariffHash, payload.Hash)
+	e.touch(state.NamespaceConfig, state.ConfigTariffVersion)
+	e.touch(state.NamespaceConfig, state.ConfigTariffHash)
+	fmt.Printf("📋 [SmartContract] Tariff version set to %s (%s)\n", payload.Version, payload.Hash)
+	return nil
+}
@@ -0,0 +1,63 @@
+package smartcontract
+
+import (
+	"fmt"
+	"strings"
+)
+
+const MockTariffVersion = "mock"
+
+// MockTariffEngine mensimulasikan pengecekan ke server INA-CBG (tanpa tabel tarif)
+type MockTariffEngine struct{}
+
+func NewMockTariffEngine() *MockTariffEngine {
+	return &MockTariffEngine{}
+}
+
+func (v *MockTariffEngine) Version() string {
+	return MockTariffVersion
+}
+
+func (v *MockTariffEngine) Hash() string {
+	return hashTariffContent(mockCodes)
+}
+
+// Calculate mengecek apakah diagnosis code valid dan eligible untuk diklaim
+func (v *MockTariffEngine) Calculate(req TariffRequest) (TariffResult, error) {
+	// Simulasi: Diagnosis code harus diawali huruf tertentu (misal 'A')
+	// dan amount tidak boleh 0
+	if len(req.DiagnosisCode) == 0 {
+		return TariffResult{}, fmt.Errorf("diagnosis code is empty")
+	}
+
+	code := strings.ToUpper(req.DiagnosisCode)
+
+	if !strings.HasPrefix(code, "A") {
+		return TariffResult{}, fmt.Errorf("invalid diagnosis code prefix")
+	}
+
+	exists, amount := v.SearchCode(code)
+	if !exists {
+		return TariffResult{}, fmt.Errorf("diagnosis code unavailable")
+	}
+
+	return TariffResult{CBGCode: code, Amount: amount}, nil
+}
+
+var mockCodes = map[string]uint64{
+	"A00": 500000,
+	"A01": 750000,
+	"A02": 600000,
+	"A03": 550000,
+	"A04": 450000,
+	"A05": 350000,
+	"A06": 800000,
+	"A07": 700000,
+	"A08": 400000,
+	"A09": 300000,
+}
+
+func (v *MockTariffEngine) SearchCode(diagnosisCode string) (bool, uint64) {
+	amount, exists := mockCodes[diagnosisCode]
+	return exists, amount
+}
@@ -0,0 +1,301 @@
+package smartcontract
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Wildcard kelas rawat / regional pada tabel tarif
+const tariffAny = "*"
+
+// Grup INA-CBG hasil grouping diagnosis (ICD-10) dan prosedur (ICD-9-CM)
+type TariffGroup struct {
+	CBGCode     string   `json:"cbg_code"`
+	Description string   `json:"description"`
+	Diagnoses   []string `json:"diagnoses"`   // prefix ICD-10, "A01" cocok untuk "A01.0"
+	Procedures  []string `json:"procedures"`  // ICD-9-CM, kosong berarti tanpa syarat prosedur
+	LOSLimit    int      `json:"los_limit"`   // lama rawat normal (hari), 0 berarti tanpa batas
+	LOSPerDay   uint64   `json:"los_per_day"` // tambahan tarif per hari di atas LOSLimit
+}
+
+// Tarif dasar grup per kelas rawat dan regional
+type TariffRate struct {
+	CBGCode      string `json:"cbg_code"`
+	CareClass    string `json:"care_class"`    // "1" / "2" / "3" atau "*"
+	RegionalTier string `json:"regional_tier"` // regional tarif atau "*"
+	Amount       uint64 `json:"amount"`
+}
+
+type TariffTable struct {
+	Version string        `json:"version"`
+	Groups  []TariffGroup `json:"groups"`
+	Rates   []TariffRate  `json:"rates"`
+}
+
+// TableTariffEngine menghitung tarif dari tabel INA-CBG yang dimuat dari file
+type TableTariffEngine struct {
+	table TariffTable
+	hash  string            // sha256 json tabel, sama untuk sumber json maupun csv
+	rates map[string]uint64 // cbg|kelas|regional -> tarif dasar
+}
+
+func NewTableTariffEngine(table TariffTable) (*TableTariffEngine, error) {
+	if table.Version == "" {
+		return nil, fmt.Errorf("tariff table has no version")
+	}
+
+	engine := &TableTariffEngine{
+		table: table,
+		hash:  hashTariffContent(table),
+		rates: make(map[string]uint64),
+	}
+
+	groups := make(map[string]bool)
+	for _, group := range table.Groups {
+		if group.CBGCode == "" || len(group.Diagnoses) == 0 {
+			return nil, fmt.Errorf("tariff group %q must have cbg code and diagnoses", group.CBGCode)
+		}
+		groups[group.CBGCode] = true
+	}
+
+	for _, rate := range table.Rates {
+		if !groups[rate.CBGCode] {
+			return nil, fmt.Errorf("tariff rate for unknown group %s", rate.CBGCode)
+		}
+
+		key := rateKey(rate.CBGCode, rate.CareClass, rate.RegionalTier)
+		if _, exists := engine.rates[key]; exists {
+			return nil, fmt.Errorf("duplicate tariff rate %s", key)
+		}
+		engine.rates[key] = rate.Amount
+	}
+
+	return engine, nil
+}
+
+// Muat tabel dari file json atau direktori csv (versi = nama direktori)
+func LoadTariffTable(path string) (*TableTariffEngine, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var table TariffTable
+	if info.IsDir() {
+		table, err = loadTariffCSV(path)
+	} else {
+		table, err = loadTariffJSON(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTableTariffEngine(table)
+}
+
+func (t *TableTariffEngine) Version() string {
+	return t.table.Version
+}
+
+func (t *TableTariffEngine) Hash() string {
+	return t.hash
+}
+
+// Hash isi tabel atas encoding json (urutan field mengikuti struct, key map terurut)
+func hashTariffContent(content any) string {
+	data, _ := json.Marshal(content)
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func (t *TableTariffEngine) Calculate(req TariffRequest) (TariffResult, error) {
+	diagnosis := normalizeCode(req.DiagnosisCode)
+	if diagnosis == "" {
+		return TariffResult{}, fmt.Errorf("diagnosis code is empty")
+	}
+	if req.LengthOfStay < 0 {
+		return TariffResult{}, fmt.Errorf("invalid length of stay %d", req.LengthOfStay)
+	}
+
+	group, found := t.group(diagnosis, req.ProcedureCodes)
+	if !found {
+		return TariffResult{}, fmt.Errorf("no INA-CBG group for diagnosis %s", diagnosis)
+	}
+
+	amount, found := t.rate(group.CBGCode, req.CareClass, req.RegionalTier)
+	if !found {
+		return TariffResult{}, fmt.Errorf("no tariff for group %s class %q regional %q", group.CBGCode, req.CareClass, req.RegionalTier)
+	}
+
+	// Lama rawat di atas batas normal dihitung sebagai outlier per hari
+	if group.LOSLimit > 0 && req.LengthOfStay > group.LOSLimit {
+		amount += uint64(req.LengthOfStay-group.LOSLimit) * group.LOSPerDay
+	}
+
+	return TariffResult{CBGCode: group.CBGCode, Amount: amount}, nil
+}
+
+// Grouping: grup dengan syarat prosedur yang terpenuhi didahulukan,
+// lalu grup tanpa syarat prosedur. Dalam satu tahap, urutan tabel yang menentukan
+func (t *TableTariffEngine) group(diagnosis string, procedures []string) (TariffGroup, bool) {
+	normalized := make([]string, len(procedures))
+	for i, procedure := range procedures {
+		normalized[i] = normalizeCode(procedure)
+	}
+
+	matchDiagnosis := func(group TariffGroup) bool {
+		for _, prefix := range group.Diagnoses {
+			if strings.HasPrefix(diagnosis, normalizeCode(prefix)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, group := range t.table.Groups {
+		if len(group.Procedures) == 0 || !matchDiagnosis(group) {
+			continue
+		}
+		for _, procedure := range group.Procedures {
+			if slices.Contains(normalized, normalizeCode(procedure)) {
+				return group, true
+			}
+		}
+	}
+
+	for _, group := range t.table.Groups {
+		if len(group.Procedures) == 0 && matchDiagnosis(group) {
+			return group, true
+		}
+	}
+
+	return TariffGroup{}, false
+}
+
+// Cari tarif paling spesifik: kelas & regional, lalu wildcard regional, lalu wildcard semua
+func (t *TableTariffEngine) rate(cbgCode string, careClass string, regionalTier string) (uint64, bool) {
+	for _, key := range []string{
+		rateKey(cbgCode, careClass, regionalTier),
+		rateKey(cbgCode, careClass, tariffAny),
+		rateKey(cbgCode, tariffAny, regionalTier),
+		rateKey(cbgCode, tariffAny, tariffAny),
+	} {
+		if amount, exists := t.rates[key]; exists {
+			return amount, true
+		}
+	}
+	return 0, false
+}
+
+func rateKey(cbgCode string, careClass string, regionalTier string) string {
+	return cbgCode + "|" + careClass + "|" + regionalTier
+}
+
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func loadTariffJSON(path string) (TariffTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return TariffTable{}, err
+	}
+
+	var table TariffTable
+	if err := json.Unmarshal(data, &table); err != nil {
+		return TariffTable{}, err
+	}
+	return table, nil
+}
+
+// Format csv:
+// groups.csv: cbg_code,description,diagnoses,procedures,los_limit,los_per_day (list dipisah ';')
+// rates.csv:  cbg_code,care_class,regional_tier,amount
+func loadTariffCSV(dir string) (TariffTable, error) {
+	table := TariffTable{Version: filepath.Base(dir)}
+
+	groups, err := readCSV(filepath.Join(dir, "groups.csv"), 6)
+	if err != nil {
+		return table, err
+	}
+	for i, row := range groups {
+		losLimit, err := strconv.Atoi(row[4])
+		if err != nil {
+			return table, fmt.Errorf("groups.csv row %d: invalid los_limit: %w", i+2, err)
+		}
+		losPerDay, err := strconv.ParseUint(row[5], 10, 64)
+		if err != nil {
+			return table, fmt.Errorf("groups.csv row %d: invalid los_per_day: %w", i+2, err)
+		}
+
+		table.Groups = append(table.Groups, TariffGroup{
+			CBGCode:     row[0],
+			Description: row[1],
+			Diagnoses:   splitList(row[2]),
+			Procedures:  splitList(row[3]),
+			LOSLimit:    losLimit,
+			LOSPerDay:   losPerDay,
+		})
+	}
+
+	rates, err := readCSV(filepath.Join(dir, "rates.csv"), 4)
+	if err != nil {
+		return table, err
+	}
+	for i, row := range rates {
+		amount, err := strconv.ParseUint(row[3], 10, 64)
+		if err != nil {
+			return table, fmt.Errorf("rates.csv row %d: invalid amount: %w", i+2, err)
+		}
+
+		table.Rates = append(table.Rates, TariffRate{
+			CBGCode:      row[0],
+			CareClass:    row[1],
+			RegionalTier: row[2],
+			Amount:       amount,
+		})
+	}
+
+	return table, nil
+}
+
+// Baca csv dengan header, return baris data
+func readCSV(path string, columns int) ([][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = columns
+	reader.TrimLeadingSpace = true
+
+	if _, err := reader.Read(); err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s is empty", path)
+		}
+		return nil, err
+	}
+
+	return reader.ReadAll()
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,111 @@
+// Query asset berdasarkan index sekunder (dipakai API, bukan bagian consensus)
+package state
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"github.com/bpjs-hackathon/sehat-chain/types"
+)
+
+// Filter query claim. Field kosong / 0 tidak dipakai
+type ClaimFilter struct {
+	FaskesID string
+	Status   string
+	From     int64 // SubmittedAt >= From
+	To       int64 // SubmittedAt <= To
+}
+
+func (filter ClaimFilter) match(claim types.ClaimAsset) bool {
+	if filter.FaskesID != "" && claim.FaskesID != filter.FaskesID {
+		return false
+	}
+	if filter.Status != "" && claim.Status != filter.Status {
+		return false
+	}
+	if filter.From != 0 && claim.SubmittedAt < filter.From {
+		return false
+	}
+	if filter.To != 0 && claim.SubmittedAt > filter.To {
+		return false
+	}
+	return true
+}
+
+// Claim sesuai filter urut waktu submit beserta jumlah total sebelum pagination.
+// Kandidat diambil dari index terkecil (faskes atau status), bukan scan semua claim
+func (ws *WorldState) QueryClaims(filter ClaimFilter, offset int, limit int) ([]types.ClaimAsset, int) {
+	ws.mux.RLock()
+	defer ws.mux.RUnlock()
+
+	var candidates []string
+	switch {
+	case filter.FaskesID != "" && (filter.Status == "" || len(ws.claimsByFaskes[filter.FaskesID]) <= len(ws.claimsByStatus[filter.Status])):
+		candidates = ws.claimsByFaskes[filter.FaskesID]
+	case filter.Status != "":
+		candidates = make([]string, 0, len(ws.claimsByStatus[filter.Status]))
+		for id := range ws.claimsByStatus[filter.Status] {
+			candidates = append(candidates, id)
+		}
+	default:
+		// Index waktu submit sudah urut, cukup ambil rentang From..To lalu halaman yang diminta
+		entries := ws.claimsSubmittedBetween(filter.From, filter.To)
+		page := paginate(entries, offset, limit)
+		claims := make([]types.ClaimAsset, 0, len(page))
+		for _, entry := range page {
+			claims = append(claims, ws.Claims[entry.claimID])
+		}
+		return claims, len(entries)
+	}
+
+	claims := make([]types.ClaimAsset, 0)
+	for _, id := range candidates {
+		if claim := ws.Claims[id]; filter.match(claim) {
+			claims = append(claims, claim)
+		}
+	}
+
+	slices.SortFunc(claims, func(a, b types.ClaimAsset) int {
+		return cmp.Or(cmp.Compare(a.SubmittedAt, b.SubmittedAt), cmp.Compare(a.ClaimID, b.ClaimID))
+	})
+
+	return paginate(claims, offset, limit), len(claims)
+}
+
+// Rentang index waktu submit dengan From <= SubmittedAt <= To (0 = tanpa batas)
+func (ws *WorldState) claimsSubmittedBetween(from int64, to int64) []claimSubmit {
+	bySubmittedAt := func(entry claimSubmit, at int64) int {
+		return cmp.Compare(entry.submittedAt, at)
+	}
+
+	lo, hi := 0, len(ws.claimsBySubmit)
+	if from != 0 {
+		lo, _ = slices.BinarySearchFunc(ws.claimsBySubmit, from, bySubmittedAt)
+	}
+	if to != 0 && to < math.MaxInt64 {
+		hi, _ = slices.BinarySearchFunc(ws.claimsBySubmit, to+1, bySubmittedAt)
+	}
+	return ws.claimsBySubmit[lo:max(lo, hi)]
+}
+
+// Rujukan milik peserta urut waktu dibuat beserta jumlah total sebelum pagination
+func (ws *WorldState) GetRujukansByPeserta(pesertaID string, offset int, limit int) ([]types.RujukanAsset, int) {
+	ws.mux.RLock()
+	defer ws.mux.RUnlock()
+
+	ids := ws.rujukansByPeserta[pesertaID]
+	rujukans := make([]types.RujukanAsset, 0, len(ids))
+	for _, id := range ids {
+		rujukans = append(rujukans, ws.Rujukans[id])
+	}
+
+	return paginate(rujukans, offset, limit), len(rujukans)
+}
+
+func paginate[T any](items []T, offset int, limit int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+	return items[offset:min(offset+limit, len(items))]
+}
@@ -0,0 +1,269 @@
+package state
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Sparse merkle tree 256 level (key = sha256) yang immutable.
+// Setiap update membuat path baru (path copying) sehingga root lama tetap
+// valid sebagai snapshot state pada height sebelumnya.
+// Subtree yang hanya berisi satu leaf disimpan sebagai shortcut leaf
+// agar memori O(log n) per update, tapi hash tetap dihitung seperti tree penuh.
+const smtDepth = 256
+
+var (
+	smtLeafPrefix = []byte{0x00}
+	smtNodePrefix = []byte{0x01}
+
+	// defaultHashes[d] adalah hash subtree kosong pada depth d (depth 256 = leaf)
+	defaultHashes = buildDefaultHashes()
+)
+
+type smtNode struct {
+	left  *smtNode
+	right *smtNode
+
+	// Shortcut leaf (leafKey != nil): satu-satunya leaf di subtree ini
+	leafKey   []byte
+	leafValue []byte
+
+	hash []byte
+}
+
+// Proof keberadaan (atau ketiadaan) sebuah key pada state root tertentu
+type MerkleProof struct {
+	Key      string   `json:"key"`      // hex encoded key (sha256 namespace:id)
+	Bitmap   string   `json:"bitmap"`   // hex 32 byte, bit ke-i = 1 jika sibling depth i bukan default
+	Siblings []string `json:"siblings"` // hash sibling non default, urut dari depth 0 (root) ke leaf
+}
+
+func buildDefaultHashes() [][]byte {
+	hashes := make([][]byte, smtDepth+1)
+	hashes[smtDepth] = make([]byte, sha256.Size)
+	for d := smtDepth - 1; d >= 0; d-- {
+		hashes[d] = smtHashNode(hashes[d+1], hashes[d+1])
+	}
+	return hashes
+}
+
+func smtHashLeaf(key []byte, value []byte) []byte {
+	valueHash := sha256.Sum256(value)
+	h := sha256.New()
+	h.Write(smtLeafPrefix)
+	h.Write(key)
+	h.Write(valueHash[:])
+	return h.Sum(nil)
+}
+
+func smtHashNode(left []byte, right []byte) []byte {
+	h := sha256.New()
+	h.Write(smtNodePrefix)
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// Bit ke-i dari key (0 = cabang kiri, 1 = cabang kanan)
+func keyBit(key []byte, i int) byte {
+	return (key[i/8] >> (7 - uint(i%8))) & 1
+}
+
+func nodeHash(n *smtNode, depth int) []byte {
+	if n == nil {
+		return defaultHashes[depth]
+	}
+	return n.hash
+}
+
+// Hash subtree pada depth yang hanya berisi satu leaf
+func shortcutHash(key []byte, value []byte, depth int) []byte {
+	h := smtHashLeaf(key, value)
+	for i := smtDepth - 1; i >= depth; i-- {
+		if keyBit(key, i) == 0 {
+			h = smtHashNode(h, defaultHashes[i+1])
+		} else {
+			h = smtHashNode(defaultHashes[i+1], h)
+		}
+	}
+	return h
+}
+
+func newLeaf(key []byte, value []byte, depth int) *smtNode {
+	return &smtNode{
+		leafKey:   key,
+		leafValue: value,
+		hash:      shortcutHash(key, value, depth),
+	}
+}
+
+func newInternal(left *smtNode, right *smtNode, depth int) *smtNode {
+	if left == nil && right == nil {
+		return nil
+	}
+
+	return &smtNode{
+		left:  left,
+		right: right,
+		hash:  smtHashNode(nodeHash(left, depth+1), nodeHash(right, depth+1)),
+	}
+}
+
+// Set value untuk key dan return root baru. value nil berarti hapus key
+func smtUpdate(n *smtNode, depth int, key []byte, value []byte) *smtNode {
+	if n == nil {
+		if value == nil {
+			return nil
+		}
+		return newLeaf(key, value, depth)
+	}
+
+	if n.leafKey != nil {
+		if bytes.Equal(n.leafKey, key) {
+			if value == nil {
+				return nil
+			}
+			return newLeaf(key, value, depth)
+		}
+
+		if value == nil {
+			return n
+		}
+
+		// Dua leaf berbagi subtree: pecah shortcut menjadi internal node
+		moved := newLeaf(n.leafKey, n.leafValue, depth+1)
+		if keyBit(n.leafKey, depth) == 0 {
+			return smtUpdate(newInternal(moved, nil, depth), depth, key, value)
+		}
+		return smtUpdate(newInternal(nil, moved, depth), depth, key, value)
+	}
+
+	if keyBit(key, depth) == 0 {
+		return newInternal(smtUpdate(n.left, depth+1, key, value), n.right, depth)
+	}
+	return newInternal(n.left, smtUpdate(n.right, depth+1, key, value), depth)
+}
+
+// Ambil value key pada tree dengan root n
+func smtGet(n *smtNode, key []byte) ([]byte, bool) {
+	for depth := 0; n != nil; depth++ {
+		if n.leafKey != nil {
+			if bytes.Equal(n.leafKey, key) {
+				return n.leafValue, true
+			}
+			return nil, false
+		}
+
+		if keyBit(key, depth) == 0 {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+
+	return nil, false
+}
+
+// Buat proof untuk key. Jika key tidak ada, proof membuktikan ketiadaan key
+func smtProve(n *smtNode, key []byte) MerkleProof {
+	bitmap := make([]byte, smtDepth/8)
+	siblings := []string{}
+
+	addSibling := func(depth int, hash []byte) {
+		if bytes.Equal(hash, defaultHashes[depth+1]) {
+			return
+		}
+		bitmap[depth/8] |= 1 << (7 - uint(depth%8))
+		siblings = append(siblings, hex.EncodeToString(hash))
+	}
+
+	for depth := 0; depth < smtDepth && n != nil; depth++ {
+		if n.leafKey != nil {
+			if bytes.Equal(n.leafKey, key) {
+				break
+			}
+
+			// Leaf lain menempati subtree ini: sibling non default hanya
+			// di depth saat path kedua key berpisah
+			for d := depth; d < smtDepth; d++ {
+				if keyBit(key, d) != keyBit(n.leafKey, d) {
+					addSibling(d, shortcutHash(n.leafKey, n.leafValue, d+1))
+					break
+				}
+			}
+			break
+		}
+
+		if keyBit(key, depth) == 0 {
+			addSibling(depth, nodeHash(n.right, depth+1))
+			n = n.left
+		} else {
+			addSibling(depth, nodeHash(n.left, depth+1))
+			n = n.right
+		}
+	}
+
+	return MerkleProof{
+		Key:      hex.EncodeToString(key),
+		Bitmap:   hex.EncodeToString(bitmap),
+		Siblings: siblings,
+	}
+}
+
+// VerifyProof mengecek bahwa key bernilai value pada state root (hex).
+// value nil berarti memverifikasi bahwa key tidak ada di state
+func VerifyProof(stateRoot string, value []byte, proof MerkleProof) error {
+	key, err := hex.DecodeString(proof.Key)
+	if err != nil || len(key) != sha256.Size {
+		return fmt.Errorf("invalid proof key")
+	}
+
+	bitmap, err := hex.DecodeString(proof.Bitmap)
+	if err != nil || len(bitmap) != smtDepth/8 {
+		return fmt.Errorf("invalid proof bitmap")
+	}
+
+	// Sibling disimpan dari root ke leaf, verifikasi berjalan dari leaf ke root
+	siblings := make([][]byte, smtDepth)
+	next := 0
+	for d := 0; d < smtDepth; d++ {
+		siblings[d] = defaultHashes[d+1]
+		if bitmap[d/8]&(1<<(7-uint(d%8))) == 0 {
+			continue
+		}
+
+		if next >= len(proof.Siblings) {
+			return fmt.Errorf("proof has fewer siblings than bitmap")
+		}
+		sibling, err := hex.DecodeString(proof.Siblings[next])
+		if err != nil {
+			return fmt.Errorf("invalid sibling at depth %d", d)
+		}
+		siblings[d] = sibling
+		next++
+	}
+	if next != len(proof.Siblings) {
+		return fmt.Errorf("proof has more siblings than bitmap")
+	}
+
+	h := defaultHashes[smtDepth]
+	if value != nil {
+		h = smtHashLeaf(key, value)
+	}
+
+	for d := smtDepth - 1; d >= 0; d-- {
+		if keyBit(key, d) == 0 {
+			h = smtHashNode(h, siblings[d])
+		} else {
+			h = smtHashNode(siblings[d], h)
+		}
+	}
+
+	if hex.EncodeToString(h) != stateRoot {
+		return fmt.Errorf("proof does not match state root")
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package state
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+type smtOp struct {
+	key   []byte
+	value []byte // nil = hapus key
+}
+
+// Key 32 byte dengan byte pertama dan terakhir tertentu, sisanya 0
+func testKey(first byte, last byte) []byte {
+	key := make([]byte, 32)
+	key[0] = first
+	key[31] = last
+	return key
+}
+
+func TestSMTInsertDeleteProof(t *testing.T) {
+	var (
+		a = testKey(0x00, 0x00)
+		b = testKey(0x00, 0x01) // hanya beda di bit terakhir dengan a
+		c = testKey(0x80, 0x00) // beda di bit pertama
+		d = StateKey(NamespaceClaim, "c-1")
+	)
+
+	tests := []struct {
+		name   string
+		ops    []smtOp
+		want   map[string][]byte // isi akhir tree (hex key -> value)
+		absent [][]byte
+	}{
+		{
+			name:   "empty tree",
+			want:   map[string][]byte{},
+			absent: [][]byte{a, d},
+		},
+		{
+			name:   "single insert",
+			ops:    []smtOp{{a, []byte("1")}},
+			want:   map[string][]byte{hex.EncodeToString(a): []byte("1")},
+			absent: [][]byte{b, c},
+		},
+		{
+			name:   "keys sharing 255 bit prefix",
+			ops:    []smtOp{{a, []byte("1")}, {b, []byte("2")}},
+			want:   map[string][]byte{hex.EncodeToString(a): []byte("1"), hex.EncodeToString(b): []byte("2")},
+			absent: [][]byte{c},
+		},
+		{
+			name:   "update existing key",
+			ops:    []smtOp{{a, []byte("1")}, {c, []byte("3")}, {a, []byte("9")}},
+			want:   map[string][]byte{hex.EncodeToString(a): []byte("9"), hex.EncodeToString(c): []byte("3")},
+			absent: [][]byte{b},
+		},
+		{
+			name:   "delete collapses split subtree",
+			ops:    []smtOp{{a, []byte("1")}, {b, []byte("2")}, {d, []byte("4")}, {b, nil}},
+			want:   map[string][]byte{hex.EncodeToString(a): []byte("1"), hex.EncodeToString(d): []byte("4")},
+			absent: [][]byte{b, c},
+		},
+		{
+			name:   "delete missing key",
+			ops:    []smtOp{{a, []byte("1")}, {c, nil}},
+			want:   map[string][]byte{hex.EncodeToString(a): []byte("1")},
+			absent: [][]byte{c},
+		},
+		{
+			name:   "delete everything",
+			ops:    []smtOp{{a, []byte("1")}, {b, []byte("2")}, {c, []byte("3")}, {a, nil}, {c, nil}, {b, nil}},
+			want:   map[string][]byte{},
+			absent: [][]byte{a, b, c},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var root *smtNode
+			for _, op := range tt.ops {
+				root = smtUpdate(root, 0, op.key, op.value)
+			}
+			stateRoot := hex.EncodeToString(nodeHash(root, 0))
+
+			// Root hanya bergantung pada isi akhir, bukan urutan operasi
+			var fresh *smtNode
+			for key, value := range tt.want {
+				rawKey, _ := hex.DecodeString(key)
+				fresh = smtUpdate(fresh, 0, rawKey, value)
+			}
+			if got := hex.EncodeToString(nodeHash(fresh, 0)); got != stateRoot {
+				t.Fatalf("root %s, want %s from inserting final content", stateRoot, got)
+			}
+
+			for key, value := range tt.want {
+				rawKey, _ := hex.DecodeString(key)
+				got, exists := smtGet(root, rawKey)
+				if !exists || !bytes.Equal(got, value) {
+					t.Fatalf("get %s = %q (exists %v), want %q", key, got, exists, value)
+				}
+
+				proof := smtProve(root, rawKey)
+				if err := VerifyProof(stateRoot, value, proof); err != nil {
+					t.Fatalf("inclusion proof %s: %v", key, err)
+				}
+				if err := VerifyProof(stateRoot, []byte("tampered"), proof); err == nil {
+					t.Fatalf("inclusion proof %s accepted a wrong value", key)
+				}
+				if err := VerifyProof(stateRoot, nil, proof); err == nil {
+					t.Fatalf("proof of existing key %s accepted as absence proof", key)
+				}
+			}
+
+			for _, key := range tt.absent {
+				if _, exists := smtGet(root, key); exists {
+					t.Fatalf("key %x should be absent", key)
+				}
+				if err := VerifyProof(stateRoot, nil, smtProve(root, key)); err != nil {
+					t.Fatalf("absence proof %x: %v", key, err)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyProofMalformed(t *testing.T) {
+	key := testKey(0x00, 0x00)
+	root := smtUpdate(smtUpdate(nil, 0, key, []byte("1")), 0, testKey(0x80, 0x00), []byte("2"))
+	stateRoot := hex.EncodeToString(nodeHash(root, 0))
+	valid := smtProve(root, key)
+
+	tests := []struct {
+		name   string
+		mutate func(proof *MerkleProof)
+	}{
+		{"bad key hex", func(proof *MerkleProof) { proof.Key = "zz" }},
+		{"short key", func(proof *MerkleProof) { proof.Key = "00" }},
+		{"short bitmap", func(proof *MerkleProof) { proof.Bitmap = "00" }},
+		{"missing sibling", func(proof *MerkleProof) { proof.Siblings = nil }},
+		{"extra sibling", func(proof *MerkleProof) { proof.Siblings = append(proof.Siblings, proof.Siblings[0]) }},
+		{"wrong sibling", func(proof *MerkleProof) { proof.Siblings = []string{hex.EncodeToString(defaultHashes[0])} }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proof := valid
+			proof.Siblings = append([]string{}, valid.Siblings...)
+			tt.mutate(&proof)
+			if err := VerifyProof(stateRoot, []byte("1"), proof); err == nil {
+				t.Fatal("malformed proof accepted")
+			}
+		})
+	}
+}
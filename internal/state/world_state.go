@@ -1,20 +1,88 @@
 package state
 
 import (
+	"cmp"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
-	"sort"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
 
 	"github.com/bpjs-hackathon/sehat-chain/types"
 )
 
+// Jumlah snapshot state root terakhir yang disimpan untuk keperluan proof
+const StateVersionsRetained = 1024
+
+// Namespace key pada state tree
+const (
+	NamespaceVisit    = "visit"
+	NamespaceRujukan  = "rujukan"
+	NamespaceClaim    = "claim"
+	NamespaceRole     = "role"
+	NamespaceRegional = "regional"
+	NamespaceConfig   = "config"
+	NamespaceNonce    = "nonce"
+
+	NamespaceValidator       = "validator"
+	NamespaceValidatorChange = "validator_change"
+)
+
+// Key parameter chain pada namespace config
+const (
+	ConfigTariffVersion = "tariff_version"
+	ConfigTariffHash    = "tariff_hash" // hash isi tabel tarif versi aktif
+)
+
 type WorldState struct {
 	VisitRecord map[string]types.TxVisit
 	Rujukans    map[string]types.RujukanAsset
 	Claims      map[string]types.ClaimAsset
-	mux         sync.RWMutex
+	Roles       map[string]string // id pengirim tx -> role (FASKES / BPJS_ADMIN)
+	Regional    map[string]string // id faskes -> regional tarif INA-CBG
+	Config      map[string]string // parameter chain (versi tabel tarif, dll)
+	Nonces      map[string]uint64 // id pengirim tx -> nonce tx terakhir yang di eksekusi (replay protection)
+
+	// Validator set aktif dan perubahan yang menunggu EffectiveHeight
+	Validators       map[string]types.ValidatorConfig
+	ValidatorChanges map[string]types.ValidatorChange
+
+	// Index sekunder claim untuk deteksi duplikasi (diturunkan dari Claims, tidak masuk state tree)
+	claimsByRekamMedis       map[string][]string // rekam medis id -> claim id
+	claimsByPesertaDiagnosis map[string][]string // peserta|diagnosis -> claim id, urut waktu submit
+
+	// Index sekunder untuk query API (juga tidak masuk state tree)
+	claimsByFaskes    map[string][]string            // faskes id -> claim id, urut waktu submit
+	claimsByStatus    map[string]map[string]struct{} // status -> set claim id
+	claimsBySubmit    []claimSubmit                  // semua claim urut waktu submit (lalu id)
+	rujukansByPeserta map[string][]string            // nik peserta -> rujukan id, urut waktu dibuat
+
+	// Authenticated state tree atas semua asset, root-nya menjadi state root block
+	tree     *smtNode
+	versions map[uint64]*smtNode // snapshot root per height yang sudah committed
+
+	// Overlay copy-on-write (parent nil untuk state utama): map di atas hanya berisi
+	// perubahan, key lain dibaca dari parent. journal diulang ke parent saat Flush
+	parent            *WorldState
+	parentTree        *smtNode // root parent saat overlay dibuat
+	journal           []func(s *WorldState)
+	removedValidators map[string]bool
+	removedChanges    map[string]bool
+
+	mux sync.RWMutex
+}
+
+// Entry index waktu submit claim
+type claimSubmit struct {
+	submittedAt int64
+	claimID     string
+}
+
+func compareClaimSubmit(a, b claimSubmit) int {
+	return cmp.Or(cmp.Compare(a.submittedAt, b.submittedAt), cmp.Compare(a.claimID, b.claimID))
 }
 
 func CreateWorldState() *WorldState {
@@ -22,83 +90,446 @@ func CreateWorldState() *WorldState {
 		VisitRecord: make(map[string]types.TxVisit),
 		Rujukans:    make(map[string]types.RujukanAsset),
 		Claims:      make(map[string]types.ClaimAsset),
+		Roles:       make(map[string]string),
+		Regional:    make(map[string]string),
+		Config:      make(map[string]string),
+		Nonces:      make(map[string]uint64),
+
+		Validators:       make(map[string]types.ValidatorConfig),
+		ValidatorChanges: make(map[string]types.ValidatorChange),
+
+		claimsByRekamMedis:       make(map[string][]string),
+		claimsByPesertaDiagnosis: make(map[string][]string),
+
+		claimsByFaskes:    make(map[string][]string),
+		claimsByStatus:    make(map[string]map[string]struct{}),
+		rujukansByPeserta: make(map[string][]string),
+
+		versions: make(map[uint64]*smtNode),
+	}
+}
+
+// Key asset pada state tree
+func StateKey(namespace string, id string) []byte {
+	key := sha256.Sum256([]byte(namespace + ":" + id))
+	return key[:]
+}
+
+// Overlay copy-on-write di atas world state ini untuk eksekusi sementara.
+// Perubahan hanya terlihat di overlay sampai Flush dan hilang jika overlay dibuang.
+// World state ini tidak boleh berubah selama overlay dipakai
+func (ws *WorldState) Overlay() *WorldState {
+	ws.mux.RLock()
+	defer ws.mux.RUnlock()
+
+	overlay := CreateWorldState()
+	overlay.parent = ws
+	overlay.parentTree = ws.tree
+	overlay.tree = ws.tree
+	overlay.removedValidators = make(map[string]bool)
+	overlay.removedChanges = make(map[string]bool)
+	return overlay
+}
+
+// Terapkan perubahan overlay ke parent sesuai urutan. Overlay tidak dipakai lagi setelahnya
+func (ws *WorldState) Flush() {
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+
+	parent := ws.parent
+	parent.mux.Lock()
+	defer parent.mux.Unlock()
+
+	if parent.tree != ws.parentTree {
+		panic("world state changed while an overlay was in use")
+	}
+
+	for _, change := range ws.journal {
+		parent.apply(change)
+	}
+	parent.tree = ws.tree
+}
+
+// Jalankan perubahan map & index (dipanggil dengan lock).
+// Overlay mencatatnya agar bisa diulang ke parent saat Flush
+func (ws *WorldState) apply(change func(s *WorldState)) {
+	change(ws)
+	if ws.parent != nil {
+		ws.journal = append(ws.journal, change)
 	}
 }
 
+// Cari id di map overlay lalu di parent (dipanggil dengan lock)
+func layered[V any](ws *WorldState, local map[string]V, id string, get func(parent *WorldState, id string) (V, bool)) (V, bool) {
+	if value, exists := local[id]; exists {
+		return value, true
+	}
+	if ws.parent == nil {
+		var zero V
+		return zero, false
+	}
+	return get(ws.parent, id)
+}
+
+// Update leaf asset pada state tree (dipanggil dengan lock)
+func (ws *WorldState) updateTree(namespace string, id string, asset any) {
+	value, err := json.Marshal(asset)
+	if err != nil {
+		panic(fmt.Sprintf("state asset marshal failed: %v", err))
+	}
+
+	ws.tree = smtUpdate(ws.tree, 0, StateKey(namespace, id), value)
+}
+
+// Hapus leaf dari state tree (dipanggil dengan lock)
+func (ws *WorldState) deleteTree(namespace string, id string) {
+	ws.tree = smtUpdate(ws.tree, 0, StateKey(namespace, id), nil)
+}
+
 func (ws *WorldState) AddVisit(visit types.TxVisit) {
 	ws.mux.Lock()
 	defer ws.mux.Unlock()
 
-	ws.VisitRecord[visit.RekamMedisID] = visit
+	ws.apply(func(s *WorldState) { s.VisitRecord[visit.RekamMedisID] = visit })
+	ws.updateTree(NamespaceVisit, visit.RekamMedisID, visit)
 }
 
 func (ws *WorldState) AddClaim(claim types.ClaimAsset) {
 	ws.mux.Lock()
 	defer ws.mux.Unlock()
 
+	ws.apply(func(s *WorldState) { s.setClaim(claim) })
+	ws.updateTree(NamespaceClaim, claim.ClaimID, claim)
+}
+
+func (ws *WorldState) setClaim(claim types.ClaimAsset) {
+	// Index hanya ditambah saat claim pertama kali dicatat
+	old, exists := layered(ws, ws.Claims, claim.ClaimID, (*WorldState).GetClaim)
+	if !exists {
+		if claim.RekamMedisID != "" {
+			ws.claimsByRekamMedis[claim.RekamMedisID] = append(ws.claimsByRekamMedis[claim.RekamMedisID], claim.ClaimID)
+		}
+		if claim.PesertaID != "" {
+			key := pesertaDiagnosisKey(claim.PesertaID, claim.DiagnosisCode)
+			ws.claimsByPesertaDiagnosis[key] = append(ws.claimsByPesertaDiagnosis[key], claim.ClaimID)
+		}
+		ws.claimsByFaskes[claim.FaskesID] = append(ws.claimsByFaskes[claim.FaskesID], claim.ClaimID)
+	}
+
+	// Index waktu submit tetap urut, claim yang waktu submit-nya berubah dipindah
+	if !exists || old.SubmittedAt != claim.SubmittedAt {
+		if exists {
+			oldEntry := claimSubmit{old.SubmittedAt, claim.ClaimID}
+			if i, found := slices.BinarySearchFunc(ws.claimsBySubmit, oldEntry, compareClaimSubmit); found {
+				ws.claimsBySubmit = slices.Delete(ws.claimsBySubmit, i, i+1)
+			}
+		}
+		entry := claimSubmit{claim.SubmittedAt, claim.ClaimID}
+		i, _ := slices.BinarySearchFunc(ws.claimsBySubmit, entry, compareClaimSubmit)
+		ws.claimsBySubmit = slices.Insert(ws.claimsBySubmit, i, entry)
+	}
+
+	// Index status mengikuti perubahan status claim
+	if exists && old.Status != claim.Status {
+		delete(ws.claimsByStatus[old.Status], claim.ClaimID)
+	}
+	if ws.claimsByStatus[claim.Status] == nil {
+		ws.claimsByStatus[claim.Status] = make(map[string]struct{})
+	}
+	ws.claimsByStatus[claim.Status][claim.ClaimID] = struct{}{}
+
 	ws.Claims[claim.ClaimID] = claim
 }
 
+func pesertaDiagnosisKey(pesertaID string, diagnosisCode string) string {
+	return pesertaID + "|" + strings.ToUpper(diagnosisCode)
+}
+
+// Semua claim atas rekam medis yang sama
+func (ws *WorldState) GetClaimsByRekamMedis(rekamMedisID string) []types.ClaimAsset {
+	ws.mux.RLock()
+	defer ws.mux.RUnlock()
+
+	return ws.claimsFromIndex(ws.indexedClaimIDs(func(s *WorldState) []string {
+		return s.claimsByRekamMedis[rekamMedisID]
+	}))
+}
+
+// Semua claim peserta dengan diagnosis yang sama, urut waktu submit
+func (ws *WorldState) GetClaimsByPesertaDiagnosis(pesertaID string, diagnosisCode string) []types.ClaimAsset {
+	ws.mux.RLock()
+	defer ws.mux.RUnlock()
+
+	key := pesertaDiagnosisKey(pesertaID, diagnosisCode)
+	return ws.claimsFromIndex(ws.indexedClaimIDs(func(s *WorldState) []string {
+		return s.claimsByPesertaDiagnosis[key]
+	}))
+}
+
+// Id claim pada index parent diikuti claim baru di overlay (dipanggil dengan lock)
+func (ws *WorldState) indexedClaimIDs(index func(s *WorldState) []string) []string {
+	if ws.parent == nil {
+		return index(ws)
+	}
+
+	ws.parent.mux.RLock()
+	ids := slices.Clone(ws.parent.indexedClaimIDs(index))
+	ws.parent.mux.RUnlock()
+
+	return append(ids, index(ws)...)
+}
+
+func (ws *WorldState) claimsFromIndex(claimIDs []string) []types.ClaimAsset {
+	claims := make([]types.ClaimAsset, 0, len(claimIDs))
+	for _, id := range claimIDs {
+		claim, _ := layered(ws, ws.Claims, id, (*WorldState).GetClaim)
+		claims = append(claims, claim)
+	}
+	return claims
+}
+
 func (ws *WorldState) AddRujukan(rujukan types.RujukanAsset) {
 	ws.mux.Lock()
 	defer ws.mux.Unlock()
 
+	ws.apply(func(s *WorldState) { s.setRujukan(rujukan) })
+	ws.updateTree(NamespaceRujukan, rujukan.ID, rujukan)
+}
+
+func (ws *WorldState) setRujukan(rujukan types.RujukanAsset) {
+	if _, exists := layered(ws, ws.Rujukans, rujukan.ID, (*WorldState).GetRujukan); !exists {
+		ws.rujukansByPeserta[rujukan.PesertaID] = append(ws.rujukansByPeserta[rujukan.PesertaID], rujukan.ID)
+	}
+
 	ws.Rujukans[rujukan.ID] = rujukan
 }
 
-func (ws *WorldState) GetVisit(rekamMedisID string) (types.TxVisit, bool) {
+// Role on-chain yang menentukan tx apa saja yang boleh dikirim participant
+func (ws *WorldState) SetRole(participantID string, role string) {
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+
+	ws.apply(func(s *WorldState) { s.Roles[participantID] = role })
+	ws.updateTree(NamespaceRole, participantID, role)
+}
+
+func (ws *WorldState) GetRole(participantID string) (string, bool) {
 	ws.mux.RLock()
 	defer ws.mux.RUnlock()
 
-	visit, exists := ws.VisitRecord[rekamMedisID]
-	return visit, exists
+	return layered(ws, ws.Roles, participantID, (*WorldState).GetRole)
 }
 
-func (ws *WorldState) GetClaim(claimID string) (types.ClaimAsset, bool) {
+func (ws *WorldState) SetRegionalTier(faskesID string, tier string) {
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+
+	ws.apply(func(s *WorldState) { s.Regional[faskesID] = tier })
+	ws.updateTree(NamespaceRegional, faskesID, tier)
+}
+
+func (ws *WorldState) GetRegionalTier(faskesID string) (string, bool) {
 	ws.mux.RLock()
 	defer ws.mux.RUnlock()
 
-	claim, exists := ws.Claims[claimID]
-	return claim, exists
+	return layered(ws, ws.Regional, faskesID, (*WorldState).GetRegionalTier)
 }
 
-func (ws *WorldState) GetRujukan(rujukanID string) (types.RujukanAsset, bool) {
+// Parameter chain yang disepakati semua validator
+func (ws *WorldState) SetConfig(key string, value string) {
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+
+	ws.apply(func(s *WorldState) { s.Config[key] = value })
+	ws.updateTree(NamespaceConfig, key, value)
+}
+
+func (ws *WorldState) GetConfig(key string) (string, bool) {
 	ws.mux.RLock()
 	defer ws.mux.RUnlock()
 
-	rujukan, exists := ws.Rujukans[rujukanID]
-	return rujukan, exists
+	return layered(ws, ws.Config, key, (*WorldState).GetConfig)
 }
 
-func (ws *WorldState) CalculateHash() string {
+// Nonce tx terakhir pengirim, tx berikutnya harus memakai nonce lebih besar
+func (ws *WorldState) SetNonce(senderID string, nonce uint64) {
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+
+	ws.apply(func(s *WorldState) { s.Nonces[senderID] = nonce })
+	ws.updateTree(NamespaceNonce, senderID, nonce)
+}
+
+// Pengirim yang belum pernah mengirim tx memiliki nonce 0
+func (ws *WorldState) GetNonce(senderID string) uint64 {
+	ws.mux.RLock()
+	defer ws.mux.RUnlock()
+
+	nonce, _ := layered(ws, ws.Nonces, senderID, func(parent *WorldState, id string) (uint64, bool) {
+		return parent.GetNonce(id), true
+	})
+	return nonce
+}
+
+func (ws *WorldState) SetValidator(validator types.ValidatorConfig) {
 	ws.mux.Lock()
 	defer ws.mux.Unlock()
 
-	// Sort asset agar hash deterministic
-	var rujukansArr []string
-	var claimsArr []string
+	ws.apply(func(s *WorldState) {
+		s.Validators[validator.ID] = validator
+		delete(s.removedValidators, validator.ID)
+	})
+	ws.updateTree(NamespaceValidator, validator.ID, validator)
+}
+
+func (ws *WorldState) RemoveValidator(validatorID string) {
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+
+	ws.apply(func(s *WorldState) {
+		delete(s.Validators, validatorID)
+		if s.parent != nil {
+			s.removedValidators[validatorID] = true
+		}
+	})
+	ws.deleteTree(NamespaceValidator, validatorID)
+}
+
+func (ws *WorldState) GetValidator(validatorID string) (types.ValidatorConfig, bool) {
+	ws.mux.RLock()
+	defer ws.mux.RUnlock()
 
-	for k := range ws.Rujukans {
-		rujukansArr = append(rujukansArr, k)
+	if ws.removedValidators[validatorID] {
+		return types.ValidatorConfig{}, false
 	}
+	return layered(ws, ws.Validators, validatorID, (*WorldState).GetValidator)
+}
+
+// Validator set aktif, urut berdasarkan id
+func (ws *WorldState) GetValidators() []types.ValidatorConfig {
+	ws.mux.RLock()
+	defer ws.mux.RUnlock()
 
-	for k := range ws.Claims {
-		claimsArr = append(claimsArr, k)
+	merged := maps.Clone(ws.Validators)
+	if ws.parent != nil {
+		for _, validator := range ws.parent.GetValidators() {
+			if _, overridden := merged[validator.ID]; !overridden && !ws.removedValidators[validator.ID] {
+				merged[validator.ID] = validator
+			}
+		}
 	}
 
-	sort.Strings(rujukansArr)
-	sort.Strings(claimsArr)
+	validators := slices.Collect(maps.Values(merged))
+	slices.SortFunc(validators, func(a, b types.ValidatorConfig) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return validators
+}
 
-	var combinedData string
-	for _, k := range rujukansArr {
-		rujukan := ws.Rujukans[k]
-		combinedData += fmt.Sprintf("%s:%s:%s|", rujukan.ID, rujukan.RekamMedisHash, rujukan.Status)
+func (ws *WorldState) ScheduleValidatorChange(change types.ValidatorChange) {
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+
+	ws.apply(func(s *WorldState) {
+		s.ValidatorChanges[change.ID] = change
+		delete(s.removedChanges, change.ID)
+	})
+	ws.updateTree(NamespaceValidatorChange, change.ID, change)
+}
+
+func (ws *WorldState) RemoveValidatorChange(changeID string) {
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+
+	ws.apply(func(s *WorldState) {
+		delete(s.ValidatorChanges, changeID)
+		if s.parent != nil {
+			s.removedChanges[changeID] = true
+		}
+	})
+	ws.deleteTree(NamespaceValidatorChange, changeID)
+}
+
+// Perubahan validator yang menunggu, urut berdasarkan EffectiveHeight lalu id
+func (ws *WorldState) GetValidatorChanges() []types.ValidatorChange {
+	ws.mux.RLock()
+	defer ws.mux.RUnlock()
+
+	merged := maps.Clone(ws.ValidatorChanges)
+	if ws.parent != nil {
+		for _, change := range ws.parent.GetValidatorChanges() {
+			if _, overridden := merged[change.ID]; !overridden && !ws.removedChanges[change.ID] {
+				merged[change.ID] = change
+			}
+		}
 	}
-	for _, k := range claimsArr {
-		claim := ws.Claims[k]
-		combinedData += fmt.Sprintf("%s:%s:%s|", claim.ClaimID, claim.RekamMedisHash, claim.Status)
+
+	changes := slices.Collect(maps.Values(merged))
+	slices.SortFunc(changes, func(a, b types.ValidatorChange) int {
+		if a.EffectiveHeight != b.EffectiveHeight {
+			return cmp.Compare(a.EffectiveHeight, b.EffectiveHeight)
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return changes
+}
+
+func (ws *WorldState) GetVisit(rekamMedisID string) (types.TxVisit, bool) {
+	ws.mux.RLock()
+	defer ws.mux.RUnlock()
+
+	return layered(ws, ws.VisitRecord, rekamMedisID, (*WorldState).GetVisit)
+}
+
+func (ws *WorldState) GetClaim(claimID string) (types.ClaimAsset, bool) {
+	ws.mux.RLock()
+	defer ws.mux.RUnlock()
+
+	return layered(ws, ws.Claims, claimID, (*WorldState).GetClaim)
+}
+
+func (ws *WorldState) GetRujukan(rujukanID string) (types.RujukanAsset, bool) {
+	ws.mux.RLock()
+	defer ws.mux.RUnlock()
+
+	return layered(ws, ws.Rujukans, rujukanID, (*WorldState).GetRujukan)
+}
+
+// State root saat ini (root hash state tree)
+func (ws *WorldState) CalculateHash() string {
+	ws.mux.RLock()
+	defer ws.mux.RUnlock()
+
+	return hex.EncodeToString(nodeHash(ws.tree, 0))
+}
+
+// Simpan snapshot state setelah block pada height di commit
+func (ws *WorldState) Commit(height uint64) {
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+
+	tree := ws.tree
+	ws.apply(func(s *WorldState) {
+		s.versions[height] = tree
+		if height >= StateVersionsRetained {
+			delete(s.versions, height-StateVersionsRetained)
+		}
+	})
+}
+
+// Buat proof asset pada height tertentu.
+// Return value asset (json, nil jika tidak ada), state root pada height tersebut dan proof
+func (ws *WorldState) Prove(namespace string, id string, height uint64) ([]byte, string, MerkleProof, error) {
+	ws.mux.RLock()
+	defer ws.mux.RUnlock()
+
+	root, exists := ws.versions[height]
+	if !exists {
+		return nil, "", MerkleProof{}, fmt.Errorf("state at height %d is not available", height)
 	}
 
-	hash := sha256.Sum256([]byte(combinedData))
-	return hex.EncodeToString(hash[:])
+	key := StateKey(namespace, id)
+	value, _ := smtGet(root, key)
+
+	return value, hex.EncodeToString(nodeHash(root, 0)), smtProve(root, key), nil
 }
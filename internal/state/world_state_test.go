@@ -0,0 +1,246 @@
+package state
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/bpjs-hackathon/sehat-chain/types"
+)
+
+func TestOverlayFlushAndDiscard(t *testing.T) {
+	seed := func(ws *WorldState) {
+		ws.AddClaim(types.ClaimAsset{ClaimID: "c-1", RekamMedisID: "rm-1", Status: types.ClaimStatusPending})
+		ws.SetValidator(types.ValidatorConfig{ID: "v-1"})
+		ws.SetValidator(types.ValidatorConfig{ID: "v-2"})
+	}
+	mutate := func(ws *WorldState) {
+		ws.AddClaim(types.ClaimAsset{ClaimID: "c-1", RekamMedisID: "rm-1", Status: types.ClaimStatusApproved})
+		ws.AddClaim(types.ClaimAsset{ClaimID: "c-2", RekamMedisID: "rm-1", Status: types.ClaimStatusFaked})
+		ws.RemoveValidator("v-1")
+		ws.SetNonce("faskes-1", 7)
+		ws.Commit(1)
+	}
+
+	// Hasil yang diharapkan: perubahan yang sama langsung pada state utama
+	want := CreateWorldState()
+	seed(want)
+	mutate(want)
+
+	tests := []struct {
+		name  string
+		flush bool
+	}{
+		{"discard", false},
+		{"flush", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := CreateWorldState()
+			seed(ws)
+			baseRoot := ws.CalculateHash()
+
+			overlay := ws.Overlay()
+			mutate(overlay)
+
+			// Overlay membaca perubahannya sendiri di atas parent
+			if got := overlay.CalculateHash(); got != want.CalculateHash() {
+				t.Fatalf("overlay root %s, want %s", got, want.CalculateHash())
+			}
+			if claims := overlay.GetClaimsByRekamMedis("rm-1"); len(claims) != 2 || claims[0].Status != types.ClaimStatusApproved {
+				t.Fatalf("overlay claims by rekam medis = %+v", claims)
+			}
+			if validators := overlay.GetValidators(); len(validators) != 1 || validators[0].ID != "v-2" {
+				t.Fatalf("overlay validators = %+v", validators)
+			}
+
+			// Parent tidak berubah sebelum Flush
+			if got := ws.CalculateHash(); got != baseRoot {
+				t.Fatalf("parent root changed before flush: %s", got)
+			}
+			if _, exists := ws.GetClaim("c-2"); exists {
+				t.Fatal("overlay claim visible in parent before flush")
+			}
+			if _, _, _, err := ws.Prove(NamespaceClaim, "c-2", 1); err == nil {
+				t.Fatal("overlay snapshot visible in parent before flush")
+			}
+
+			if !tt.flush {
+				return
+			}
+
+			overlay.Flush()
+			if got := ws.CalculateHash(); got != want.CalculateHash() {
+				t.Fatalf("root after flush %s, want %s", got, want.CalculateHash())
+			}
+			if claims := ws.GetClaimsByRekamMedis("rm-1"); len(claims) != 2 {
+				t.Fatalf("index after flush = %+v", claims)
+			}
+			if _, exists := ws.GetValidator("v-1"); exists {
+				t.Fatal("removed validator still present after flush")
+			}
+			if nonce := ws.GetNonce("faskes-1"); nonce != 7 {
+				t.Fatalf("nonce after flush = %d", nonce)
+			}
+			if value, root, _, err := ws.Prove(NamespaceClaim, "c-2", 1); err != nil || value == nil || root != want.CalculateHash() {
+				t.Fatalf("snapshot after flush: value %s, root %s, err %v", value, root, err)
+			}
+		})
+	}
+}
+
+// Claim c-1..c-6 dari dua faskes, SubmittedAt 100..600 (dicatat tidak urut)
+func seedQueryClaims(ws *WorldState) {
+	for _, i := range []int{3, 1, 6, 2, 5, 4} {
+		faskes := "faskes-1"
+		if i%2 == 0 {
+			faskes = "faskes-2"
+		}
+		status := types.ClaimStatusPending
+		if i > 4 {
+			status = types.ClaimStatusApproved
+		}
+		ws.AddClaim(types.ClaimAsset{
+			ClaimID:      fmt.Sprintf("c-%d", i),
+			FaskesID:     faskes,
+			RekamMedisID: fmt.Sprintf("rm-%d", i),
+			Status:       status,
+			SubmittedAt:  int64(i * 100),
+		})
+	}
+}
+
+func claimIDs(claims []types.ClaimAsset) []string {
+	ids := make([]string, 0, len(claims))
+	for _, claim := range claims {
+		ids = append(ids, claim.ClaimID)
+	}
+	return ids
+}
+
+func TestQueryClaims(t *testing.T) {
+	ws := CreateWorldState()
+	seedQueryClaims(ws)
+
+	// Status claim berubah setelah dicatat, index status & waktu submit ikut berubah
+	ws.AddClaim(types.ClaimAsset{ClaimID: "c-3", FaskesID: "faskes-1", RekamMedisID: "rm-3", Status: types.ClaimStatusFaked, SubmittedAt: 300})
+
+	tests := []struct {
+		name   string
+		filter ClaimFilter
+		offset int
+		limit  int
+		want   []string
+		total  int
+	}{
+		{"all", ClaimFilter{}, 0, 10, []string{"c-1", "c-2", "c-3", "c-4", "c-5", "c-6"}, 6},
+		{"from", ClaimFilter{From: 300}, 0, 10, []string{"c-3", "c-4", "c-5", "c-6"}, 4},
+		{"to", ClaimFilter{To: 250}, 0, 10, []string{"c-1", "c-2"}, 2},
+		{"from to inclusive", ClaimFilter{From: 200, To: 400}, 0, 10, []string{"c-2", "c-3", "c-4"}, 3},
+		{"empty range", ClaimFilter{From: 410, To: 490}, 0, 10, []string{}, 0},
+		{"from after to", ClaimFilter{From: 500, To: 100}, 0, 10, []string{}, 0},
+		{"faskes", ClaimFilter{FaskesID: "faskes-2"}, 0, 10, []string{"c-2", "c-4", "c-6"}, 3},
+		{"faskes and range", ClaimFilter{FaskesID: "faskes-1", From: 200}, 0, 10, []string{"c-3", "c-5"}, 2},
+		{"status", ClaimFilter{Status: types.ClaimStatusPending}, 0, 10, []string{"c-1", "c-2", "c-4"}, 3},
+		{"changed status", ClaimFilter{Status: types.ClaimStatusFaked}, 0, 10, []string{"c-3"}, 1},
+		{"faskes and status", ClaimFilter{FaskesID: "faskes-2", Status: types.ClaimStatusApproved}, 0, 10, []string{"c-6"}, 1},
+		{"page", ClaimFilter{}, 2, 2, []string{"c-3", "c-4"}, 6},
+		{"last page", ClaimFilter{}, 4, 5, []string{"c-5", "c-6"}, 6},
+		{"offset past end", ClaimFilter{}, 6, 2, []string{}, 6},
+		{"page of range", ClaimFilter{From: 200, To: 500}, 1, 2, []string{"c-3", "c-4"}, 4},
+		{"page of status", ClaimFilter{Status: types.ClaimStatusPending}, 1, 1, []string{"c-2"}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, total := ws.QueryClaims(tt.filter, tt.offset, tt.limit)
+			if got := claimIDs(claims); !slices.Equal(got, tt.want) || total != tt.total {
+				t.Fatalf("got %v (total %d), want %v (total %d)", got, total, tt.want, tt.total)
+			}
+		})
+	}
+}
+
+func TestQueryClaimsAfterOverlayFlush(t *testing.T) {
+	ws := CreateWorldState()
+	overlay := ws.Overlay()
+	seedQueryClaims(overlay)
+	overlay.Flush()
+
+	claims, total := ws.QueryClaims(ClaimFilter{From: 200, To: 300}, 0, 10)
+	if got := claimIDs(claims); !slices.Equal(got, []string{"c-2", "c-3"}) || total != 2 {
+		t.Fatalf("got %v (total %d) after flush", got, total)
+	}
+}
+
+func TestQueryVisit(t *testing.T) {
+	ws := CreateWorldState()
+	seedQueryClaims(ws)
+	ws.AddVisit(types.TxVisit{RekamMedisID: "rm-2", RekamMedisHash: "hash-2"})
+	ws.AddClaim(types.ClaimAsset{ClaimID: "c-7", RekamMedisID: "rm-2", Status: types.ClaimStatusFaked, SubmittedAt: 700})
+
+	visit, exists := ws.GetVisit("rm-2")
+	if !exists || visit.RekamMedisHash != "hash-2" {
+		t.Fatalf("visit rm-2 = %+v, %v", visit, exists)
+	}
+	if got := claimIDs(ws.GetClaimsByRekamMedis("rm-2")); !slices.Equal(got, []string{"c-2", "c-7"}) {
+		t.Fatalf("claims of rm-2 = %v", got)
+	}
+
+	if _, exists := ws.GetVisit("rm-404"); exists {
+		t.Fatal("unknown visit found")
+	}
+	if claims := ws.GetClaimsByRekamMedis("rm-404"); len(claims) != 0 {
+		t.Fatalf("claims of unknown rekam medis = %v", claimIDs(claims))
+	}
+}
+
+func TestGetRujukansByPeserta(t *testing.T) {
+	ws := CreateWorldState()
+	for i := 1; i <= 5; i++ {
+		peserta := "nik-1"
+		if i == 3 {
+			peserta = "nik-2"
+		}
+		ws.AddRujukan(types.RujukanAsset{ID: fmt.Sprintf("r-%d", i), PesertaID: peserta, Status: types.RujukanStatusActive, IssueDate: int64(i)})
+	}
+	// Update status tidak menambah entry index
+	ws.AddRujukan(types.RujukanAsset{ID: "r-2", PesertaID: "nik-1", Status: types.RujukanStatusUsed, IssueDate: 2})
+
+	rujukanIDs := func(rujukans []types.RujukanAsset) []string {
+		ids := make([]string, 0, len(rujukans))
+		for _, rujukan := range rujukans {
+			ids = append(ids, rujukan.ID)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name    string
+		peserta string
+		offset  int
+		limit   int
+		want    []string
+		total   int
+	}{
+		{"all", "nik-1", 0, 10, []string{"r-1", "r-2", "r-4", "r-5"}, 4},
+		{"other peserta", "nik-2", 0, 10, []string{"r-3"}, 1},
+		{"unknown peserta", "nik-404", 0, 10, []string{}, 0},
+		{"page", "nik-1", 1, 2, []string{"r-2", "r-4"}, 4},
+		{"offset past end", "nik-1", 4, 2, []string{}, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rujukans, total := ws.GetRujukansByPeserta(tt.peserta, tt.offset, tt.limit)
+			if got := rujukanIDs(rujukans); !slices.Equal(got, tt.want) || total != tt.total {
+				t.Fatalf("got %v (total %d), want %v (total %d)", got, total, tt.want, tt.total)
+			}
+		})
+	}
+
+	if rujukans, _ := ws.GetRujukansByPeserta("nik-1", 1, 1); rujukans[0].Status != types.RujukanStatusUsed {
+		t.Fatalf("updated rujukan = %+v", rujukans[0])
+	}
+}
@@ -0,0 +1,394 @@
+// Penyimpanan block append-only di disk (segmented log + index)
+package store
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bpjs-hackathon/sehat-chain/types"
+)
+
+const (
+	SegmentMaxBlocks = 4096 // jumlah block per file segment
+
+	recordHeaderSize = 8 // 4 byte panjang data + 4 byte crc32
+	indexEntrySize   = 8 // offset record di file log
+
+	segmentPrefix = "blocks-"
+	logSuffix     = ".log"
+	indexSuffix   = ".idx"
+)
+
+var ErrNotFound = errors.New("block not found")
+
+// Lokasi record block pada segment
+type location struct {
+	segment int
+	offset  int64
+}
+
+type segment struct {
+	id    int
+	log   *os.File
+	index *os.File
+	size  int64 // ukuran file log yang valid
+	count int   // jumlah block di segment
+}
+
+// Isi satu record: block beserta receipt hasil eksekusinya
+type blockRecord struct {
+	Block    types.Block     `json:"block"`
+	Receipts []types.Receipt `json:"receipts"`
+}
+
+// BlockStore menyimpan block berurutan berdasarkan height (record ke-n = height n).
+// Setiap record: [panjang uint32][crc32 uint32][block + receipts json]
+type BlockStore struct {
+	dir       string
+	segments  []*segment
+	locations []location // index height -> lokasi record
+	mux       sync.RWMutex
+}
+
+// Buka (atau buat) block store di dir. Segment terakhir di scan ulang
+// untuk mendeteksi torn write akibat crash saat menulis block terakhir
+func OpenBlockStore(dir string) (*BlockStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	bs := &BlockStore{dir: dir}
+
+	ids, err := bs.listSegments()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, id := range ids {
+		seg, err := bs.openSegment(id)
+		if err != nil {
+			bs.Close()
+			return nil, err
+		}
+
+		isLast := i == len(ids)-1
+		if isLast {
+			err = bs.recoverSegment(seg)
+		} else {
+			err = bs.loadSegmentIndex(seg)
+		}
+		if err != nil {
+			bs.Close()
+			return nil, fmt.Errorf("segment %d: %w", id, err)
+		}
+
+		bs.segments = append(bs.segments, seg)
+	}
+
+	return bs, nil
+}
+
+// Jumlah block yang tersimpan
+func (bs *BlockStore) Len() uint64 {
+	bs.mux.RLock()
+	defer bs.mux.RUnlock()
+
+	return uint64(len(bs.locations))
+}
+
+// Tambah block dan receipt-nya ke akhir log lalu fsync sebelum return
+func (bs *BlockStore) Append(block types.Block, receipts []types.Receipt) error {
+	bs.mux.Lock()
+	defer bs.mux.Unlock()
+
+	if block.Header.Height != uint64(len(bs.locations)) {
+		return fmt.Errorf("block store expecting height %d, got %d", len(bs.locations), block.Header.Height)
+	}
+
+	data, err := json.Marshal(blockRecord{Block: block, Receipts: receipts})
+	if err != nil {
+		return err
+	}
+
+	seg, err := bs.activeSegment()
+	if err != nil {
+		return err
+	}
+
+	record := make([]byte, recordHeaderSize+len(data))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(data))
+	copy(record[recordHeaderSize:], data)
+
+	offset := seg.size
+	if _, err := seg.log.WriteAt(record, offset); err != nil {
+		return err
+	}
+	if err := seg.log.Sync(); err != nil {
+		return err
+	}
+
+	var entry [indexEntrySize]byte
+	binary.BigEndian.PutUint64(entry[:], uint64(offset))
+	if _, err := seg.index.WriteAt(entry[:], int64(seg.count)*indexEntrySize); err != nil {
+		return err
+	}
+	if err := seg.index.Sync(); err != nil {
+		return err
+	}
+
+	seg.size += int64(len(record))
+	seg.count++
+	bs.locations = append(bs.locations, location{segment: len(bs.segments) - 1, offset: offset})
+
+	return nil
+}
+
+// Ambil block berdasarkan height
+func (bs *BlockStore) Get(height uint64) (types.Block, error) {
+	record, err := bs.getRecord(height)
+	if err != nil {
+		return types.Block{}, err
+	}
+
+	return record.Block, nil
+}
+
+// Ambil receipt tx block pada height
+func (bs *BlockStore) GetReceipts(height uint64) ([]types.Receipt, error) {
+	record, err := bs.getRecord(height)
+	if err != nil {
+		return nil, err
+	}
+
+	return record.Receipts, nil
+}
+
+func (bs *BlockStore) getRecord(height uint64) (blockRecord, error) {
+	bs.mux.RLock()
+	defer bs.mux.RUnlock()
+
+	if height >= uint64(len(bs.locations)) {
+		return blockRecord{}, ErrNotFound
+	}
+
+	loc := bs.locations[height]
+	data, err := readRecord(bs.segments[loc.segment].log, loc.offset)
+	if err != nil {
+		return blockRecord{}, fmt.Errorf("failed to read block %d: %w", height, err)
+	}
+
+	var record blockRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return blockRecord{}, fmt.Errorf("failed to decode block %d: %w", height, err)
+	}
+
+	return record, nil
+}
+
+func (bs *BlockStore) Close() error {
+	bs.mux.Lock()
+	defer bs.mux.Unlock()
+
+	var firstErr error
+	for _, seg := range bs.segments {
+		for _, f := range []*os.File{seg.log, seg.index} {
+			if err := f.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	bs.segments = nil
+
+	return firstErr
+}
+
+// Segment yang sedang ditulis, buat segment baru jika sudah penuh
+func (bs *BlockStore) activeSegment() (*segment, error) {
+	if len(bs.segments) > 0 {
+		last := bs.segments[len(bs.segments)-1]
+		if last.count < SegmentMaxBlocks {
+			return last, nil
+		}
+	}
+
+	seg, err := bs.openSegment(len(bs.segments))
+	if err != nil {
+		return nil, err
+	}
+
+	bs.segments = append(bs.segments, seg)
+	return seg, nil
+}
+
+func (bs *BlockStore) listSegments() ([]int, error) {
+	entries, err := os.ReadDir(bs.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, segmentPrefix) || !strings.HasSuffix(name, logSuffix) {
+			continue
+		}
+
+		var id int
+		if _, err := fmt.Sscanf(strings.TrimSuffix(strings.TrimPrefix(name, segmentPrefix), logSuffix), "%06d", &id); err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for i, id := range ids {
+		if id != i {
+			return nil, fmt.Errorf("missing block segment %d", i)
+		}
+	}
+
+	return ids, nil
+}
+
+func (bs *BlockStore) openSegment(id int) (*segment, error) {
+	base := filepath.Join(bs.dir, fmt.Sprintf("%s%06d", segmentPrefix, id))
+
+	logFile, err := os.OpenFile(base+logSuffix, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	indexFile, err := os.OpenFile(base+indexSuffix, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		logFile.Close()
+		return nil, err
+	}
+
+	return &segment{id: id, log: logFile, index: indexFile}, nil
+}
+
+// Segment yang sudah penuh (bukan terakhir) dipercaya dari file index
+func (bs *BlockStore) loadSegmentIndex(seg *segment) error {
+	raw, err := io.ReadAll(seg.index)
+	if err != nil {
+		return err
+	}
+
+	if len(raw) != SegmentMaxBlocks*indexEntrySize {
+		return fmt.Errorf("sealed segment index has %d bytes, expecting %d", len(raw), SegmentMaxBlocks*indexEntrySize)
+	}
+
+	info, err := seg.log.Stat()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < SegmentMaxBlocks; i++ {
+		offset := int64(binary.BigEndian.Uint64(raw[i*indexEntrySize:]))
+		if offset >= info.Size() {
+			return fmt.Errorf("index entry %d points past end of log", i)
+		}
+		bs.locations = append(bs.locations, location{segment: seg.id, offset: offset})
+	}
+
+	seg.size = info.Size()
+	seg.count = SegmentMaxBlocks
+	return nil
+}
+
+// Scan segment terakhir record per record. Record yang terpotong atau crc tidak cocok
+// (torn write) dibuang dengan truncate, lalu index ditulis ulang dari hasil scan
+func (bs *BlockStore) recoverSegment(seg *segment) error {
+	info, err := seg.log.Stat()
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(seg.log, 0, info.Size()))
+	var offsets []int64
+	var offset int64
+
+	for offset < info.Size() {
+		var header [recordHeaderSize]byte
+		if _, err := io.ReadFull(reader, header[:]); err != nil {
+			break
+		}
+
+		length := int64(binary.BigEndian.Uint32(header[0:4]))
+		if offset+recordHeaderSize+length > info.Size() {
+			break
+		}
+
+		data := make([]byte, length)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			break
+		}
+
+		if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:8]) {
+			break
+		}
+
+		offsets = append(offsets, offset)
+		offset += recordHeaderSize + length
+	}
+
+	if offset < info.Size() {
+		fmt.Printf("⚠️ Torn write detected in block segment %d, truncating %d bytes\n", seg.id, info.Size()-offset)
+		if err := seg.log.Truncate(offset); err != nil {
+			return err
+		}
+		if err := seg.log.Sync(); err != nil {
+			return err
+		}
+	}
+
+	// Tulis ulang index sesuai record yang valid
+	index := make([]byte, len(offsets)*indexEntrySize)
+	for i, o := range offsets {
+		binary.BigEndian.PutUint64(index[i*indexEntrySize:], uint64(o))
+		bs.locations = append(bs.locations, location{segment: seg.id, offset: o})
+	}
+	if err := seg.index.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := seg.index.WriteAt(index, 0); err != nil {
+		return err
+	}
+	if err := seg.index.Sync(); err != nil {
+		return err
+	}
+
+	seg.size = offset
+	seg.count = len(offsets)
+	return nil
+}
+
+func readRecord(file *os.File, offset int64) ([]byte, error) {
+	var header [recordHeaderSize]byte
+	if _, err := file.ReadAt(header[:], offset); err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	data := make([]byte, length)
+	if _, err := file.ReadAt(data, offset+recordHeaderSize); err != nil {
+		return nil, err
+	}
+
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, fmt.Errorf("checksum mismatch at offset %d", offset)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,178 @@
+package store
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bpjs-hackathon/sehat-chain/types"
+)
+
+func testBlock(height uint64) types.Block {
+	return types.Block{Header: types.BlockHeader{Height: height, Timestamp: int64(1000 + height)}}
+}
+
+// Block store berisi block 0..n-1, return path file log segment pertama
+func writeTestStore(t *testing.T, dir string, n uint64) string {
+	t.Helper()
+
+	bs, err := OpenBlockStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for h := uint64(0); h < n; h++ {
+		if err := bs.Append(testBlock(h), []types.Receipt{{TxID: "tx", BlockHeight: h}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bs.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return filepath.Join(dir, segmentPrefix+"000000"+logSuffix)
+}
+
+func TestBlockStoreRecoversTornLastRecord(t *testing.T) {
+	// Panjang record terakhir di file log (header + data)
+	lastRecordSize := func(t *testing.T, logPath string) int64 {
+		raw, err := os.ReadFile(logPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var offset, last int64
+		for offset < int64(len(raw)) {
+			last = recordHeaderSize + int64(binary.BigEndian.Uint32(raw[offset:]))
+			offset += last
+		}
+		return last
+	}
+
+	tests := []struct {
+		name    string
+		corrupt func(t *testing.T, logPath string)
+		want    uint64 // jumlah block setelah recovery
+	}{
+		{
+			name:    "intact",
+			corrupt: func(t *testing.T, logPath string) {},
+			want:    3,
+		},
+		{
+			name: "truncated record data",
+			corrupt: func(t *testing.T, logPath string) {
+				info, _ := os.Stat(logPath)
+				if err := os.Truncate(logPath, info.Size()-5); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: 2,
+		},
+		{
+			name: "truncated record header",
+			corrupt: func(t *testing.T, logPath string) {
+				info, _ := os.Stat(logPath)
+				size := info.Size() - lastRecordSize(t, logPath) + recordHeaderSize/2
+				if err := os.Truncate(logPath, size); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: 2,
+		},
+		{
+			name: "checksum mismatch",
+			corrupt: func(t *testing.T, logPath string) {
+				file, err := os.OpenFile(logPath, os.O_RDWR, 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer file.Close()
+
+				info, _ := file.Stat()
+				last := make([]byte, 1)
+				file.ReadAt(last, info.Size()-2)
+				last[0] ^= 0xff
+				if _, err := file.WriteAt(last, info.Size()-2); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: 2,
+		},
+		{
+			name: "length past end of file",
+			corrupt: func(t *testing.T, logPath string) {
+				var header [recordHeaderSize]byte
+				binary.BigEndian.PutUint32(header[0:4], 1<<30)
+				appendBytes(t, logPath, header[:])
+			},
+			want: 3,
+		},
+		{
+			name: "trailing garbage",
+			corrupt: func(t *testing.T, logPath string) {
+				appendBytes(t, logPath, []byte{0x01, 0x02, 0x03})
+			},
+			want: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			logPath := writeTestStore(t, dir, 3)
+			tt.corrupt(t, logPath)
+
+			bs, err := OpenBlockStore(dir)
+			if err != nil {
+				t.Fatalf("open after corruption: %v", err)
+			}
+			defer bs.Close()
+
+			if got := bs.Len(); got != tt.want {
+				t.Fatalf("recovered %d blocks, want %d", got, tt.want)
+			}
+			for h := uint64(0); h < tt.want; h++ {
+				block, err := bs.Get(h)
+				if err != nil || block.Header.Height != h {
+					t.Fatalf("block %d after recovery: %+v, %v", h, block.Header, err)
+				}
+			}
+			if _, err := bs.Get(tt.want); err != ErrNotFound {
+				t.Fatalf("block %d after recovery: err %v, want ErrNotFound", tt.want, err)
+			}
+
+			// Block berikutnya ditulis di atas bagian yang di truncate dan bertahan setelah reopen
+			if err := bs.Append(testBlock(tt.want), nil); err != nil {
+				t.Fatalf("append after recovery: %v", err)
+			}
+			bs.Close()
+
+			reopened, err := OpenBlockStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reopened.Close()
+
+			if got := reopened.Len(); got != tt.want+1 {
+				t.Fatalf("reopened store has %d blocks, want %d", got, tt.want+1)
+			}
+			if block, err := reopened.Get(tt.want); err != nil || block.Header.Height != tt.want {
+				t.Fatalf("appended block after reopen: %+v, %v", block.Header, err)
+			}
+		})
+	}
+}
+
+func appendBytes(t *testing.T, path string, data []byte) {
+	t.Helper()
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		t.Fatal(err)
+	}
+}
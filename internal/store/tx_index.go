@@ -0,0 +1,169 @@
+package store
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+)
+
+// Posisi tx di chain
+type TxLocation struct {
+	Height uint64 `json:"height"`
+	Index  int    `json:"index"` // urutan tx di dalam block
+}
+
+// Satu record per block: id tx sesuai urutan di block
+type txIndexRecord struct {
+	Height uint64   `json:"height"`
+	TxIDs  []string `json:"tx_ids"`
+}
+
+// TxIndex menyimpan id tx -> posisi di chain dalam log append-only
+// dengan format record yang sama seperti block store ([panjang][crc32][json])
+type TxIndex struct {
+	file      *os.File
+	size      int64
+	locations map[string]TxLocation
+	next      uint64 // height berikutnya yang belum di index
+	mux       sync.RWMutex
+}
+
+// Buka (atau buat) tx index di path. Record terakhir yang terpotong (torn write) dibuang
+func OpenTxIndex(path string) (*TxIndex, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &TxIndex{
+		file:      file,
+		locations: make(map[string]TxLocation),
+	}
+
+	if err := idx.load(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("tx index %s: %w", path, err)
+	}
+
+	return idx, nil
+}
+
+// Height berikutnya yang harus di index (= jumlah block yang sudah di index)
+func (idx *TxIndex) NextHeight() uint64 {
+	idx.mux.RLock()
+	defer idx.mux.RUnlock()
+
+	return idx.next
+}
+
+// Tambahkan tx block pada height lalu fsync. Block harus di index berurutan
+func (idx *TxIndex) Append(height uint64, txIDs []string) error {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	if height != idx.next {
+		return fmt.Errorf("tx index expecting height %d, got %d", idx.next, height)
+	}
+
+	data, err := json.Marshal(txIndexRecord{Height: height, TxIDs: txIDs})
+	if err != nil {
+		return err
+	}
+
+	record := make([]byte, recordHeaderSize+len(data))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(data))
+	copy(record[recordHeaderSize:], data)
+
+	if _, err := idx.file.WriteAt(record, idx.size); err != nil {
+		return err
+	}
+	if err := idx.file.Sync(); err != nil {
+		return err
+	}
+
+	idx.size += int64(len(record))
+	idx.apply(txIndexRecord{Height: height, TxIDs: txIDs})
+	return nil
+}
+
+// Posisi tx di chain
+func (idx *TxIndex) Get(txID string) (TxLocation, bool) {
+	idx.mux.RLock()
+	defer idx.mux.RUnlock()
+
+	location, exists := idx.locations[txID]
+	return location, exists
+}
+
+func (idx *TxIndex) Close() error {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	return idx.file.Close()
+}
+
+func (idx *TxIndex) apply(record txIndexRecord) {
+	for i, txID := range record.TxIDs {
+		idx.locations[txID] = TxLocation{Height: record.Height, Index: i}
+	}
+	idx.next = record.Height + 1
+}
+
+// Baca semua record, berhenti di record pertama yang rusak lalu truncate sisanya
+func (idx *TxIndex) load() error {
+	info, err := idx.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(idx.file, 0, info.Size()))
+	var offset int64
+
+	for offset < info.Size() {
+		var header [recordHeaderSize]byte
+		if _, err := io.ReadFull(reader, header[:]); err != nil {
+			break
+		}
+
+		length := int64(binary.BigEndian.Uint32(header[0:4]))
+		if offset+recordHeaderSize+length > info.Size() {
+			break
+		}
+
+		data := make([]byte, length)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			break
+		}
+
+		if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:8]) {
+			break
+		}
+
+		var record txIndexRecord
+		if err := json.Unmarshal(data, &record); err != nil || record.Height != idx.next {
+			break
+		}
+
+		idx.apply(record)
+		offset += recordHeaderSize + length
+	}
+
+	if offset < info.Size() {
+		fmt.Printf("⚠️ Torn write detected in tx index, truncating %d bytes\n", info.Size()-offset)
+		if err := idx.file.Truncate(offset); err != nil {
+			return err
+		}
+		if err := idx.file.Sync(); err != nil {
+			return err
+		}
+	}
+
+	idx.size = offset
+	return nil
+}
@@ -32,7 +32,7 @@ New-Item -ItemType Directory -Force -Path "configs" | Out-Null
 
 # Build binary
 Write-Host "Building blockchain node..." -ForegroundColor Yellow
-$buildResult = go build -o sehat-chain.exe cmd\node\main.go
+$buildResult = go build -o sehat-chain.exe .\cmd\node
 if ($LASTEXITCODE -ne 0) {
     Write-Host "Build failed!" -ForegroundColor Red
     exit 1
@@ -40,6 +40,17 @@ if ($LASTEXITCODE -ne 0) {
 Write-Host "Build successful" -ForegroundColor Green
 Write-Host ""
 
+# Key node dan genesis.json tidak di commit, buat lokal jika belum ada
+if (-not (Test-Path "configs\genesis.json")) {
+    Write-Host "Generating local devnet keys and genesis..." -ForegroundColor Yellow
+    .\sehat-chain.exe -init-devnet configs
+    if ($LASTEXITCODE -ne 0) {
+        Write-Host "Devnet init failed!" -ForegroundColor Red
+        exit 1
+    }
+    Write-Host ""
+}
+
 # Function to start a node
 function Start-Node {
     param(
@@ -69,7 +80,8 @@ Write-Host "========================================" -ForegroundColor Cyan
 Write-Host "Auto-detecting and Starting Nodes..." -ForegroundColor Cyan
 Write-Host "========================================" -ForegroundColor Cyan
 
-$configFiles = Get-ChildItem -Path "configs" -Filter "*.json"
+# genesis.json (dan template-nya) dipakai bersama oleh semua node, bukan config node
+$configFiles = Get-ChildItem -Path "configs" -Filter "*.json" | Where-Object { $_.Name -notlike "genesis*.json" }
 
 if ($configFiles.Count -eq 0) {
     Write-Host "No configuration files found in 'configs' directory!" -ForegroundColor Red
@@ -83,10 +95,11 @@ if ($configFiles.Count -eq 0) {
             $internalId = $jsonContent.node_id
             $port = $jsonContent.port
             
-            # Determine if this node is a validator (if its ID is in the validators list)
+            # Determine if this node is a validator (if its ID is in the genesis validators list)
             $isValidator = $false
-            if ($jsonContent.validators) {
-                foreach ($v in $jsonContent.validators) {
+            $genesis = Get-Content $jsonContent.genesis_file | ConvertFrom-Json
+            if ($genesis.validators) {
+                foreach ($v in $genesis.validators) {
                     if ($v.ID -eq $internalId) {
                         $isValidator = $true
                         break
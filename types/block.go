@@ -3,8 +3,9 @@ package types
 
 import (
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
-	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -15,40 +16,60 @@ type Block struct {
 }
 
 type BlockHeader struct {
-	Height     uint64 `json:"height"`
-	Timestamp  int64  `json:"timestamp"`
-	PrevHash   string `json:"prev_hash"`
-	StateRoot  string `json:"state_root"`
-	TxRoot     string `json:"tx_root"`
-	ProposerID string `json:"proposer"`
+	Height       uint64 `json:"height"`
+	Timestamp    int64  `json:"timestamp"`
+	PrevHash     string `json:"prev_hash"`
+	StateRoot    string `json:"state_root"`
+	TxRoot       string `json:"tx_root"`
+	ReceiptsRoot string `json:"receipts_root"` // merkle root receipt hasil eksekusi tx block ini
+	ProposerID   string `json:"proposer"`
+	View         uint64 `json:"view"` // view (round) saat block dibuat, menentukan leader yang berhak propose
 }
 
+// Setiap field diberi prefix panjang seperti Transaction.Hash
+// agar batas antar field tidak ambigu
 func (b *Block) HeaderHash() string {
-	data := fmt.Sprintf("%d%d%s%s%s%s",
-		b.Header.Height,
-		b.Header.Timestamp,
-		b.Header.PrevHash,
-		b.Header.StateRoot,
-		b.Header.TxRoot,
-		b.Header.ProposerID,
-	)
-
-	hash := sha256.Sum256([]byte(data))
-	return hex.EncodeToString(hash[:])
+	h := sha256.New()
+
+	writeField := func(data []byte) {
+		var length [8]byte
+		binary.BigEndian.PutUint64(length[:], uint64(len(data)))
+		h.Write(length[:])
+		h.Write(data)
+	}
+
+	writeField([]byte(strconv.FormatUint(b.Header.Height, 10)))
+	writeField([]byte(strconv.FormatInt(b.Header.Timestamp, 10)))
+	writeField([]byte(b.Header.PrevHash))
+	writeField([]byte(b.Header.StateRoot))
+	writeField([]byte(b.Header.TxRoot))
+	writeField([]byte(b.Header.ProposerID))
+	writeField([]byte(strconv.FormatUint(b.Header.View, 10)))
+	writeField([]byte(b.Header.ReceiptsRoot))
+
+	return hex.EncodeToString(h.Sum(nil))
 }
 
+// Inclusion proof tx ke-index terhadap TxRoot block
+func BuildTxProof(txs []Transaction, index int) (MerkleProof, error) {
+	return BuildMerkleProof(txLeaves(txs), index)
+}
+
+// Canonical tx root: merkle root atas hash setiap tx sesuai urutan di block
 func CalculateTxRoot(txs []Transaction) string {
 	if len(txs) == 0 {
 		return strings.Repeat("0", 64)
 	}
 
-	h := sha256.New()
-	for _, tx := range txs {
-		txHash := tx.Hash()
-		txHashBytes, _ := hex.DecodeString(txHash)
+	return hex.EncodeToString(MerkleRoot(txLeaves(txs)))
+}
 
-		h.Write(txHashBytes)
+// Leaf tx root: hash setiap tx sesuai urutan di block
+func txLeaves(txs []Transaction) [][]byte {
+	leaves := make([][]byte, len(txs))
+	for i, tx := range txs {
+		txHashBytes, _ := hex.DecodeString(tx.Hash())
+		leaves[i] = txHashBytes
 	}
-
-	return hex.EncodeToString(h.Sum(nil))
+	return leaves
 }
@@ -0,0 +1,24 @@
+package types
+
+import "testing"
+
+func TestHeaderHashFieldBoundaries(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b BlockHeader
+	}{
+		{"height and timestamp", BlockHeader{Height: 1, Timestamp: 23}, BlockHeader{Height: 12, Timestamp: 3}},
+		{"prev hash and state root", BlockHeader{PrevHash: "ab", StateRoot: "c"}, BlockHeader{PrevHash: "a", StateRoot: "bc"}},
+		{"proposer and view", BlockHeader{ProposerID: "v1", View: 2}, BlockHeader{ProposerID: "v", View: 12}},
+		{"view and receipts root", BlockHeader{View: 1, ReceiptsRoot: "0a"}, BlockHeader{View: 10, ReceiptsRoot: "a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := Block{Header: tt.a}, Block{Header: tt.b}
+			if a.HeaderHash() == b.HeaderHash() {
+				t.Fatalf("headers %+v and %+v hash the same", tt.a, tt.b)
+			}
+		})
+	}
+}
@@ -1,9 +1,12 @@
 // Berisi data yang tersimpan di blockchain
 package types
 
+// Lifecycle rujukan: ACTIVE -> USED / EXPIRED / CANCELLED (semua final)
 const (
-	RujukanStatusActive = "ACTIVE"
-	RujukanStatusUsed   = "USED"
+	RujukanStatusActive    = "ACTIVE"
+	RujukanStatusUsed      = "USED"      // Sudah dipakai untuk claim di faskes tujuan
+	RujukanStatusExpired   = "EXPIRED"   // Lewat ExpiryDate berdasarkan waktu block
+	RujukanStatusCancelled = "CANCELLED" // Dibatalkan faskes pembuat (RUJUKAN_BURN)
 )
 
 type RujukanAsset struct {
@@ -26,12 +29,17 @@ type ClaimAsset struct {
 
 	RujukanID string `json:"rujukan_id"`
 	FaskesID  string `json:"faskes_id"`
+	PesertaID string `json:"peserta_id"`
 
 	RekamMedisID   string `json:"rekam_medis_id"`
 	RekamMedisHash string `json:"rekam_medis_hash"`
 
 	DiagnosisCode string `json:"diagnosis_code"`
 	Amount        uint64 `json:"amount"`
+	CBGCode       string `json:"cbg_code"`       // Grup INA-CBG hasil grouping
+	TariffVersion string `json:"tariff_version"` // Versi tabel tarif saat claim dihitung
 	Status        string `json:"status"`
-	Timestamp     int64  `json:"timestamp"` // Kapan disubmit
+	Timestamp     int64  `json:"timestamp"`              // Kapan disubmit
+	SubmittedAt   int64  `json:"submitted_at"`           // Waktu block saat claim masuk chain
+	FraudReason   string `json:"fraud_reason,omitempty"` // Alasan claim ditandai FAKED
 }
@@ -1,6 +1,42 @@
 package types
 
+import "fmt"
+
+// Tipe vote validator (two-phase voting)
+const (
+	VoteTypePrepare = "PREPARE"
+	VoteTypeCommit  = "COMMIT"
+)
+
 type QuorumCertificate struct {
-	HeaderHash string `json:"header_hash"`
-	Signatures string `json:"signatures"` // leader hex encoded signature
+	HeaderHash string            `json:"header_hash"`
+	Height     uint64            `json:"height"`
+	View       uint64            `json:"view"`       // view saat QC terbentuk (>= view pada header block)
+	VoteType   string            `json:"vote_type"`  // PREPARE atau COMMIT (block yang di commit selalu COMMIT)
+	Signers    []string          `json:"signers"`    // id validator yang menandatangani (sorted)
+	Signatures map[string]string `json:"signatures"` // id validator -> hex encoded signature atas SignBytes()
+}
+
+// Jumlah minimal signature agar lebih dari 2/3 validator setuju
+func QuorumSize(validators int) int {
+	return validators*2/3 + 1
+}
+
+// Byte yang ditandatangani validator saat memberikan vote
+func VoteSignBytes(voteType string, height uint64, view uint64, headerHash string) []byte {
+	return []byte(fmt.Sprintf("%s:%d:%d:%s", voteType, height, view, headerHash))
+}
+
+// Byte yang ditandatangani leader saat mengirim proposal untuk view tertentu
+func ProposalSignBytes(headerHash string, view uint64) []byte {
+	return []byte(fmt.Sprintf("PROPOSAL:%d:%s", view, headerHash))
+}
+
+// Byte yang ditandatangani validator saat meminta pergantian view
+func ViewChangeSignBytes(height uint64, view uint64) []byte {
+	return []byte(fmt.Sprintf("VIEW_CHANGE:%d:%d", height, view))
+}
+
+func (qc *QuorumCertificate) SignBytes() []byte {
+	return VoteSignBytes(qc.VoteType, qc.Height, qc.View, qc.HeaderHash)
 }
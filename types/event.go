@@ -0,0 +1,13 @@
+package types
+
+// Jenis event yang dihasilkan smart contract
+const (
+	EventClaimStatusChanged = "CLAIM_STATUS_CHANGED"
+)
+
+// Event hasil eksekusi tx. Side effect ke sistem luar (database BPJS)
+// hanya dijalankan dari event ini setelah block di commit
+type Event struct {
+	Type       string            `json:"type"`
+	Attributes map[string]string `json:"attributes"`
+}
@@ -0,0 +1,75 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// Genesis mendeskripsikan state awal chain. Semua node dalam satu network
+// harus memakai genesis yang sama (dibandingkan lewat Hash saat handshake)
+type Genesis struct {
+	ChainID       string            `json:"chain_id"`     // ex: "sehat-mainnet", "sehat-testnet"
+	GenesisTime   int64             `json:"genesis_time"` // Unix timestamp block genesis
+	Validators    []ValidatorConfig `json:"validators"`   // validator set awal
+	Submitters    []SubmitterConfig `json:"submitters"`   // faskes & admin BPJS yang terdaftar
+	TariffVersion string            `json:"tariff_version"`
+	TariffHash    string            `json:"tariff_hash"` // hash isi tabel tarif versi genesis
+	Assets        GenesisAssets     `json:"assets"`
+}
+
+// Asset yang sudah ada sebelum chain berjalan (migrasi data lama)
+type GenesisAssets struct {
+	Visits   []TxVisit      `json:"visits,omitempty"`
+	Rujukans []RujukanAsset `json:"rujukans,omitempty"`
+	Claims   []ClaimAsset   `json:"claims,omitempty"`
+}
+
+// Hash genesis atas encoding json (urutan field mengikuti struct)
+func (g Genesis) Hash() string {
+	data, _ := json.Marshal(g)
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func (g Genesis) Validate() error {
+	if g.ChainID == "" {
+		return fmt.Errorf("genesis chain_id is empty")
+	}
+
+	if len(g.Validators) == 0 {
+		return fmt.Errorf("genesis has no validators")
+	}
+
+	validators := make(map[string]bool)
+	for _, validator := range g.Validators {
+		if validator.ID == "" || validator.PublicKey == "" {
+			return fmt.Errorf("genesis validator must have ID and PublicKey")
+		}
+		if validators[validator.ID] {
+			return fmt.Errorf("duplicate genesis validator %s", validator.ID)
+		}
+		validators[validator.ID] = true
+	}
+
+	submitters := make(map[string]bool)
+	for _, submitter := range g.Submitters {
+		if submitter.ID == "" || submitter.PublicKey == "" {
+			return fmt.Errorf("genesis submitter must have ID and PublicKey")
+		}
+		if submitter.Role != RoleFaskes && submitter.Role != RoleBPJSAdmin {
+			return fmt.Errorf("unknown role %s for submitter %s", submitter.Role, submitter.ID)
+		}
+		if submitters[submitter.ID] {
+			return fmt.Errorf("duplicate genesis submitter %s", submitter.ID)
+		}
+		submitters[submitter.ID] = true
+	}
+
+	if g.TariffVersion == "" || g.TariffHash == "" {
+		return fmt.Errorf("genesis tariff_version and tariff_hash are required")
+	}
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package types
+
+import "fmt"
+
+// Jarak minimal (dalam block) antara tx governance di commit dan perubahan validator berlaku
+const ValidatorChangeDelay = 2
+
+// Payload ADD_VALIDATOR / REMOVE_VALIDATOR.
+// Untuk REMOVE_VALIDATOR cukup Validator.ID yang diisi
+type TxValidatorChange struct {
+	Validator       ValidatorConfig   `json:"validator"`
+	EffectiveHeight uint64            `json:"effective_height"` // height block pertama yang memakai validator set baru
+	Approvals       map[string]string `json:"approvals"`        // id validator -> signature atas ValidatorChangeSignBytes
+}
+
+// Perubahan validator set yang sudah disetujui dan menunggu EffectiveHeight
+type ValidatorChange struct {
+	ID              string          `json:"id"` // id tx governance
+	Action          string          `json:"action"`
+	Validator       ValidatorConfig `json:"validator"`
+	EffectiveHeight uint64          `json:"effective_height"`
+}
+
+// Byte yang ditandatangani validator saat menyetujui perubahan validator set
+func ValidatorChangeSignBytes(action string, validator ValidatorConfig, effectiveHeight uint64) []byte {
+	return []byte(fmt.Sprintf("%s:%s:%s:%s:%d", action, validator.ID, validator.PublicKey, validator.Address, effectiveHeight))
+}
@@ -0,0 +1,122 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Prefix untuk membedakan hash leaf dan hash node (mencegah second preimage attack)
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+func merkleLeafHash(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleLeafPrefix})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func merkleNodeHash(left []byte, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleNodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// Binary merkle root dari list leaf. Node ganjil di akhir level dinaikkan tanpa di hash ulang
+func MerkleRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		return nil
+	}
+
+	level := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		level[i] = merkleLeafHash(leaf)
+	}
+
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, merkleNodeHash(level[i], level[i+1]))
+		}
+		level = next
+	}
+
+	return level[0]
+}
+
+// Satu langkah proof: hash sibling pada level tersebut
+type MerkleProofStep struct {
+	Hash string `json:"hash"` // hex encoded
+	Left bool   `json:"left"` // sibling berada di kiri
+}
+
+// Inclusion proof leaf terhadap MerkleRoot. Level dimana node dinaikkan tanpa sibling tidak punya step
+type MerkleProof struct {
+	Index int               `json:"index"` // posisi leaf
+	Steps []MerkleProofStep `json:"steps"`
+}
+
+// Bangun inclusion proof untuk leaf ke-index
+func BuildMerkleProof(leaves [][]byte, index int) (MerkleProof, error) {
+	if index < 0 || index >= len(leaves) {
+		return MerkleProof{}, fmt.Errorf("leaf index %d out of range (%d leaves)", index, len(leaves))
+	}
+
+	level := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		level[i] = merkleLeafHash(leaf)
+	}
+
+	proof := MerkleProof{Index: index, Steps: []MerkleProofStep{}}
+	position := index
+
+	for len(level) > 1 {
+		if sibling := position ^ 1; sibling < len(level) {
+			proof.Steps = append(proof.Steps, MerkleProofStep{
+				Hash: hex.EncodeToString(level[sibling]),
+				Left: sibling < position,
+			})
+		}
+
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, merkleNodeHash(level[i], level[i+1]))
+		}
+		level = next
+		position /= 2
+	}
+
+	return proof, nil
+}
+
+// Cek leaf termasuk dalam root (hex encoded) berdasarkan proof
+func VerifyMerkleProof(root string, leaf []byte, proof MerkleProof) bool {
+	hash := merkleLeafHash(leaf)
+	for _, step := range proof.Steps {
+		sibling, err := hex.DecodeString(step.Hash)
+		if err != nil {
+			return false
+		}
+
+		if step.Left {
+			hash = merkleNodeHash(sibling, hash)
+		} else {
+			hash = merkleNodeHash(hash, sibling)
+		}
+	}
+
+	return hex.EncodeToString(hash) == root
+}
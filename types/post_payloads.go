@@ -19,15 +19,25 @@ type TxRujukan struct {
 	DiagnosisCode   string `json:"diagnosis_code"`
 }
 
+// Payload pembatalan rujukan oleh faskes pembuat
+type TxBurnRujukan struct {
+	RujukanID string `json:"rujukan_id"`
+	Reason    string `json:"reason"`
+}
+
 // Payload untuk submit claim
 // digunakan oleh rumah sakit terujuk saat upload rekam medis final.
 type TxSubmitClaim struct {
-	ClaimID        string `json:"claim_id"`
-	RujukanID      string `json:"rujukan_id"`
-	RekamMedisID   string `json:"rekam_medis_id"`
-	RekamMedisHash string `json:"rekam_medis_hash"`
-	DiagnosisCode  string `json:"diagnosis_final"`
-	Amount         uint64 `json:"amount"`
+	ClaimID        string   `json:"claim_id"`
+	RujukanID      string   `json:"rujukan_id"`
+	PesertaID      string   `json:"patient_id"`
+	RekamMedisID   string   `json:"rekam_medis_id"`
+	RekamMedisHash string   `json:"rekam_medis_hash"`
+	DiagnosisCode  string   `json:"diagnosis_final"`
+	ProcedureCodes []string `json:"procedure_codes"` // ICD-9-CM
+	CareClass      string   `json:"care_class"`      // kelas rawat 1 / 2 / 3
+	LengthOfStay   int      `json:"length_of_stay"`  // hari
+	Amount         uint64   `json:"amount"`
 }
 
 // Payload persetujuan pembayaran
@@ -40,9 +50,16 @@ type TxExecuteClaim struct {
 	Status  string `json:"status"`
 }
 
+// Payload penggantian versi tabel tarif yang dipakai untuk claim berikutnya
+type TxSetTariffVersion struct {
+	Version string `json:"version"`
+	Hash    string `json:"hash"` // hash isi tabel, node dengan tabel berbeda menolak menghitung claim
+}
+
 const (
 	ClaimStatusSubmitted = "SUBMITTED" // Claim disubmit client tapi belum diverifikasi blockchain
 	ClaimStatusPending   = "PENDING"   // Claim sudah diverifikasi blockchain
+	ClaimStatusApproved  = "APPROVED"  // Claim disetujui admin BPJS, menunggu pembayaran
 	ClaimStatusPaid      = "PAID"      // TxExecuteClaim sudah dijalankan admin
 	ClaimStatusRejected  = "REJECTED"  // Claim direject oleh blockchain atau saat TxExecuteClaim
 	ClaimStatusFaked     = "FAKED"
@@ -0,0 +1,63 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+const (
+	ReceiptStatusSuccess = "SUCCESS"
+	ReceiptStatusFailed  = "FAILED"
+)
+
+// Kode alasan tx gagal dieksekusi
+const (
+	ErrCodeMalformedPayload  = "MALFORMED_PAYLOAD"
+	ErrCodeUnknownTxType     = "UNKNOWN_TX_TYPE"
+	ErrCodeUnauthorized      = "UNAUTHORIZED"
+	ErrCodeRujukanInvalid    = "RUJUKAN_INVALID"
+	ErrCodeRujukanExists     = "RUJUKAN_EXISTS"
+	ErrCodeRujukanExpired    = "RUJUKAN_EXPIRED"
+	ErrCodeClaimRejected     = "CLAIM_REJECTED"
+	ErrCodeClaimNotFound     = "CLAIM_NOT_FOUND"
+	ErrCodeClaimExists       = "CLAIM_EXISTS"
+	ErrCodeClaimDuplicate    = "CLAIM_DUPLICATE"
+	ErrCodeTariffUnavailable = "TARIFF_UNAVAILABLE"
+	ErrCodeInvalidValidator  = "INVALID_VALIDATOR_CHANGE"
+	ErrCodeNoQuorum          = "NO_QUORUM"
+	ErrCodeClaimTransition   = "CLAIM_INVALID_TRANSITION"
+)
+
+// Hasil eksekusi satu tx di dalam block
+type Receipt struct {
+	TxID         string   `json:"tx_id"`
+	BlockHeight  uint64   `json:"block_height"`
+	Status       string   `json:"status"`
+	ErrorCode    string   `json:"error_code,omitempty"`
+	ErrorMessage string   `json:"error_message,omitempty"`
+	Events       []Event  `json:"events"`
+	StateKeys    []string `json:"state_keys"` // key state yang diubah, format namespace:id
+}
+
+// Hash canonical receipt (json dengan urutan field tetap)
+func (r Receipt) Hash() []byte {
+	data, _ := json.Marshal(r)
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+// Merkle root atas hash receipt sesuai urutan tx di block
+func CalculateReceiptsRoot(receipts []Receipt) string {
+	if len(receipts) == 0 {
+		return strings.Repeat("0", 64)
+	}
+
+	leaves := make([][]byte, len(receipts))
+	for i, receipt := range receipts {
+		leaves[i] = receipt.Hash()
+	}
+
+	return hex.EncodeToString(MerkleRoot(leaves))
+}
@@ -0,0 +1,16 @@
+package types
+
+// Role pihak yang boleh mengirim transaksi ke blockchain
+const (
+	RoleFaskes    = "FASKES"     // Fasilitas kesehatan (FK1 / FK2)
+	RoleBPJSAdmin = "BPJS_ADMIN" // Admin BPJS yang mengeksekusi claim
+)
+
+// SubmitterConfig mendaftarkan pengirim transaksi yang diotorisasi
+type SubmitterConfig struct {
+	ID        string
+	Role      string
+	PublicKey string // hex encoded Ed25519 public key
+
+	RegionalTier string // regional tarif INA-CBG (khusus faskes)
+}
@@ -3,9 +3,10 @@ package types
 
 import (
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
-	"fmt"
+	"strconv"
 )
 
 // TransactionType
@@ -15,6 +16,11 @@ const (
 	TxTypeSubmitClaim   = "SUBMIT_CLAIM"  // Submit klaim oleh pengunjung
 	TxTypeExecuteClaim  = "EXECUTE_CLAIM" // Persetujuan final bahwa BPJS telah memvalidasi dan akan membayar
 	TxTypeRedeemRujukan = "RUJUKAN_BURN"
+
+	TxTypeSetTariffVersion = "SET_TARIFF_VERSION" // Admin BPJS mengganti versi tabel tarif INA-CBG
+
+	TxTypeAddValidator    = "ADD_VALIDATOR"    // Governance: tambah validator (disetujui quorum validator)
+	TxTypeRemoveValidator = "REMOVE_VALIDATOR" // Governance: hapus validator (disetujui quorum validator)
 )
 
 // Wrapping transaction yang disebar antar node
@@ -23,19 +29,30 @@ type Transaction struct {
 	Type      string          `json:"type"`
 	Timestamp int64           `json:"timestamp"`
 	SenderID  string          `json:"sender_id"`
-	Signature string          `json:"signature"` // Mock signature
+	Nonce     uint64          `json:"nonce"`     // harus lebih besar dari nonce tx sebelumnya dari pengirim yang sama
+	Signature string          `json:"signature"` // hex encoded Ed25519 signature atas Hash()
 	Payload   json.RawMessage `json:"payload"`
 }
 
+// Canonical hash tx yang ditandatangani pengirim.
+// Semua field (kecuali Signature) di-hash dengan prefix panjang
+// agar batas antar field tidak ambigu
 func (tx *Transaction) Hash() string {
-	data := fmt.Sprintf("%s%d%s%s",
-		tx.Type,
-		tx.Timestamp,
-		tx.SenderID,
-		string(tx.Payload),
-	)
-
-	hashBytes := sha256.Sum256([]byte(data))
-	hash := hex.EncodeToString(hashBytes[:])
-	return hash
+	h := sha256.New()
+
+	writeField := func(data []byte) {
+		var length [8]byte
+		binary.BigEndian.PutUint64(length[:], uint64(len(data)))
+		h.Write(length[:])
+		h.Write(data)
+	}
+
+	writeField([]byte(tx.ID))
+	writeField([]byte(tx.Type))
+	writeField([]byte(strconv.FormatInt(tx.Timestamp, 10)))
+	writeField([]byte(tx.SenderID))
+	writeField([]byte(strconv.FormatUint(tx.Nonce, 10)))
+	writeField(tx.Payload)
+
+	return hex.EncodeToString(h.Sum(nil))
 }
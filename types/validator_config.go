@@ -2,7 +2,7 @@ package types
 
 // ValidatorConfig digunakan untuk passing data dari main/cmd ke Node
 type ValidatorConfig struct {
-	ID      string
-	Secret  string
-	Address string // IP:Port (ex: "192.168.1.5:9000")
+	ID        string
+	PublicKey string // hex encoded Ed25519 public key
+	Address   string // IP:Port (ex: "192.168.1.5:9000")
 }
@@ -1,29 +1,91 @@
 package utils
 
-import "fmt"
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
 
-// Mock keypair
+// Keypair Ed25519 milik node
 type CryptoCred struct {
-	secret string
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
 }
 
-func NewCred(secret string) *CryptoCred {
+func NewCred(privateKey ed25519.PrivateKey) *CryptoCred {
 	return &CryptoCred{
-		secret: secret,
+		privateKey: privateKey,
+		publicKey:  privateKey.Public().(ed25519.PublicKey),
 	}
 }
 
-func (cc *CryptoCred) GetSecret() string {
-	return cc.secret
+// Membuat keypair baru secara acak
+func GenerateCred() (*CryptoCred, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCred(privateKey), nil
+}
+
+// Load keypair dari key file (berisi hex encoded seed 32 byte)
+func LoadCred(path string) (*CryptoCred, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file %s: %w", path, err)
+	}
+
+	seed, err := hex.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %w", path, err)
+	}
+
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid key file %s: expecting %d bytes seed, got %d", path, ed25519.SeedSize, len(seed))
+	}
+
+	return NewCred(ed25519.NewKeyFromSeed(seed)), nil
 }
 
+// Simpan seed private key ke key file
+func (cc *CryptoCred) Save(path string) error {
+	seed := hex.EncodeToString(cc.privateKey.Seed())
+	return os.WriteFile(path, []byte(seed+"\n"), 0600)
+}
+
+// Hex encoded public key
+func (cc *CryptoCred) PublicKey() string {
+	return hex.EncodeToString(cc.publicKey)
+}
+
+// Sign data dan return hex encoded signature
 func (cc *CryptoCred) Sign(data []byte) string {
-	return cc.secret
+	return hex.EncodeToString(ed25519.Sign(cc.privateKey, data))
 }
 
+// Validasi signature yang dibuat oleh keypair ini sendiri
 func (cc *CryptoCred) Validate(signature string, data []byte) error {
-	if signature != cc.secret {
-		return fmt.Errorf("wrong signature, has (%s) but calculated (%s)", signature, cc.secret)
+	return Verify(cc.PublicKey(), signature, data)
+}
+
+// Verifikasi hex encoded signature terhadap hex encoded public key penandatangan
+func Verify(publicKey string, signature string, data []byte) error {
+	pubKeyBytes, err := hex.DecodeString(publicKey)
+	if err != nil || len(pubKeyBytes) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid public key (%s)", publicKey)
+	}
+
+	sigBytes, err := hex.DecodeString(signature)
+	if err != nil || len(sigBytes) != ed25519.SignatureSize {
+		return fmt.Errorf("invalid signature format")
+	}
+
+	if !ed25519.Verify(ed25519.PublicKey(pubKeyBytes), data, sigBytes) {
+		return fmt.Errorf("signature verification failed")
 	}
 
 	return nil